@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"sync"
 	"time"
 
 	"github.com/apex/log"
@@ -16,10 +15,9 @@ import (
 
 var _ log.Handler = (*Handler)(nil)
 
-const (
-	batchSize    = 1024
-	sendInterval = time.Second
-)
+// flushTimeout is the maximum time spent sending the buffered events when
+// closing.
+const flushTimeout = 10 * time.Second
 
 // ErrMissingDatasetName is raised when a dataset name is not provided. Set it
 // manually using the SetDataset option or export `AXIOM_DATASET`.
@@ -55,6 +53,18 @@ func SetDataset(datasetName string) Option {
 	}
 }
 
+// SetSpool makes the handler persist batches of logs which fail to be ingested
+// because Axiom is unreachable or a limit is exceeded to the directory given in
+// the `axiom.SpoolConfig`, instead of dropping them. They are replayed in order
+// with the next logs or, if there are none, after a second, even after a
+// restart. Persisted logs are not reported as failed. See `axiom.Spool`.
+func SetSpool(config axiom.SpoolConfig) Option {
+	return func(h *Handler) error {
+		h.spoolConfig = &config
+		return nil
+	}
+}
+
 // SetIngestOptions specifies the ingestion options to use for ingesting the
 // logs.
 func SetIngestOptions(opts ...ingest.Option) Option {
@@ -64,6 +74,16 @@ func SetIngestOptions(opts ...ingest.Option) Option {
 	}
 }
 
+// SetProcessor specifies a processor, e.g. an `ingest.Pipeline`, which
+// transforms the events created from log entries before they are queued for
+// ingestion. Events it drops are discarded.
+func SetProcessor(processor ingest.Processor) Option {
+	return func(h *Handler) error {
+		h.processor = processor
+		return nil
+	}
+}
+
 // Handler implements a `log.Handler` used for shipping logs to Axiom.
 type Handler struct {
 	client      *axiom.Client
@@ -71,11 +91,11 @@ type Handler struct {
 
 	clientOptions []axiom.Option
 	ingestOptions []ingest.Option
+	processor     ingest.Processor
+	spoolConfig   *axiom.SpoolConfig
+	spool         *axiom.Spool
 
-	eventCh   chan axiom.Event
-	cancel    context.CancelFunc
-	closeCh   chan struct{}
-	closeOnce sync.Once
+	ingester *axiom.Ingester
 }
 
 // New creates a new `Handler` configured to ingest logs to the Axiom deployment
@@ -92,10 +112,7 @@ type Handler struct {
 // A handler needs to be closed properly to make sure all logs are sent by
 // calling `Close()`.
 func New(options ...Option) (*Handler, error) {
-	handler := &Handler{
-		eventCh: make(chan axiom.Event, 1),
-		closeCh: make(chan struct{}),
-	}
+	handler := new(Handler)
 
 	// Apply supplied options.
 	for _, option := range options {
@@ -112,6 +129,14 @@ func New(options ...Option) (*Handler, error) {
 		}
 	}
 
+	// Create spool, if configured.
+	if handler.spoolConfig != nil {
+		var err error
+		if handler.spool, err = axiom.NewSpool(handler.client.Datasets, *handler.spoolConfig); err != nil {
+			return nil, err
+		}
+	}
+
 	// When the dataset name is not set, use `AXIOM_DATASET`.
 	if handler.datasetName == "" {
 		handler.datasetName = os.Getenv("AXIOM_DATASET")
@@ -120,22 +145,33 @@ func New(options ...Option) (*Handler, error) {
 		}
 	}
 
-	// Run background scheduler.
-	var ctx context.Context
-	ctx, handler.cancel = context.WithCancel(context.Background())
-	go handler.run(ctx, handler.closeCh)
+	// Batch and send events in the background, through the spool, if
+	// configured.
+	var datasets axiom.EventIngester = handler.client.Datasets
+	if handler.spool != nil {
+		datasets = handler.spool
+	}
+
+	var err error
+	if handler.ingester, err = axiom.NewIngester(datasets, handler.datasetName, axiom.IngesterConfig{
+		IngestOptions:  handler.ingestOptions,
+		OnFailedEvents: reportFailedEvents,
+	}); err != nil {
+		return nil, err
+	}
 
 	return handler, nil
 }
 
-// Close the handler and make sure all events are flushed. Closing the handler
-// renders it unusable for further use.
+// Close the handler and make sure all events are flushed, waiting at most ten
+// seconds for them to be sent. Closing the handler renders it unusable for
+// further use.
 func (h *Handler) Close() {
-	h.closeOnce.Do(func() {
-		close(h.eventCh)
-		h.cancel()
-		<-h.closeCh
-	})
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	// Errors are already reported by the ingester.
+	_ = h.ingester.Close(ctx)
 }
 
 // HandleLog implements `log.Handler`.
@@ -152,63 +188,17 @@ func (h *Handler) HandleLog(entry *log.Entry) error {
 	event["severity"] = entry.Level.String()
 	event["message"] = entry.Message
 
-	h.eventCh <- event
-
-	return nil
-}
-
-func (h *Handler) run(ctx context.Context, closeCh chan struct{}) {
-	defer close(closeCh)
-
-	t := time.NewTicker(sendInterval)
-	defer t.Stop()
-
-	events := make([]axiom.Event, 0, batchSize)
-
-	defer func() {
-		flushCtx, flushCancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer flushCancel()
-		h.ingest(flushCtx, events)
-	}()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-t.C:
-			if len(events) == 0 {
-				continue
-			}
-		case event, ok := <-h.eventCh:
-			if !ok {
-				continue
-			}
-
-			events = append(events, event)
-
-			if len(events) < batchSize {
-				continue
-			}
+	if h.processor != nil {
+		if event = h.processor.Process(event); event == nil {
+			return nil
 		}
-
-		h.ingest(ctx, events)
-
-		// Clear batch buffer.
-		events = make([]axiom.Event, 0, batchSize)
 	}
-}
 
-func (h *Handler) ingest(ctx context.Context, events []axiom.Event) {
-	if len(events) == 0 {
-		return
-	}
+	return h.ingester.Ingest(context.Background(), event)
+}
 
-	res, err := h.client.Datasets.IngestEvents(ctx, h.datasetName, events, h.ingestOptions...)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to ingest batch of %d events: %s\n", len(events), err)
-	} else if res.Failed > 0 {
-		// Best effort on notifying the user about the ingest failure.
-		fmt.Fprintf(os.Stderr, "event at %s failed to ingest: %s\n",
-			res.Failures[0].Timestamp, res.Failures[0].Error)
-	}
+// reportFailedEvents notifies the user about events which failed to be
+// ingested, on a best effort basis.
+func reportFailedEvents(events []axiom.FailedEvent) {
+	fmt.Fprintf(os.Stderr, "%d events failed to ingest: %s\n", len(events), events[0].Error)
 }
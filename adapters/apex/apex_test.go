@@ -2,11 +2,13 @@ package apex
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -112,6 +114,73 @@ func TestHandler_FlushFullBatch(t *testing.T) {
 	assert.EqualValues(t, 1025, atomic.LoadUint64(&lines))
 }
 
+func TestHandler_Spool(t *testing.T) {
+	var (
+		up    uint32
+		lines uint64
+	)
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadUint32(&up) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		zsr, err := zstd.NewReader(r.Body)
+		require.NoError(t, err)
+
+		s := bufio.NewScanner(zsr)
+		for s.Scan() {
+			atomic.AddUint64(&lines, 1)
+		}
+		assert.NoError(t, s.Err())
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	}
+
+	dir := t.TempDir()
+	setupSpool := func(dataset string, client *axiom.Client) *Handler {
+		require.NoError(t, client.Options(axiom.SetNoRetry()))
+
+		handler, err := New(
+			SetClient(client),
+			SetDataset(dataset),
+			SetSpool(axiom.SpoolConfig{Dir: dir}),
+		)
+		require.NoError(t, err)
+
+		return handler
+	}
+
+	// While Axiom is unavailable, the batch is persisted...
+	handler := adapters.Setup(t, hf, setupSpool)
+	(&log.Logger{Handler: handler, Level: log.InfoLevel}).Info("my message")
+
+	// Wait for timer based handler flush.
+	time.Sleep(1250 * time.Millisecond)
+	handler.Close()
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Zero(t, atomic.LoadUint64(&lines))
+
+	// ... and replayed by a new handler once it is available again.
+	atomic.StoreUint32(&up, 1)
+
+	handler = adapters.Setup(t, hf, setupSpool)
+	(&log.Logger{Handler: handler, Level: log.InfoLevel}).Info("my message")
+
+	// Wait for timer based handler flush.
+	time.Sleep(1250 * time.Millisecond)
+	handler.Close()
+
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.EqualValues(t, 2, atomic.LoadUint64(&lines))
+}
+
 func setup(t *testing.T) func(dataset string, client *axiom.Client) *log.Logger {
 	return func(dataset string, client *axiom.Client) *log.Logger {
 		t.Helper()
@@ -159,3 +228,47 @@ func JSONEqExp(t assert.TestingT, expected string, actual string, excludedFields
 
 	return assert.Equal(t, expectedJSONAsInterface, actualJSONAsInterface, msgAndArgs...)
 }
+
+func TestHandler_Processor(t *testing.T) {
+	datasets := new(recordingIngester)
+
+	ing, err := axiom.NewIngester(datasets, "test", axiom.IngesterConfig{})
+	require.NoError(t, err)
+
+	handler := &Handler{
+		processor: ingest.Pipeline{
+			ingest.Filter(func(event map[string]any) bool { return event["message"] != "drop me" }),
+			ingest.AddFields(map[string]any{"service": "api"}),
+			ingest.DropFields("password"),
+		},
+		ingester: ing,
+	}
+
+	logger := &log.Logger{Handler: handler, Level: log.InfoLevel}
+	for _, msg := range []string{"keep me", "drop me"} {
+		logger.WithField("password", "secret").Info(msg)
+	}
+	handler.Close()
+
+	events := datasets.events
+	require.Len(t, events, 1)
+
+	assert.Equal(t, "keep me", events[0]["message"])
+	assert.Equal(t, "api", events[0]["service"])
+	assert.NotContains(t, events[0], "password")
+}
+
+// recordingIngester is an `axiom.EventIngester` which records the events it is
+// passed.
+type recordingIngester struct {
+	events []axiom.Event
+	mtx    sync.Mutex
+}
+
+func (r *recordingIngester) IngestEvents(_ context.Context, _ string, events []axiom.Event, _ ...ingest.Option) (*ingest.Status, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.events = append(r.events, events...)
+	return &ingest.Status{Ingested: uint64(len(events))}, nil
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -16,10 +15,9 @@ import (
 
 var _ logrus.Hook = (*Hook)(nil)
 
-const (
-	batchSize    = 1024
-	sendInterval = time.Second
-)
+// flushTimeout is the maximum time spent sending the buffered events when
+// closing.
+const flushTimeout = 10 * time.Second
 
 // ErrMissingDatasetName is raised when a dataset name is not provided. Set it
 // manually using the SetDataset option or export `AXIOM_DATASET`.
@@ -55,6 +53,18 @@ func SetDataset(datasetName string) Option {
 	}
 }
 
+// SetSpool makes the hook persist batches of logs which fail to be ingested
+// because Axiom is unreachable or a limit is exceeded to the directory given in
+// the `axiom.SpoolConfig`, instead of dropping them. They are replayed in order
+// with the next logs or, if there are none, after a second, even after a
+// restart. Persisted logs are not reported as failed. See `axiom.Spool`.
+func SetSpool(config axiom.SpoolConfig) Option {
+	return func(h *Hook) error {
+		h.spoolConfig = &config
+		return nil
+	}
+}
+
 // SetIngestOptions specifies the ingestion options to use for ingesting the
 // logs.
 func SetIngestOptions(opts ...ingest.Option) Option {
@@ -64,6 +74,16 @@ func SetIngestOptions(opts ...ingest.Option) Option {
 	}
 }
 
+// SetProcessor specifies a processor, e.g. an `ingest.Pipeline`, which
+// transforms the events created from log entries before they are queued for
+// ingestion. Events it drops are discarded.
+func SetProcessor(processor ingest.Processor) Option {
+	return func(h *Hook) error {
+		h.processor = processor
+		return nil
+	}
+}
+
 // SetLevels sets the logrus levels that the Axiom hook will create log entries
 // for.
 func SetLevels(levels ...logrus.Level) Option {
@@ -80,12 +100,12 @@ type Hook struct {
 
 	clientOptions []axiom.Option
 	ingestOptions []ingest.Option
+	processor     ingest.Processor
+	spoolConfig   *axiom.SpoolConfig
+	spool         *axiom.Spool
 	levels        []logrus.Level
 
-	eventCh   chan axiom.Event
-	cancel    context.CancelFunc
-	closeCh   chan struct{}
-	closeOnce sync.Once
+	ingester *axiom.Ingester
 }
 
 // New creates a new `Hook` configured to ingest logs to the Axiom deployment
@@ -104,9 +124,6 @@ type Hook struct {
 func New(options ...Option) (*Hook, error) {
 	hook := &Hook{
 		levels: logrus.AllLevels,
-
-		eventCh: make(chan axiom.Event, 1),
-		closeCh: make(chan struct{}),
 	}
 
 	// Apply supplied options.
@@ -124,6 +141,14 @@ func New(options ...Option) (*Hook, error) {
 		}
 	}
 
+	// Create spool, if configured.
+	if hook.spoolConfig != nil {
+		var err error
+		if hook.spool, err = axiom.NewSpool(hook.client.Datasets, *hook.spoolConfig); err != nil {
+			return nil, err
+		}
+	}
+
 	// When the dataset name is not set, use `AXIOM_DATASET`.
 	if hook.datasetName == "" {
 		hook.datasetName = os.Getenv("AXIOM_DATASET")
@@ -132,23 +157,34 @@ func New(options ...Option) (*Hook, error) {
 		}
 	}
 
-	// Run background scheduler.
-	var ctx context.Context
-	ctx, hook.cancel = context.WithCancel(context.Background())
-	go hook.run(ctx, hook.closeCh)
+	// Batch and send events in the background, through the spool, if
+	// configured.
+	var datasets axiom.EventIngester = hook.client.Datasets
+	if hook.spool != nil {
+		datasets = hook.spool
+	}
+
+	var err error
+	if hook.ingester, err = axiom.NewIngester(datasets, hook.datasetName, axiom.IngesterConfig{
+		IngestOptions:  hook.ingestOptions,
+		OnFailedEvents: reportFailedEvents,
+	}); err != nil {
+		return nil, err
+	}
 
 	return hook, nil
 }
 
-// Close the hook and make sure all events are flushed. This should be
-// registered with `logrus.RegisterExitHandler(h.Close)`. Closing the hook
-// renders it unusable for further use.
+// Close the hook and make sure all events are flushed, waiting at most ten
+// seconds for them to be sent. This should be registered with
+// `logrus.RegisterExitHandler(h.Close)`. Closing the hook renders it unusable
+// for further use.
 func (h *Hook) Close() {
-	h.closeOnce.Do(func() {
-		close(h.eventCh)
-		h.cancel()
-		<-h.closeCh
-	})
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	// Errors are already reported by the ingester.
+	_ = h.ingester.Close(ctx)
 }
 
 // Levels implements `logrus.Hook`.
@@ -170,63 +206,17 @@ func (h *Hook) Fire(entry *logrus.Entry) error {
 	event["severity"] = entry.Level.String()
 	event["message"] = entry.Message
 
-	h.eventCh <- event
-
-	return nil
-}
-
-func (h *Hook) run(ctx context.Context, closeCh chan struct{}) {
-	defer close(closeCh)
-
-	t := time.NewTicker(sendInterval)
-	defer t.Stop()
-
-	events := make([]axiom.Event, 0, batchSize)
-
-	defer func() {
-		flushCtx, flushCancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer flushCancel()
-		h.ingest(flushCtx, events)
-	}()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-t.C:
-			if len(events) == 0 {
-				continue
-			}
-		case event, ok := <-h.eventCh:
-			if !ok {
-				continue
-			}
-
-			events = append(events, event)
-
-			if len(events) < batchSize {
-				continue
-			}
+	if h.processor != nil {
+		if event = h.processor.Process(event); event == nil {
+			return nil
 		}
-
-		h.ingest(ctx, events)
-
-		// Clear batch buffer.
-		events = make([]axiom.Event, 0, batchSize)
 	}
-}
 
-func (h *Hook) ingest(ctx context.Context, events []axiom.Event) {
-	if len(events) == 0 {
-		return
-	}
+	return h.ingester.Ingest(context.Background(), event)
+}
 
-	res, err := h.client.Datasets.IngestEvents(ctx, h.datasetName, events, h.ingestOptions...)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to ingest batch of %d events: %s\n", len(events), err)
-	} else if res.Failed > 0 {
-		// Best effort on notifying the user about the ingest failure.
-		fmt.Fprintf(os.Stderr, "event at %s failed to ingest: %s\n",
-			res.Failures[0].Timestamp, res.Failures[0].Error)
-	}
+// reportFailedEvents notifies the user about events which failed to be
+// ingested, on a best effort basis.
+func reportFailedEvents(events []axiom.FailedEvent) {
+	fmt.Fprintf(os.Stderr, "%d events failed to ingest: %s\n", len(events), events[0].Error)
 }
@@ -2,10 +2,12 @@ package logrus
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -16,6 +18,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/axiomhq/axiom-go/axiom/ingest"
 	"github.com/axiomhq/axiom-go/internal/test/adapters"
 	"github.com/axiomhq/axiom-go/internal/test/testhelper"
 )
@@ -131,3 +134,49 @@ func setup(t *testing.T) func(dataset string, client *axiom.Client) *logrus.Logg
 		return logger
 	}
 }
+
+func TestHook_Processor(t *testing.T) {
+	datasets := new(recordingIngester)
+
+	ing, err := axiom.NewIngester(datasets, "test", axiom.IngesterConfig{})
+	require.NoError(t, err)
+
+	hook := &Hook{
+		processor: ingest.Pipeline{
+			ingest.Filter(func(event map[string]any) bool { return event["message"] != "drop me" }),
+			ingest.AddFields(map[string]any{"service": "api"}),
+			ingest.DropFields("password"),
+		},
+		ingester: ing,
+	}
+
+	logger := logrus.New()
+	for _, msg := range []string{"keep me", "drop me"} {
+		entry := logger.WithField("password", "secret")
+		entry.Message = msg
+		require.NoError(t, hook.Fire(entry))
+	}
+	hook.Close()
+
+	events := datasets.events
+	require.Len(t, events, 1)
+
+	assert.Equal(t, "keep me", events[0]["message"])
+	assert.Equal(t, "api", events[0]["service"])
+	assert.NotContains(t, events[0], "password")
+}
+
+// recordingIngester is an `axiom.EventIngester` which records the events it is
+// passed.
+type recordingIngester struct {
+	events []axiom.Event
+	mtx    sync.Mutex
+}
+
+func (r *recordingIngester) IngestEvents(_ context.Context, _ string, events []axiom.Event, _ ...ingest.Option) (*ingest.Status, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.events = append(r.events, events...)
+	return &ingest.Status{Ingested: uint64(len(events))}, nil
+}
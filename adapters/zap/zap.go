@@ -3,10 +3,10 @@ package zap
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
-	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -69,6 +69,18 @@ func SetDataset(datasetName string) Option {
 	}
 }
 
+// SetSpool makes the WriteSyncer persist batches of logs which fail to be
+// ingested because Axiom is unreachable or a limit is exceeded to the directory
+// given in the `axiom.SpoolConfig`, instead of dropping them. They are replayed
+// in order with the next logs or, if there are none, after a second, even after
+// a restart. Persisted logs are not reported as failed. See `axiom.Spool`.
+func SetSpool(config axiom.SpoolConfig) Option {
+	return func(ws *WriteSyncer) error {
+		ws.spoolConfig = &config
+		return nil
+	}
+}
+
 // SetIngestOptions specifies the ingestion options to use for ingesting the
 // logs.
 func SetIngestOptions(opts ...ingest.Option) Option {
@@ -78,6 +90,16 @@ func SetIngestOptions(opts ...ingest.Option) Option {
 	}
 }
 
+// SetProcessor specifies a processor, e.g. an `ingest.Pipeline`, which
+// transforms the JSON encoded log entries before they are buffered for
+// ingestion. Entries it drops are discarded.
+func SetProcessor(processor ingest.Processor) Option {
+	return func(ws *WriteSyncer) error {
+		ws.processor = processor
+		return nil
+	}
+}
+
 // SetLevelEnabler sets the level enabler that the Axiom WriteSyncer will us to
 // determine if logs will be shipped to Axiom.
 func SetLevelEnabler(levelEnabler zapcore.LevelEnabler) Option {
@@ -88,17 +110,20 @@ func SetLevelEnabler(levelEnabler zapcore.LevelEnabler) Option {
 }
 
 // WriteSyncer implements a `zapcore.WriteSyncer` used for shipping logs to
-// Axiom.
+// Axiom. Log entries are batched and sent in the background, like by the other
+// adapters. `Sync` waits for all entries written before to be sent.
 type WriteSyncer struct {
 	client      *axiom.Client
 	datasetName string
 
 	clientOptions []axiom.Option
 	ingestOptions []ingest.Option
+	processor     ingest.Processor
+	spoolConfig   *axiom.SpoolConfig
+	spool         *axiom.Spool
 	levelEnabler  zapcore.LevelEnabler
 
-	buf    bytes.Buffer
-	bufMtx sync.Mutex
+	ingester *axiom.Ingester
 }
 
 // New creates a new `zapcore.Core` configured to ingest logs to the Axiom
@@ -133,6 +158,14 @@ func New(options ...Option) (zapcore.Core, error) {
 		}
 	}
 
+	// Create spool, if configured.
+	if ws.spoolConfig != nil {
+		var err error
+		if ws.spool, err = axiom.NewSpool(ws.client.Datasets, *ws.spoolConfig); err != nil {
+			return nil, err
+		}
+	}
+
 	// When the dataset name is not set, use `AXIOM_DATASET`.
 	if ws.datasetName == "" {
 		if ws.datasetName = os.Getenv("AXIOM_DATASET"); ws.datasetName == "" {
@@ -140,6 +173,21 @@ func New(options ...Option) (zapcore.Core, error) {
 		}
 	}
 
+	// Batch and send events in the background, through the spool, if
+	// configured.
+	var datasets axiom.EventIngester = ws.client.Datasets
+	if ws.spool != nil {
+		datasets = ws.spool
+	}
+
+	var err error
+	if ws.ingester, err = axiom.NewIngester(datasets, ws.datasetName, axiom.IngesterConfig{
+		IngestOptions:  ws.ingestOptions,
+		OnFailedEvents: reportFailedEvents,
+	}); err != nil {
+		return nil, err
+	}
+
 	enc := zapcore.NewJSONEncoder(encoderConfig)
 
 	return zapcore.NewCore(enc, ws, ws.levelEnabler), nil
@@ -147,10 +195,27 @@ func New(options ...Option) (zapcore.Core, error) {
 
 // Write implements `zapcore.WriteSyncer`.
 func (ws *WriteSyncer) Write(p []byte) (n int, err error) {
-	ws.bufMtx.Lock()
-	defer ws.bufMtx.Unlock()
+	// Decode the entries to pass them on as events. Numbers are kept as is to
+	// not lose precision.
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+
+	for dec.More() {
+		var event axiom.Event
+		if err = dec.Decode(&event); err != nil {
+			return 0, err
+		}
+		if ws.processor != nil {
+			if event = ws.processor.Process(event); event == nil {
+				continue
+			}
+		}
+		if err = ws.ingester.Ingest(context.Background(), event); err != nil {
+			return 0, err
+		}
+	}
 
-	return ws.buf.Write(p)
+	return len(p), nil
 }
 
 // Sync implements `zapcore.WriteSyncer`.
@@ -159,29 +224,11 @@ func (ws *WriteSyncer) Sync() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	ws.bufMtx.Lock()
-	defer ws.bufMtx.Unlock()
-
-	if ws.buf.Len() == 0 {
-		return nil
-	}
-
-	// Make sure to reset the buffer.
-	defer ws.buf.Reset()
-
-	r, err := axiom.ZstdEncoder(&ws.buf)
-	if err != nil {
-		return err
-	}
-
-	res, err := ws.client.Datasets.Ingest(ctx, ws.datasetName, r, axiom.NDJSON, axiom.Zstd, ws.ingestOptions...)
-	if err != nil {
-		return err
-	} else if res.Failed > 0 {
-		// Best effort on notifying the user about the ingest failure.
-		return fmt.Errorf("event at %s failed to ingest: %s",
-			res.Failures[0].Timestamp, res.Failures[0].Error)
-	}
+	return ws.ingester.Flush(ctx)
+}
 
-	return nil
+// reportFailedEvents notifies the user about events which failed to be
+// ingested, on a best effort basis.
+func reportFailedEvents(events []axiom.FailedEvent) {
+	fmt.Fprintf(os.Stderr, "%d events failed to ingest: %s\n", len(events), events[0].Error)
 }
@@ -1,10 +1,14 @@
 package zap
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -12,6 +16,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/axiomhq/axiom-go/axiom"
 	"github.com/axiomhq/axiom-go/axiom/ingest"
@@ -87,3 +92,52 @@ func TestCore(t *testing.T) {
 
 	assert.True(t, hasRun)
 }
+
+func TestCore_Processor(t *testing.T) {
+	datasets := new(recordingIngester)
+
+	ing, err := axiom.NewIngester(datasets, "test", axiom.IngesterConfig{})
+	require.NoError(t, err)
+	defer ing.Close(context.Background())
+
+	ws := &WriteSyncer{
+		processor: ingest.Pipeline{
+			ingest.Filter(func(event map[string]any) bool { return event["msg"] != "drop me" }),
+			ingest.AddFields(map[string]any{"service": "api"}),
+			ingest.DropFields("password"),
+		},
+		ingester: ing,
+	}
+
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), ws, zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	for _, msg := range []string{"keep me", "drop me"} {
+		logger.Info(msg, zap.String("password", "secret"), zap.Int64("big", math.MaxInt64))
+	}
+	require.NoError(t, logger.Sync())
+
+	events := datasets.events
+	require.Len(t, events, 1)
+
+	event := events[0]
+	assert.Equal(t, "keep me", event["msg"])
+	assert.Equal(t, "api", event["service"])
+	assert.Equal(t, json.Number("9223372036854775807"), event["big"])
+	assert.NotContains(t, event, "password")
+}
+
+// recordingIngester is an `axiom.EventIngester` which records the events it is
+// passed.
+type recordingIngester struct {
+	events []axiom.Event
+	mtx    sync.Mutex
+}
+
+func (r *recordingIngester) IngestEvents(_ context.Context, _ string, events []axiom.Event, _ ...ingest.Option) (*ingest.Status, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.events = append(r.events, events...)
+	return &ingest.Status{Ingested: uint64(len(events))}, nil
+}
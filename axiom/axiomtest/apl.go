@@ -0,0 +1,516 @@
+package axiomtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/axiomhq/axiom-go/axiom/querylegacy"
+)
+
+func (s *Server) queryAPL(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query     string    `json:"apl"`
+		StartTime time.Time `json:"startTime"`
+		EndTime   time.Time `json:"endTime"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, q, err := parseAPL(req.Query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q.StartTime, q.EndTime = req.StartTime, req.EndTime
+
+	res, qErr := s.query(id, q)
+	if qErr != nil {
+		writeError(w, qErr.code, qErr.msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+type tokenKind uint8
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// lexAPL splits the given APL query into tokens.
+func lexAPL(q string) ([]token, error) {
+	var (
+		toks []token
+		rs   = []rune(q)
+	)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '[' && i+1 < len(rs) && (rs[i+1] == '\'' || rs[i+1] == '"'):
+			// Bracketed identifier, e.g. ['my-dataset'].
+			s, n, err := lexString(rs[i+1:])
+			if err != nil {
+				return nil, err
+			} else if i+1+n >= len(rs) || rs[i+1+n] != ']' {
+				return nil, fmt.Errorf("unterminated bracketed identifier at position %d", i)
+			}
+			toks = append(toks, token{tokenIdent, s})
+			i += n + 2
+		case r == '\'' || r == '"':
+			s, n, err := lexString(rs[i:])
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{tokenString, s})
+			i += n
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			j := i + 1
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.' || rs[j] == 'e' || rs[j] == 'E') {
+				j++
+			}
+			toks = append(toks, token{tokenNumber, string(rs[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_' || r == '$' || (r == '!' && i+1 < len(rs) && unicode.IsLetter(rs[i+1])):
+			j := i + 1
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_' || rs[j] == '.' || rs[j] == '$') {
+				j++
+			}
+			toks = append(toks, token{tokenIdent, string(rs[i:j])})
+			i = j
+		default:
+			sym := string(r)
+			if i+1 < len(rs) {
+				if two := string(rs[i : i+2]); two == "==" || two == "!=" || two == "<=" || two == ">=" {
+					sym = two
+				}
+			}
+			if !strings.Contains("|(),=<>", sym) && len(sym) == 1 {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+			toks = append(toks, token{tokenSymbol, sym})
+			i += len([]rune(sym))
+		}
+	}
+	return append(toks, token{kind: tokenEOF}), nil
+}
+
+// lexString lexes a quoted string at the start of the given runes. It returns
+// the unquoted string and the amount of runes consumed.
+func lexString(rs []rune) (string, int, error) {
+	var (
+		quote = rs[0]
+		sb    strings.Builder
+	)
+	for i := 1; i < len(rs); i++ {
+		switch rs[i] {
+		case '\\':
+			if i+1 < len(rs) {
+				i++
+				sb.WriteRune(rs[i])
+			}
+		case quote:
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteRune(rs[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string %q", string(rs))
+}
+
+type aplParser struct {
+	toks []token
+	pos  int
+}
+
+// parseAPL parses the supported subset of APL into a legacy query on the
+// returned dataset.
+func parseAPL(apl string) (string, querylegacy.Query, error) {
+	toks, err := lexAPL(apl)
+	if err != nil {
+		return "", querylegacy.Query{}, err
+	}
+
+	p := &aplParser{toks: toks}
+	id, q, err := p.parse()
+	if err != nil {
+		return "", querylegacy.Query{}, fmt.Errorf("invalid or unsupported query: %w", err)
+	}
+	return id, q, nil
+}
+
+func (p *aplParser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *aplParser) next() token {
+	tok := p.toks[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is of the given kind and text.
+func (p *aplParser) accept(kind tokenKind, text string) bool {
+	if tok := p.peek(); tok.kind == kind && tok.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *aplParser) expect(kind tokenKind, text string) error {
+	if !p.accept(kind, text) {
+		return fmt.Errorf("expected %q but got %q", text, p.peek().text)
+	}
+	return nil
+}
+
+func (p *aplParser) ident() (string, error) {
+	tok := p.next()
+	if tok.kind != tokenIdent {
+		return "", fmt.Errorf("expected identifier but got %q", tok.text)
+	}
+	return tok.text, nil
+}
+
+// aplStages holds the position of the supported operators in the order they
+// are applied in by the server. Only where can occur more than once.
+var aplStages = map[string]int{
+	"where":     0,
+	"summarize": 1,
+	"count":     1,
+	"sort":      2,
+	"order":     2,
+	"take":      3,
+	"limit":     3,
+	"project":   4,
+}
+
+func (p *aplParser) parse() (string, querylegacy.Query, error) {
+	var q querylegacy.Query
+
+	id, err := p.ident()
+	if err != nil {
+		return "", q, err
+	}
+
+	var (
+		filters []querylegacy.Filter
+		stage   = -1
+		prev    string
+	)
+	for p.accept(tokenSymbol, "|") {
+		op, err := p.ident()
+		if err != nil {
+			return "", q, err
+		}
+
+		// The server doesn't run operators in the order of the pipeline, so
+		// reject pipelines whose result would differ. Sorting, limiting and
+		// projecting is not supported on aggregated results.
+		if next, ok := aplStages[op]; ok {
+			if next < stage || (next == stage && op != "where") || (stage == aplStages["summarize"] && next > stage) {
+				return "", q, fmt.Errorf("unsupported operator %q after %q", op, prev)
+			}
+			stage, prev = next, op
+		}
+
+		switch op {
+		case "where":
+			f, err := p.or()
+			if err != nil {
+				return "", q, err
+			}
+			filters = append(filters, f)
+		case "take", "limit":
+			tok := p.next()
+			n, err := strconv.ParseUint(tok.text, 10, 32)
+			if tok.kind != tokenNumber || err != nil {
+				return "", q, fmt.Errorf("invalid %s count %q", op, tok.text)
+			}
+			q.Limit = uint32(n)
+		case "project":
+			if q.Projections, err = p.projections(); err != nil {
+				return "", q, err
+			}
+		case "sort", "order":
+			if q.Order, err = p.order(); err != nil {
+				return "", q, err
+			}
+		case "count":
+			q.Aggregations = []querylegacy.Aggregation{{Op: querylegacy.OpCount}}
+		case "summarize":
+			if q.Aggregations, q.GroupBy, err = p.summarize(); err != nil {
+				return "", q, err
+			}
+		default:
+			return "", q, fmt.Errorf("unsupported operator %q", op)
+		}
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return "", q, fmt.Errorf("unexpected %q", tok.text)
+	}
+
+	switch len(filters) {
+	case 0:
+	case 1:
+		q.Filter = filters[0]
+	default:
+		q.Filter = querylegacy.Filter{Op: querylegacy.OpAnd, Children: filters}
+	}
+
+	return id, q, nil
+}
+
+func (p *aplParser) or() (querylegacy.Filter, error) {
+	f, err := p.and()
+	if err != nil {
+		return f, err
+	}
+	for p.accept(tokenIdent, "or") {
+		g, err := p.and()
+		if err != nil {
+			return f, err
+		}
+		f = querylegacy.Filter{Op: querylegacy.OpOr, Children: []querylegacy.Filter{f, g}}
+	}
+	return f, nil
+}
+
+func (p *aplParser) and() (querylegacy.Filter, error) {
+	f, err := p.unary()
+	if err != nil {
+		return f, err
+	}
+	for p.accept(tokenIdent, "and") {
+		g, err := p.unary()
+		if err != nil {
+			return f, err
+		}
+		f = querylegacy.Filter{Op: querylegacy.OpAnd, Children: []querylegacy.Filter{f, g}}
+	}
+	return f, nil
+}
+
+func (p *aplParser) unary() (querylegacy.Filter, error) {
+	if p.accept(tokenIdent, "not") {
+		f, err := p.unary()
+		if err != nil {
+			return f, err
+		}
+		return querylegacy.Filter{Op: querylegacy.OpNot, Children: []querylegacy.Filter{f}}, nil
+	}
+
+	if p.accept(tokenSymbol, "(") {
+		f, err := p.or()
+		if err != nil {
+			return f, err
+		}
+		return f, p.expect(tokenSymbol, ")")
+	}
+
+	return p.comparison()
+}
+
+var aplFilterOps = map[string]querylegacy.FilterOp{
+	"==":          querylegacy.OpEqual,
+	"!=":          querylegacy.OpNotEqual,
+	"<":           querylegacy.OpLessThan,
+	"<=":          querylegacy.OpLessThanEqual,
+	">":           querylegacy.OpGreaterThan,
+	">=":          querylegacy.OpGreaterThanEqual,
+	"contains":    querylegacy.OpContains,
+	"!contains":   querylegacy.OpNotContains,
+	"startswith":  querylegacy.OpStartsWith,
+	"!startswith": querylegacy.OpNotStartsWith,
+	"endswith":    querylegacy.OpEndsWith,
+	"!endswith":   querylegacy.OpNotEndsWith,
+}
+
+func (p *aplParser) comparison() (querylegacy.Filter, error) {
+	var f querylegacy.Filter
+
+	name, err := p.ident()
+	if err != nil {
+		return f, err
+	}
+	f.Field = name
+
+	tok := p.next()
+	switch {
+	case tok.kind == tokenIdent && tok.text == "matches":
+		if err = p.expect(tokenIdent, "regex"); err != nil {
+			return f, err
+		}
+		f.Op, f.CaseSensitive = querylegacy.OpRegexp, true
+	case tok.kind == tokenSymbol || tok.kind == tokenIdent:
+		// String operators are case-insensitive, unless suffixed with "_cs".
+		opText := strings.TrimSuffix(tok.text, "_cs")
+		op, ok := aplFilterOps[opText]
+		if !ok {
+			return f, fmt.Errorf("unsupported comparison %q", tok.text)
+		}
+		f.Op, f.CaseSensitive = op, tok.kind == tokenSymbol || opText != tok.text
+	default:
+		return f, fmt.Errorf("expected comparison but got %q", tok.text)
+	}
+
+	f.Value, err = p.literal()
+	return f, err
+}
+
+func (p *aplParser) literal() (any, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenString:
+		return tok.text, nil
+	case tokenNumber:
+		return strconv.ParseFloat(tok.text, 64)
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return nil, fmt.Errorf("expected literal but got %q", tok.text)
+}
+
+func (p *aplParser) projections() ([]querylegacy.Projection, error) {
+	var res []querylegacy.Projection
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+
+		proj := querylegacy.Projection{Field: name}
+		if p.accept(tokenSymbol, "=") {
+			if proj.Field, err = p.ident(); err != nil {
+				return nil, err
+			}
+			proj.Alias = name
+		}
+		res = append(res, proj)
+
+		if !p.accept(tokenSymbol, ",") {
+			return res, nil
+		}
+	}
+}
+
+func (p *aplParser) order() ([]querylegacy.Order, error) {
+	if err := p.expect(tokenIdent, "by"); err != nil {
+		return nil, err
+	}
+
+	var res []querylegacy.Order
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+
+		// Sorting is descending by default.
+		order := querylegacy.Order{Field: name, Desc: true}
+		if p.accept(tokenIdent, "asc") {
+			order.Desc = false
+		} else {
+			p.accept(tokenIdent, "desc")
+		}
+		res = append(res, order)
+
+		if !p.accept(tokenSymbol, ",") {
+			return res, nil
+		}
+	}
+}
+
+var aplAggregationOps = map[string]querylegacy.AggregationOp{
+	"count":  querylegacy.OpCount,
+	"dcount": querylegacy.OpDistinct,
+	"sum":    querylegacy.OpSum,
+	"avg":    querylegacy.OpAvg,
+	"min":    querylegacy.OpMin,
+	"max":    querylegacy.OpMax,
+}
+
+func (p *aplParser) summarize() ([]querylegacy.Aggregation, []string, error) {
+	var aggs []querylegacy.Aggregation
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var agg querylegacy.Aggregation
+		if p.accept(tokenSymbol, "=") {
+			agg.Alias = name
+			if name, err = p.ident(); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		op, ok := aplAggregationOps[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unsupported aggregation %q", name)
+		}
+		agg.Op = op
+
+		if err = p.expect(tokenSymbol, "("); err != nil {
+			return nil, nil, err
+		}
+		if op != querylegacy.OpCount {
+			if agg.Field, err = p.ident(); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err = p.expect(tokenSymbol, ")"); err != nil {
+			return nil, nil, err
+		}
+		aggs = append(aggs, agg)
+
+		if !p.accept(tokenSymbol, ",") {
+			break
+		}
+	}
+
+	var groupBy []string
+	if p.accept(tokenIdent, "by") {
+		for {
+			name, err := p.ident()
+			if err != nil {
+				return nil, nil, err
+			}
+			groupBy = append(groupBy, name)
+
+			if !p.accept(tokenSymbol, ",") {
+				break
+			}
+		}
+	}
+
+	return aggs, groupBy, nil
+}
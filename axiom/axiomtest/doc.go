@@ -0,0 +1,25 @@
+// Package axiomtest provides an in-memory stand-in for the Axiom API to be used
+// in tests. It allows testing code that uses an `*axiom.Client` end to end
+// without network access or credentials:
+//
+//	srv := axiomtest.NewServer()
+//	defer srv.Close()
+//
+//	client, err := srv.Client()
+//	if err != nil {
+//		// Handle error.
+//	}
+//
+// The server implements the dataset operations, including ingestion of all
+// supported content types and encodings, trimming and querying. Legacy queries
+// are supported for the most common filters and aggregations. APL queries are
+// supported for a basic subset of the language, see `Server` for details.
+//
+// The server is not a reference implementation of the Axiom API. It mimics its
+// behaviour closely enough to test clients of it, but doesn't strive to be
+// complete.
+//
+// For unit tests that don't need a server at all, the package also provides
+// the fakes `Datasets`, `Organizations` and `Users`, which implement the
+// interfaces of the corresponding services of package axiom.
+package axiomtest
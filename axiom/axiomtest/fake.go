@@ -0,0 +1,174 @@
+package axiomtest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+	"github.com/axiomhq/axiom-go/axiom/query"
+	"github.com/axiomhq/axiom-go/axiom/querylegacy"
+)
+
+// ErrNotImplemented is returned by the methods of the fakes in this package
+// which function isn't set.
+var ErrNotImplemented = errors.New("method not implemented by fake")
+
+var (
+	_ axiom.DatasetsAPI      = (*Datasets)(nil)
+	_ axiom.OrganizationsAPI = (*Organizations)(nil)
+	_ axiom.UsersAPI         = (*Users)(nil)
+)
+
+// Datasets is a fake implementation of `axiom.DatasetsAPI`. Each method calls
+// the function of the same name, suffixed with "Func". If that function is not
+// set, the method returns `ErrNotImplemented`.
+type Datasets struct {
+	ListFunc          func(ctx context.Context) ([]*axiom.Dataset, error)
+	GetFunc           func(ctx context.Context, id string) (*axiom.Dataset, error)
+	CreateFunc        func(ctx context.Context, req axiom.DatasetCreateRequest) (*axiom.Dataset, error)
+	UpdateFunc        func(ctx context.Context, id string, req axiom.DatasetUpdateRequest) (*axiom.Dataset, error)
+	DeleteFunc        func(ctx context.Context, id string) error
+	TrimFunc          func(ctx context.Context, id string, maxDuration time.Duration) (*axiom.TrimResult, error)
+	IngestFunc        func(ctx context.Context, id string, r io.Reader, typ axiom.ContentType, enc axiom.ContentEncoding, options ...ingest.Option) (*ingest.Status, error)
+	IngestEventsFunc  func(ctx context.Context, id string, events []axiom.Event, options ...ingest.Option) (*ingest.Status, error)
+	IngestChannelFunc func(ctx context.Context, id string, events <-chan axiom.Event, options ...ingest.Option) (*ingest.Status, error)
+	IngestFileFunc    func(ctx context.Context, id string, r io.Reader, typ axiom.ContentType, options ...ingest.Option) (*ingest.Status, error)
+	QueryFunc         func(ctx context.Context, q query.Query, options ...query.Option) (*query.Result, error)
+	QueryLegacyFunc   func(ctx context.Context, id string, q querylegacy.Query, opts querylegacy.Options) (*querylegacy.Result, error)
+}
+
+// List implements `axiom.DatasetsAPI`.
+func (f *Datasets) List(ctx context.Context) ([]*axiom.Dataset, error) {
+	if f.ListFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return f.ListFunc(ctx)
+}
+
+// Get implements `axiom.DatasetsAPI`.
+func (f *Datasets) Get(ctx context.Context, id string) (*axiom.Dataset, error) {
+	if f.GetFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return f.GetFunc(ctx, id)
+}
+
+// Create implements `axiom.DatasetsAPI`.
+func (f *Datasets) Create(ctx context.Context, req axiom.DatasetCreateRequest) (*axiom.Dataset, error) {
+	if f.CreateFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return f.CreateFunc(ctx, req)
+}
+
+// Update implements `axiom.DatasetsAPI`.
+func (f *Datasets) Update(ctx context.Context, id string, req axiom.DatasetUpdateRequest) (*axiom.Dataset, error) {
+	if f.UpdateFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return f.UpdateFunc(ctx, id, req)
+}
+
+// Delete implements `axiom.DatasetsAPI`.
+func (f *Datasets) Delete(ctx context.Context, id string) error {
+	if f.DeleteFunc == nil {
+		return ErrNotImplemented
+	}
+	return f.DeleteFunc(ctx, id)
+}
+
+// Trim implements `axiom.DatasetsAPI`.
+func (f *Datasets) Trim(ctx context.Context, id string, maxDuration time.Duration) (*axiom.TrimResult, error) {
+	if f.TrimFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return f.TrimFunc(ctx, id, maxDuration)
+}
+
+// Ingest implements `axiom.DatasetsAPI`.
+func (f *Datasets) Ingest(ctx context.Context, id string, r io.Reader, typ axiom.ContentType, enc axiom.ContentEncoding, options ...ingest.Option) (*ingest.Status, error) {
+	if f.IngestFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return f.IngestFunc(ctx, id, r, typ, enc, options...)
+}
+
+// IngestEvents implements `axiom.DatasetsAPI`.
+func (f *Datasets) IngestEvents(ctx context.Context, id string, events []axiom.Event, options ...ingest.Option) (*ingest.Status, error) {
+	if f.IngestEventsFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return f.IngestEventsFunc(ctx, id, events, options...)
+}
+
+// IngestChannel implements `axiom.DatasetsAPI`.
+func (f *Datasets) IngestChannel(ctx context.Context, id string, events <-chan axiom.Event, options ...ingest.Option) (*ingest.Status, error) {
+	if f.IngestChannelFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return f.IngestChannelFunc(ctx, id, events, options...)
+}
+
+// IngestFile implements `axiom.DatasetsAPI`.
+func (f *Datasets) IngestFile(ctx context.Context, id string, r io.Reader, typ axiom.ContentType, options ...ingest.Option) (*ingest.Status, error) {
+	if f.IngestFileFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return f.IngestFileFunc(ctx, id, r, typ, options...)
+}
+
+// Query implements `axiom.DatasetsAPI`.
+func (f *Datasets) Query(ctx context.Context, q query.Query, options ...query.Option) (*query.Result, error) {
+	if f.QueryFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return f.QueryFunc(ctx, q, options...)
+}
+
+// QueryLegacy implements `axiom.DatasetsAPI`.
+func (f *Datasets) QueryLegacy(ctx context.Context, id string, q querylegacy.Query, opts querylegacy.Options) (*querylegacy.Result, error) {
+	if f.QueryLegacyFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return f.QueryLegacyFunc(ctx, id, q, opts)
+}
+
+// Organizations is a fake implementation of `axiom.OrganizationsAPI`. It
+// works like `Datasets`.
+type Organizations struct {
+	ListFunc func(ctx context.Context) ([]*axiom.Organization, error)
+	GetFunc  func(ctx context.Context, id string) (*axiom.Organization, error)
+}
+
+// List implements `axiom.OrganizationsAPI`.
+func (f *Organizations) List(ctx context.Context) ([]*axiom.Organization, error) {
+	if f.ListFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return f.ListFunc(ctx)
+}
+
+// Get implements `axiom.OrganizationsAPI`.
+func (f *Organizations) Get(ctx context.Context, id string) (*axiom.Organization, error) {
+	if f.GetFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return f.GetFunc(ctx, id)
+}
+
+// Users is a fake implementation of `axiom.UsersAPI`. It works like
+// `Datasets`.
+type Users struct {
+	CurrentFunc func(ctx context.Context) (*axiom.User, error)
+}
+
+// Current implements `axiom.UsersAPI`.
+func (f *Users) Current(ctx context.Context) (*axiom.User, error) {
+	if f.CurrentFunc == nil {
+		return nil, ErrNotImplemented
+	}
+	return f.CurrentFunc(ctx)
+}
@@ -0,0 +1,55 @@
+package axiomtest_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/axiomhq/axiom-go/axiom/axiomtest"
+)
+
+// datasetNames is an example for code depending on `axiom.DatasetsAPI`
+// instead of the concrete service.
+func datasetNames(ctx context.Context, datasets axiom.DatasetsAPI) ([]string, error) {
+	res, err := datasets.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(res))
+	for i, dataset := range res {
+		names[i] = dataset.Name
+	}
+	return names, nil
+}
+
+func TestDatasets(t *testing.T) {
+	fake := &axiomtest.Datasets{
+		ListFunc: func(context.Context) ([]*axiom.Dataset, error) {
+			return []*axiom.Dataset{{Name: "a"}, {Name: "b"}}, nil
+		},
+	}
+
+	names, err := datasetNames(context.Background(), fake)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, names)
+
+	_, err = fake.Get(context.Background(), "a")
+	assert.ErrorIs(t, err, axiomtest.ErrNotImplemented)
+}
+
+func TestUsers(t *testing.T) {
+	fake := &axiomtest.Users{
+		CurrentFunc: func(context.Context) (*axiom.User, error) {
+			return nil, axiom.ErrUnauthenticated
+		},
+	}
+
+	_, err := fake.Current(context.Background())
+	assert.ErrorIs(t, err, axiom.ErrUnauthenticated)
+
+	_, err = (&axiomtest.Organizations{}).List(context.Background())
+	assert.ErrorIs(t, err, axiomtest.ErrNotImplemented)
+}
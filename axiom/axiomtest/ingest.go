@@ -0,0 +1,273 @@
+package axiomtest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+	"github.com/axiomhq/axiom-go/axiom/querylegacy"
+)
+
+func (s *Server) ingest(w http.ResponseWriter, r *http.Request, id string) {
+	s.mtx.RLock()
+	_, ok := s.datasets[id]
+	s.mtx.RUnlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "dataset not found")
+		return
+	}
+
+	body, err := decodeContentEncoding(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer body.Close()
+
+	cr := &countingReader{Reader: body}
+	events, err := decodeEvents(r, cr)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var (
+		timestampField  = r.URL.Query().Get("timestamp-field")
+		timestampFormat = r.URL.Query().Get("timestamp-format")
+		now             = time.Now().UTC()
+	)
+	if timestampField == "" {
+		timestampField = ingest.TimestampField
+	}
+
+	status := ingest.Status{
+		Failures:       []*ingest.Failure{},
+		ProcessedBytes: cr.n,
+	}
+	entries := make([]querylegacy.Entry, 0, len(events))
+	for _, event := range events {
+		ts, err := eventTime(event, timestampField, timestampFormat, now)
+		if err == nil && s.validateEvent != nil {
+			err = s.validateEvent(event)
+		}
+		if err != nil {
+			status.Failed++
+			status.Failures = append(status.Failures, &ingest.Failure{
+				Timestamp: ts,
+				Error:     err.Error(),
+			})
+			continue
+		}
+
+		// The time of an event is never stored as part of its data, but the
+		// value of a custom timestamp field is.
+		delete(event, ingest.TimestampField)
+
+		entries = append(entries, querylegacy.Entry{
+			Time:    ts,
+			SysTime: now,
+			Data:    event,
+		})
+		status.Ingested++
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// The dataset might have been deleted while the events were decoded.
+	ds, ok := s.datasets[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "dataset not found")
+		return
+	}
+
+	for i := range entries {
+		s.rowID++
+		entries[i].RowID = strconv.FormatUint(s.rowID, 10)
+	}
+	ds.events = append(ds.events, entries...)
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// decodeContentEncoding returns the body of the request, decoded according to
+// its content encoding.
+func decodeContentEncoding(r *http.Request) (io.ReadCloser, error) {
+	switch enc := r.Header.Get("Content-Encoding"); enc {
+	case "", axiom.Identity.String():
+		return r.Body, nil
+	case axiom.Gzip.String():
+		return gzip.NewReader(r.Body)
+	case axiom.Zstd.String():
+		dec, err := zstd.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", enc)
+	}
+}
+
+// decodeEvents decodes the events read from the given reader according to the
+// content type of the request.
+func decodeEvents(r *http.Request, rd io.Reader) ([]axiom.Event, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid content type: %w", err)
+	}
+
+	switch mediaType {
+	case axiom.JSON.String():
+		return decodeJSON(rd)
+	case axiom.NDJSON.String():
+		return decodeNDJSON(rd)
+	case axiom.CSV.String():
+		return decodeCSV(rd, r.URL.Query().Get("csv-delimiter"))
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+}
+
+// decodeJSON decodes a single JSON object or an array of JSON objects.
+func decodeJSON(r io.Reader) ([]axiom.Event, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	if len(raw) > 0 && raw[0] == '[' {
+		var events []axiom.Event
+		if err := json.Unmarshal(raw, &events); err != nil {
+			return nil, err
+		}
+		return events, nil
+	}
+
+	var event axiom.Event
+	if err := json.Unmarshal(raw, &event); err != nil {
+		return nil, err
+	}
+	return []axiom.Event{event}, nil
+}
+
+// decodeNDJSON decodes newline delimited JSON objects.
+func decodeNDJSON(r io.Reader) ([]axiom.Event, error) {
+	dec := json.NewDecoder(r)
+
+	var events []axiom.Event
+	for {
+		var event axiom.Event
+		if err := dec.Decode(&event); errors.Is(err, io.EOF) {
+			return events, nil
+		} else if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+}
+
+// decodeCSV decodes CSV records into events, using the first record as the
+// header. Values that look like numbers are stored as such.
+func decodeCSV(r io.Reader, delimiter string) ([]axiom.Event, error) {
+	cr := csv.NewReader(r)
+	if delimiter != "" {
+		if len([]rune(delimiter)) != 1 {
+			return nil, fmt.Errorf("invalid csv delimiter %q", delimiter)
+		}
+		cr.Comma = []rune(delimiter)[0]
+	}
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var events []axiom.Event
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		event := make(axiom.Event, len(header))
+		for i, field := range header {
+			if f, err := strconv.ParseFloat(record[i], 64); err == nil {
+				event[field] = f
+			} else {
+				event[field] = record[i]
+			}
+		}
+		events = append(events, event)
+	}
+}
+
+// eventTime returns the time of the event as specified by its timestamp field.
+// If the event doesn't have a timestamp, the given time is returned.
+func eventTime(event axiom.Event, field, format string, now time.Time) (time.Time, error) {
+	v, ok := event[field]
+	if !ok || v == nil {
+		return now, nil
+	}
+
+	switch v := v.(type) {
+	case string:
+		if format == "" {
+			format = time.RFC3339Nano
+		}
+		ts, err := time.Parse(format, v)
+		if err != nil {
+			return now, fmt.Errorf("invalid timestamp in field %q: %w", field, err)
+		}
+		return ts.UTC(), nil
+	case float64:
+		return unixTime(v), nil
+	case time.Time:
+		return v.UTC(), nil
+	default:
+		return now, fmt.Errorf("invalid timestamp in field %q: unsupported type %T", field, v)
+	}
+}
+
+// unixTime interprets the given number as a unix timestamp and guesses its
+// precision by its magnitude.
+func unixTime(f float64) time.Time {
+	switch {
+	case f < 1e11:
+		return time.Unix(0, int64(f*float64(time.Second))).UTC()
+	case f < 1e14:
+		return time.Unix(0, int64(f*float64(time.Millisecond))).UTC()
+	case f < 1e17:
+		return time.Unix(0, int64(f*float64(time.Microsecond))).UTC()
+	default:
+		return time.Unix(0, int64(f)).UTC()
+	}
+}
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	io.Reader
+
+	n uint64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += uint64(n)
+	return n, err
+}
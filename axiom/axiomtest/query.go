@@ -0,0 +1,474 @@
+package axiomtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom/querylegacy"
+)
+
+func (s *Server) queryLegacy(w http.ResponseWriter, r *http.Request, id string) {
+	var q querylegacy.Query
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res, err := s.query(id, q)
+	if err != nil {
+		writeError(w, err.code, err.msg)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// queryError is an error that occurred while executing a query.
+type queryError struct {
+	code int
+	msg  string
+}
+
+func newQueryError(format string, a ...any) *queryError {
+	return &queryError{
+		code: http.StatusBadRequest,
+		msg:  fmt.Sprintf(format, a...),
+	}
+}
+
+// query executes the given legacy query on the dataset identified by its id.
+func (s *Server) query(id string, q querylegacy.Query) (*querylegacy.Result, *queryError) {
+	start := time.Now()
+
+	if len(q.VirtualFields) > 0 {
+		return nil, newQueryError("virtual fields are not supported")
+	}
+
+	s.mtx.RLock()
+	ds, ok := s.datasets[id]
+	if !ok {
+		s.mtx.RUnlock()
+		return nil, &queryError{http.StatusNotFound, "dataset not found"}
+	}
+	entries := make([]querylegacy.Entry, len(ds.events))
+	copy(entries, ds.events)
+	s.mtx.RUnlock()
+
+	var (
+		res     querylegacy.Result
+		matches = make([]querylegacy.Entry, 0)
+	)
+	for _, entry := range entries {
+		if !q.StartTime.IsZero() && entry.Time.Before(q.StartTime) {
+			continue
+		} else if !q.EndTime.IsZero() && !entry.Time.Before(q.EndTime) {
+			continue
+		}
+		res.Status.RowsExamined++
+
+		ok, err := matchFilter(q.Filter, entry)
+		if err != nil {
+			return nil, err
+		} else if ok {
+			matches = append(matches, entry)
+		}
+	}
+	res.Status.RowsMatched = uint64(len(matches))
+
+	if len(q.Aggregations) > 0 {
+		groups, err := aggregate(matches, q.Aggregations, q.GroupBy)
+		if err != nil {
+			return nil, err
+		}
+		res.Buckets.Totals = groups
+		res.Status.NumGroups = uint32(len(groups))
+		res.Matches = []querylegacy.Entry{}
+	} else {
+		sortEntries(matches, q.Order)
+		if q.Limit > 0 && uint32(len(matches)) > q.Limit {
+			matches = matches[:q.Limit]
+		}
+		res.Matches = project(matches, q.Projections)
+	}
+
+	res.Status.ElapsedTime = time.Since(start)
+
+	return &res, nil
+}
+
+// field returns the value of the given field of the entry. Nested fields are
+// addressed by joining their names with a dot.
+func field(entry querylegacy.Entry, name string) (any, bool) {
+	switch name {
+	case "_time":
+		return entry.Time, true
+	case "_sysTime":
+		return entry.SysTime, true
+	}
+
+	if v, ok := entry.Data[name]; ok {
+		return v, true
+	}
+
+	var (
+		v  any = entry.Data
+		ok bool
+	)
+	for _, part := range strings.Split(name, ".") {
+		m, isMap := v.(map[string]any)
+		if !isMap {
+			return nil, false
+		}
+		if v, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// matchFilter returns true if the entry matches the given filter.
+func matchFilter(f querylegacy.Filter, entry querylegacy.Entry) (bool, *queryError) {
+	switch f.Op {
+	case querylegacy.OpAnd, querylegacy.OpOr:
+		for _, child := range f.Children {
+			ok, err := matchFilter(child, entry)
+			if err != nil {
+				return false, err
+			} else if ok == (f.Op == querylegacy.OpOr) {
+				return ok, nil
+			}
+		}
+		return f.Op == querylegacy.OpAnd, nil
+	case querylegacy.OpNot:
+		if len(f.Children) != 1 {
+			return false, newQueryError("filter %q requires exactly one child", f.Op)
+		}
+		ok, err := matchFilter(f.Children[0], entry)
+		return !ok, err
+	}
+
+	if f.Op == 0 {
+		return true, nil
+	}
+
+	v, exists := field(entry, f.Field)
+
+	switch f.Op {
+	case querylegacy.OpExists:
+		return exists, nil
+	case querylegacy.OpNotExists:
+		return !exists, nil
+	case querylegacy.OpEqual:
+		return exists && compare(v, f.Value) == 0, nil
+	case querylegacy.OpNotEqual:
+		return !exists || compare(v, f.Value) != 0, nil
+	case querylegacy.OpGreaterThan:
+		return exists && compare(v, f.Value) > 0, nil
+	case querylegacy.OpGreaterThanEqual:
+		return exists && compare(v, f.Value) >= 0, nil
+	case querylegacy.OpLessThan:
+		return exists && compare(v, f.Value) < 0, nil
+	case querylegacy.OpLessThanEqual:
+		return exists && compare(v, f.Value) <= 0, nil
+	}
+
+	var (
+		str  = toString(v)
+		pred = toString(f.Value)
+	)
+	if !f.CaseSensitive {
+		str, pred = strings.ToLower(str), strings.ToLower(pred)
+	}
+
+	switch f.Op {
+	case querylegacy.OpStartsWith:
+		return exists && strings.HasPrefix(str, pred), nil
+	case querylegacy.OpNotStartsWith:
+		return !exists || !strings.HasPrefix(str, pred), nil
+	case querylegacy.OpEndsWith:
+		return exists && strings.HasSuffix(str, pred), nil
+	case querylegacy.OpNotEndsWith:
+		return !exists || !strings.HasSuffix(str, pred), nil
+	case querylegacy.OpContains:
+		return exists && contains(v, str, pred, f.CaseSensitive), nil
+	case querylegacy.OpNotContains:
+		return !exists || !contains(v, str, pred, f.CaseSensitive), nil
+	case querylegacy.OpRegexp, querylegacy.OpNotRegexp:
+		expr := toString(f.Value)
+		if !f.CaseSensitive {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return false, newQueryError("invalid regular expression %q: %s", f.Value, err)
+		}
+		if f.Op == querylegacy.OpRegexp {
+			return exists && re.MatchString(toString(v)), nil
+		}
+		return !exists || !re.MatchString(toString(v)), nil
+	}
+
+	return false, newQueryError("unsupported filter %q", f.Op)
+}
+
+// contains returns true if the given value contains the predicate. Arrays
+// contain the predicate if one of their elements equals it, strings if the
+// predicate is a substring.
+func contains(v any, str, pred string, caseSensitive bool) bool {
+	arr, ok := v.([]any)
+	if !ok {
+		return strings.Contains(str, pred)
+	}
+	for _, elem := range arr {
+		s := toString(elem)
+		if !caseSensitive {
+			s = strings.ToLower(s)
+		}
+		if s == pred {
+			return true
+		}
+	}
+	return false
+}
+
+// compare compares the two values. Numbers are compared numerically, times
+// chronologically and everything else by its string representation.
+func compare(a, b any) int {
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			}
+			return 0
+		}
+	}
+	if ta, ok := toTime(a); ok {
+		if tb, ok := toTime(b); ok {
+			switch {
+			case ta.Before(tb):
+				return -1
+			case ta.After(tb):
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(toString(a), toString(b))
+}
+
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toTime(v any) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+func toString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// sortEntries sorts the entries by the given order. Without an order, entries
+// are sorted by time, newest first.
+func sortEntries(entries []querylegacy.Entry, order []querylegacy.Order) {
+	if len(order) == 0 {
+		order = []querylegacy.Order{{Field: "_time", Desc: true}}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		for _, o := range order {
+			a, _ := field(entries[i], o.Field)
+			b, _ := field(entries[j], o.Field)
+			if c := compare(a, b); c != 0 {
+				return (c < 0) != o.Desc
+			}
+		}
+		return false
+	})
+}
+
+// project reduces the data of the entries to the given projections.
+func project(entries []querylegacy.Entry, projections []querylegacy.Projection) []querylegacy.Entry {
+	if len(projections) == 0 {
+		return entries
+	}
+
+	res := make([]querylegacy.Entry, len(entries))
+	for i, entry := range entries {
+		data := make(map[string]any, len(projections))
+		for _, p := range projections {
+			if v, ok := field(entry, p.Field); ok {
+				name := p.Field
+				if p.Alias != "" {
+					name = p.Alias
+				}
+				data[name] = v
+			}
+		}
+		entry.Data = data
+		res[i] = entry
+	}
+	return res
+}
+
+// aggregate groups the entries by the given fields and computes the given
+// aggregations for each group.
+func aggregate(entries []querylegacy.Entry, aggs []querylegacy.Aggregation, groupBy []string) ([]querylegacy.EntryGroup, *queryError) {
+	for _, agg := range aggs {
+		switch agg.Op {
+		case querylegacy.OpCount, querylegacy.OpDistinct, querylegacy.OpSum,
+			querylegacy.OpAvg, querylegacy.OpMin, querylegacy.OpMax:
+		default:
+			return nil, newQueryError("unsupported aggregation %q", agg.Op)
+		}
+	}
+
+	type group struct {
+		values  map[string]any
+		entries []querylegacy.Entry
+	}
+
+	var (
+		groups = make(map[string]*group)
+		keys   []string
+	)
+	for _, entry := range entries {
+		var (
+			values = make(map[string]any, len(groupBy))
+			key    strings.Builder
+		)
+		for _, name := range groupBy {
+			v, _ := field(entry, name)
+			values[name] = v
+			fmt.Fprintf(&key, "%q=%v;", name, v)
+		}
+
+		g, ok := groups[key.String()]
+		if !ok {
+			g = &group{values: values}
+			groups[key.String()] = g
+			keys = append(keys, key.String())
+		}
+		g.entries = append(g.entries, entry)
+	}
+
+	// Without grouping, there is always exactly one group, even if no entries
+	// matched.
+	if len(groupBy) == 0 && len(groups) == 0 {
+		groups[""] = &group{values: map[string]any{}}
+		keys = append(keys, "")
+	}
+
+	res := make([]querylegacy.EntryGroup, len(keys))
+	for i, key := range keys {
+		g := groups[key]
+		res[i] = querylegacy.EntryGroup{
+			ID:           uint64(i),
+			Group:        g.values,
+			Aggregations: make([]querylegacy.EntryGroupAgg, len(aggs)),
+		}
+		for j, agg := range aggs {
+			alias := agg.Alias
+			if alias == "" {
+				alias = agg.Op.String()
+			}
+			res[i].Aggregations[j] = querylegacy.EntryGroupAgg{
+				Alias: alias,
+				Value: computeAggregation(agg, g.entries),
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func computeAggregation(agg querylegacy.Aggregation, entries []querylegacy.Entry) any {
+	if agg.Op == querylegacy.OpCount {
+		return float64(len(entries))
+	}
+
+	var (
+		distinct = make(map[string]struct{})
+		sum      float64
+		n        float64
+		lo       = math.Inf(1)
+		hi       = math.Inf(-1)
+	)
+	for _, entry := range entries {
+		v, ok := field(entry, agg.Field)
+		if !ok {
+			continue
+		}
+		distinct[toString(v)] = struct{}{}
+
+		f, ok := toFloat(v)
+		if !ok {
+			continue
+		}
+		sum += f
+		n++
+		lo = math.Min(lo, f)
+		hi = math.Max(hi, f)
+	}
+
+	switch agg.Op {
+	case querylegacy.OpDistinct:
+		return float64(len(distinct))
+	case querylegacy.OpSum:
+		return sum
+	case querylegacy.OpAvg:
+		if n == 0 {
+			return nil
+		}
+		return sum / n
+	case querylegacy.OpMin:
+		if n == 0 {
+			return nil
+		}
+		return lo
+	case querylegacy.OpMax:
+		if n == 0 {
+			return nil
+		}
+		return hi
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package axiomtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/axiomhq/axiom-go/axiom/querylegacy"
+)
+
+const (
+	// AccessToken is the personal token used by clients returned by
+	// `Server.Client`. The server accepts any non-empty access token.
+	AccessToken = "xapt-00000000-0000-0000-0000-000000000000" //nolint:gosec // Chill, it's just testing.
+	// OrganizationID is the organization ID used by clients returned by
+	// `Server.Client`.
+	OrganizationID = "axiomtest"
+
+	basePath = "/api/v1/datasets"
+)
+
+// An Option modifies the behaviour of the test server.
+type Option func(s *Server)
+
+// SetEventValidator specifies a function every ingested event is passed to
+// before it is stored. Events for which it returns an error are not stored but
+// reported as failures on the `ingest.Status` returned to the client. Events
+// which timestamp can't be parsed are always reported as failures.
+func SetEventValidator(validator func(axiom.Event) error) Option {
+	return func(s *Server) { s.validateEvent = validator }
+}
+
+// Server is an in-memory stand-in for the Axiom API. It stores datasets and
+// their events in memory and answers requests sent by an `*axiom.Client`. It
+// is safe for concurrent use.
+//
+// APL queries sent to the server must start with the dataset to query, either
+// as `['dataset']` or `dataset`, optionally followed by any of the tabular
+// operators listed below. The operators must occur in the order they are listed
+// in and, except for where, at most once. Queries which summarize can't sort,
+// take or project afterwards:
+//
+//   - where <predicate>: Filters events by comparing fields to literals using
+//     ==, !=, <, <=, >, >=, contains, !contains, startswith, !startswith,
+//     endswith, !endswith and matches regex. Predicates can be combined using
+//     and, or, not and parentheses.
+//   - summarize <aggregation>, ... [by <field>, ...]: Aggregates events using
+//     count(), dcount(), sum(), avg(), min() and max().
+//   - count: Counts events. Short for `summarize count()`.
+//   - sort by <field> [asc|desc], ...: Sorts events. Also available as order.
+//   - take <n>: Limits the amount of events. Also available as limit.
+//   - project <field>, ...: Selects the fields of the events returned.
+type Server struct {
+	// URL of the server, e.g. "http://127.0.0.1:1234".
+	URL string
+
+	srv           *httptest.Server
+	validateEvent func(axiom.Event) error
+
+	datasets map[string]*dataset
+	rowID    uint64
+	mtx      sync.RWMutex
+}
+
+type dataset struct {
+	axiom.Dataset
+
+	events []querylegacy.Entry
+}
+
+// NewServer starts and returns a new test server. It must be closed by calling
+// `Server.Close` when done.
+func NewServer(options ...Option) *Server {
+	s := &Server{
+		datasets: make(map[string]*dataset),
+	}
+	for _, option := range options {
+		option(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/user", s.handleUser)
+	mux.HandleFunc(basePath, s.handleDatasets)
+	mux.HandleFunc(basePath+"/", s.handleDatasets)
+
+	s.srv = httptest.NewServer(requireAuth(mux))
+	s.URL = s.srv.URL
+
+	return s
+}
+
+// Close shuts down the server and blocks until all outstanding requests on it
+// have completed.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Client returns a new `*axiom.Client` configured to talk to the server. The
+// given options are applied after the ones configuring the client for the
+// server.
+func (s *Server) Client(options ...axiom.Option) (*axiom.Client, error) {
+	return axiom.NewClient(append([]axiom.Option{
+		axiom.SetNoEnv(),
+		axiom.SetURL(s.URL),
+		axiom.SetAccessToken(AccessToken),
+		axiom.SetOrganizationID(OrganizationID),
+		axiom.SetClient(s.srv.Client()),
+	}, options...)...)
+}
+
+// Events returns the events stored in the dataset identified by its id, oldest
+// first. The time of each event is set as its "_time" field. Returns nil if the
+// dataset doesn't exist.
+func (s *Server) Events(id string) []axiom.Event {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	ds, ok := s.datasets[id]
+	if !ok {
+		return nil
+	}
+
+	events := make([]axiom.Event, len(ds.events))
+	for i, entry := range ds.events {
+		event := make(axiom.Event, len(entry.Data)+1)
+		for k, v := range entry.Data {
+			event[k] = v
+		}
+		event["_time"] = entry.Time
+		events[i] = event
+	}
+
+	return events
+}
+
+func (s *Server) handleUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, axiom.User{
+		ID:   "axiomtest",
+		Name: "Axiom Test",
+	})
+}
+
+func (s *Server) handleDatasets(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, basePath), "/")
+	segments := strings.Split(path, "/")
+
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		s.listDatasets(w)
+	case path == "" && r.Method == http.MethodPost:
+		s.createDataset(w, r)
+	case path == "_apl" && r.Method == http.MethodPost:
+		s.queryAPL(w, r)
+	case len(segments) == 1 && r.Method == http.MethodGet:
+		s.getDataset(w, segments[0])
+	case len(segments) == 1 && r.Method == http.MethodPut:
+		s.updateDataset(w, r, segments[0])
+	case len(segments) == 1 && r.Method == http.MethodDelete:
+		s.deleteDataset(w, segments[0])
+	case len(segments) == 2 && segments[1] == "trim" && r.Method == http.MethodPost:
+		s.trimDataset(w, r, segments[0])
+	case len(segments) == 2 && segments[1] == "ingest" && r.Method == http.MethodPost:
+		s.ingest(w, r, segments[0])
+	case len(segments) == 2 && segments[1] == "query" && r.Method == http.MethodPost:
+		s.queryLegacy(w, r, segments[0])
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) listDatasets(w http.ResponseWriter) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	res := make([]axiom.Dataset, 0, len(s.datasets))
+	for _, ds := range s.datasets {
+		res = append(res, ds.Dataset)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) createDataset(w http.ResponseWriter, r *http.Request) {
+	var req axiom.DatasetCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "dataset name must not be empty")
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, ok := s.datasets[req.Name]; ok {
+		writeError(w, http.StatusConflict, "entity exists")
+		return
+	}
+
+	ds := &dataset{
+		Dataset: axiom.Dataset{
+			ID:          req.Name,
+			Name:        req.Name,
+			Description: req.Description,
+			CreatedBy:   "axiomtest",
+			CreatedAt:   time.Now().UTC(),
+		},
+	}
+	s.datasets[req.Name] = ds
+
+	writeJSON(w, http.StatusOK, ds.Dataset)
+}
+
+func (s *Server) getDataset(w http.ResponseWriter, id string) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	ds, ok := s.datasets[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "dataset not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ds.Dataset)
+}
+
+func (s *Server) updateDataset(w http.ResponseWriter, r *http.Request, id string) {
+	var req axiom.DatasetUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	ds, ok := s.datasets[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "dataset not found")
+		return
+	}
+	ds.Description = req.Description
+
+	writeJSON(w, http.StatusOK, ds.Dataset)
+}
+
+func (s *Server) deleteDataset(w http.ResponseWriter, id string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, ok := s.datasets[id]; !ok {
+		writeError(w, http.StatusNotFound, "dataset not found")
+		return
+	}
+	delete(s.datasets, id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) trimDataset(w http.ResponseWriter, r *http.Request, id string) {
+	var req struct {
+		MaxDuration string `json:"maxDuration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	maxDuration, err := time.ParseDuration(req.MaxDuration)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid max duration: %s", err))
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	ds, ok := s.datasets[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "dataset not found")
+		return
+	}
+
+	oldest := time.Now().Add(-maxDuration)
+	events := ds.events[:0]
+	for _, entry := range ds.events {
+		if !entry.Time.Before(oldest) {
+			events = append(events, entry)
+		}
+	}
+	ds.events = events
+
+	writeJSON(w, http.StatusOK, axiom.TrimResult{})
+}
+
+// requireAuth rejects all requests that don't carry an access token.
+func requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); token == "" {
+			writeError(w, http.StatusUnauthorized, "missing access token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, axiom.Error{Message: msg})
+}
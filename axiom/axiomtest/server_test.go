@@ -0,0 +1,273 @@
+package axiomtest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/axiomhq/axiom-go/axiom"
+	"github.com/axiomhq/axiom-go/axiom/axiomtest"
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+	"github.com/axiomhq/axiom-go/axiom/querylegacy"
+)
+
+func setup(t *testing.T, options ...axiomtest.Option) (*axiomtest.Server, *axiom.Client) {
+	t.Helper()
+
+	srv := axiomtest.NewServer(options...)
+	t.Cleanup(srv.Close)
+
+	client, err := srv.Client()
+	require.NoError(t, err)
+
+	_, err = client.Datasets.Create(context.Background(), axiom.DatasetCreateRequest{
+		Name: "test",
+	})
+	require.NoError(t, err)
+
+	return srv, client
+}
+
+func TestServer_Datasets(t *testing.T) {
+	_, client := setup(t)
+	ctx := context.Background()
+
+	require.NoError(t, client.ValidateCredentials(ctx))
+
+	_, err := client.Datasets.Create(ctx, axiom.DatasetCreateRequest{Name: "test"})
+	require.ErrorIs(t, err, axiom.ErrExists)
+
+	dataset, err := client.Datasets.Update(ctx, "test", axiom.DatasetUpdateRequest{
+		Description: "A test dataset",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "A test dataset", dataset.Description)
+
+	dataset, err = client.Datasets.Get(ctx, "test")
+	require.NoError(t, err)
+	assert.Equal(t, "test", dataset.ID)
+	assert.Equal(t, "A test dataset", dataset.Description)
+
+	datasets, err := client.Datasets.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, datasets, 1)
+
+	require.NoError(t, client.Datasets.Delete(ctx, "test"))
+
+	_, err = client.Datasets.Get(ctx, "test")
+	require.ErrorIs(t, err, axiom.ErrNotFound)
+}
+
+func TestServer_Ingest(t *testing.T) {
+	const (
+		jsonData   = `[{"a":1,"b":"x"},{"a":2,"b":"y"}]`
+		ndjsonData = "{\"a\":1,\"b\":\"x\"}\n{\"a\":2,\"b\":\"y\"}\n"
+		csvData    = "a;b\n1;x\n2;y\n"
+	)
+
+	tests := []struct {
+		typ  axiom.ContentType
+		data string
+	}{
+		{axiom.JSON, jsonData},
+		{axiom.NDJSON, ndjsonData},
+		{axiom.CSV, csvData},
+	}
+	for _, tt := range tests {
+		for _, enc := range []axiom.ContentEncoding{axiom.Identity, axiom.Gzip, axiom.Zstd} {
+			t.Run(tt.typ.String()+"/"+enc.String(), func(t *testing.T) {
+				srv, client := setup(t)
+
+				status, err := client.Datasets.Ingest(context.Background(), "test",
+					encode(t, enc, tt.data), tt.typ, enc, ingest.SetCSVDelimiter(";"))
+				require.NoError(t, err)
+
+				assert.EqualValues(t, 2, status.Ingested)
+				assert.Zero(t, status.Failed)
+				assert.EqualValues(t, len(tt.data), status.ProcessedBytes)
+
+				events := srv.Events("test")
+				require.Len(t, events, 2)
+				assert.EqualValues(t, 1, events[0]["a"])
+				assert.Equal(t, "y", events[1]["b"])
+			})
+		}
+	}
+}
+
+func TestServer_Ingest_Failures(t *testing.T) {
+	errInvalid := errors.New("invalid event")
+	srv, client := setup(t, axiomtest.SetEventValidator(func(event axiom.Event) error {
+		if event["invalid"] == true {
+			return errInvalid
+		}
+		return nil
+	}))
+
+	status, err := client.Datasets.IngestEvents(context.Background(), "test", []axiom.Event{
+		{"_time": "2022-01-01T00:00:00Z", "msg": "valid"},
+		{"_time": "yesterday", "msg": "bad timestamp"},
+		{"msg": "rejected", "invalid": true},
+	})
+	require.NoError(t, err)
+
+	assert.EqualValues(t, 1, status.Ingested)
+	assert.EqualValues(t, 2, status.Failed)
+	if assert.Len(t, status.Failures, 2) {
+		assert.Contains(t, status.Failures[0].Error, "invalid timestamp")
+		assert.Equal(t, errInvalid.Error(), status.Failures[1].Error)
+	}
+
+	events := srv.Events("test")
+	require.Len(t, events, 1)
+	assert.Equal(t, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), events[0]["_time"])
+
+	_, err = client.Datasets.IngestEvents(context.Background(), "unknown", []axiom.Event{{}})
+	require.ErrorIs(t, err, axiom.ErrNotFound)
+}
+
+func TestServer_Trim(t *testing.T) {
+	srv, client := setup(t)
+
+	_, err := client.Datasets.IngestEvents(context.Background(), "test", []axiom.Event{
+		{"_time": time.Now().Add(-time.Hour), "msg": "old"},
+		{"msg": "new"},
+	})
+	require.NoError(t, err)
+
+	_, err = client.Datasets.Trim(context.Background(), "test", time.Minute)
+	require.NoError(t, err)
+
+	events := srv.Events("test")
+	require.Len(t, events, 1)
+	assert.Equal(t, "new", events[0]["msg"])
+}
+
+func TestServer_QueryLegacy(t *testing.T) {
+	_, client := setup(t)
+	ingestTestEvents(t, client)
+
+	res, err := client.Datasets.QueryLegacy(context.Background(), "test", querylegacy.Query{
+		Filter: querylegacy.Filter{
+			Op:    querylegacy.OpGreaterThanEqual,
+			Field: "status",
+			Value: 400,
+		},
+		Order: []querylegacy.Order{{Field: "duration"}},
+	}, querylegacy.Options{})
+	require.NoError(t, err)
+
+	assert.EqualValues(t, 4, res.Status.RowsExamined)
+	assert.EqualValues(t, 2, res.Status.RowsMatched)
+	if assert.Len(t, res.Matches, 2) {
+		assert.Equal(t, "/b", res.Matches[0].Data["path"])
+		assert.Equal(t, "/c", res.Matches[1].Data["path"])
+	}
+
+	res, err = client.Datasets.QueryLegacy(context.Background(), "test", querylegacy.Query{
+		Aggregations: []querylegacy.Aggregation{
+			{Op: querylegacy.OpCount},
+			{Op: querylegacy.OpSum, Field: "duration", Alias: "total"},
+		},
+		GroupBy: []string{"status"},
+	}, querylegacy.Options{})
+	require.NoError(t, err)
+
+	assert.EqualValues(t, 3, res.Status.NumGroups)
+	if assert.Len(t, res.Buckets.Totals, 3) {
+		assert.EqualValues(t, 200, res.Buckets.Totals[0].Group["status"])
+		assert.EqualValues(t, 2, res.Buckets.Totals[0].Aggregations[0].Value)
+		assert.Equal(t, "total", res.Buckets.Totals[0].Aggregations[1].Alias)
+		assert.EqualValues(t, 30, res.Buckets.Totals[0].Aggregations[1].Value)
+	}
+}
+
+func TestServer_Query(t *testing.T) {
+	_, client := setup(t)
+	ingestTestEvents(t, client)
+
+	res, err := client.Datasets.Query(context.Background(),
+		"['test'] | where status == 200 or path startswith '/B' | sort by duration desc | take 2 | project path, ms = duration")
+	require.NoError(t, err)
+
+	assert.EqualValues(t, 3, res.Status.RowsMatched)
+	if assert.Len(t, res.Matches, 2) {
+		assert.Equal(t, map[string]any{"path": "/b", "ms": float64(40)}, res.Matches[0].Data)
+		assert.Equal(t, map[string]any{"path": "/a", "ms": float64(20)}, res.Matches[1].Data)
+	}
+
+	res, err = client.Datasets.Query(context.Background(),
+		"test | where not(status == 200) | summarize n = count(), max(duration)")
+	require.NoError(t, err)
+
+	if assert.Len(t, res.Buckets.Totals, 1) {
+		aggs := res.Buckets.Totals[0].Aggregations
+		require.Len(t, aggs, 2)
+		assert.Equal(t, "n", aggs[0].Alias)
+		assert.EqualValues(t, 2, aggs[0].Value)
+		assert.EqualValues(t, 50, aggs[1].Value)
+	}
+
+	_, err = client.Datasets.Query(context.Background(), "['test'] | extend x = 1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported operator "extend"`)
+
+	_, err = client.Datasets.Query(context.Background(), "['test'] | take 2 | sort by duration")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported operator "sort" after "take"`)
+
+	_, err = client.Datasets.Query(context.Background(), "['test'] | take 2 | where status == 200")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported operator "where" after "take"`)
+
+	_, err = client.Datasets.Query(context.Background(), "['test'] | count | take 1")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unsupported operator "take" after "count"`)
+
+	_, err = client.Datasets.Query(context.Background(), "['test'] | where status == 200 | where duration > 10")
+	require.NoError(t, err)
+
+	_, err = client.Datasets.Query(context.Background(), "['unknown']")
+	require.ErrorIs(t, err, axiom.ErrNotFound)
+}
+
+func ingestTestEvents(t *testing.T, client *axiom.Client) {
+	t.Helper()
+
+	_, err := client.Datasets.IngestEvents(context.Background(), "test", []axiom.Event{
+		{"path": "/a", "status": 200, "duration": 20},
+		{"path": "/b", "status": 500, "duration": 40},
+		{"path": "/c", "status": 404, "duration": 50},
+		{"path": "/d", "status": 200, "duration": 10},
+	})
+	require.NoError(t, err)
+}
+
+func encode(t *testing.T, enc axiom.ContentEncoding, data string) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	switch enc {
+	case axiom.Identity:
+		buf.WriteString(data)
+	case axiom.Gzip:
+		w := gzip.NewWriter(&buf)
+		_, err := w.Write([]byte(data))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	case axiom.Zstd:
+		w, err := zstd.NewWriter(&buf)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(data))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	}
+	return &buf
+}
@@ -0,0 +1,265 @@
+package axiom
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+)
+
+// defaultChunkSize is the uncompressed size of the chunks `IngestFile` splits
+// its input into, unless configured otherwise using `ingest.SetMaxBytes`.
+const defaultChunkSize = 8 << 20 // 8 MiB
+
+// chunkSplitter splits NDJSON or CSV content into chunks of whole records. For
+// CSV, the header is repeated at the start of every chunk.
+type chunkSplitter struct {
+	br         *bufio.Reader
+	csv        bool
+	maxBytes   int
+	maxRecords int
+
+	header  []byte
+	pending []byte
+}
+
+func newChunkSplitter(r io.Reader, typ ContentType, maxBytes, maxRecords int) (*chunkSplitter, error) {
+	if typ != NDJSON && typ != CSV {
+		return nil, fmt.Errorf("content type %s can't be split into chunks", typ)
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultChunkSize
+	}
+
+	s := &chunkSplitter{
+		br:         bufio.NewReaderSize(r, 64*1024),
+		csv:        typ == CSV,
+		maxBytes:   maxBytes,
+		maxRecords: maxRecords,
+	}
+
+	if b, _ := s.br.Peek(len(utf8BOM)); bytes.Equal(b, utf8BOM) {
+		_, _ = s.br.Discard(len(utf8BOM))
+	}
+
+	if s.csv {
+		header, err := s.readRecord(nil)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		s.header = header
+	}
+
+	return s, nil
+}
+
+// next returns the next chunk and the amount of records it holds. It returns
+// `io.EOF` if there are no more records to read.
+func (s *chunkSplitter) next() ([]byte, int, error) {
+	var (
+		chunk   = append(append([]byte(nil), s.header...), s.pending...)
+		records int
+	)
+	if s.pending != nil {
+		records, s.pending = 1, nil
+	}
+
+	for s.maxRecords <= 0 || records < s.maxRecords {
+		if records > 0 && len(chunk) >= s.maxBytes {
+			break
+		}
+
+		n := len(chunk)
+		var err error
+		if chunk, err = s.readRecord(chunk); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, 0, err
+		}
+
+		// A record which doesn't fit anymore starts the next chunk. Only a
+		// record exceeding the size on its own makes up a chunk that is
+		// larger.
+		if records > 0 && len(chunk) > s.maxBytes {
+			s.pending = append([]byte(nil), chunk[n:]...)
+			chunk = chunk[:n]
+			break
+		}
+		records++
+	}
+
+	if records == 0 {
+		return nil, 0, io.EOF
+	}
+	return chunk, records, nil
+}
+
+// readRecord appends the next record, including its trailing newline, to the
+// given buffer. Blank lines are skipped. A CSV record spans multiple lines if
+// a newline is part of a quoted value. It returns `io.EOF` if no record is
+// left.
+func (s *chunkSplitter) readRecord(dst []byte) ([]byte, error) {
+	var (
+		start  = len(dst)
+		quotes int
+	)
+	for {
+		line, err := s.br.ReadSlice('\n')
+		if err != nil && err != bufio.ErrBufferFull && err != io.EOF {
+			return dst[:start], err
+		}
+
+		if len(dst) == start && err == nil && len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		dst = append(dst, line...)
+		if s.csv {
+			quotes += bytes.Count(line, []byte{'"'})
+		}
+
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF:
+			if len(bytes.TrimSpace(dst[start:])) == 0 {
+				return dst[:start], io.EOF
+			}
+			return append(dst, '\n'), nil
+		case quotes%2 == 0:
+			return dst, nil
+		}
+	}
+}
+
+// compressChunk zstd compresses the given chunk.
+func compressChunk(chunk []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zsw, err := getZstdWriter(&buf, zstd.SpeedDefault)
+	if err != nil {
+		return nil, err
+	}
+	defer putZstdWriter(zsw, zstd.SpeedDefault)
+
+	if _, err = zsw.Write(chunk); err != nil {
+		return nil, err
+	} else if err = zsw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ingestChunk compresses and sends a single chunk. As the request body can be
+// replayed, the request is retried according to the retry policy of the
+// client.
+func ingestChunk(ctx context.Context, s *DatasetsService, path string, typ ContentType, chunk []byte, progress *progressTracker) (*ingest.Status, error) {
+	data, err := compressChunk(chunk)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := s.client.NewRequest(ctx, http.MethodPost, path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", typ.String())
+	req.Header.Set("Content-Encoding", Zstd.String())
+	progress.trackBody(req, false)
+
+	var res ingest.Status
+	_, err = s.client.Do(req, &res)
+	progress.completed()
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// chunkResult is the outcome of ingesting a single chunk.
+type chunkResult struct {
+	status *ingest.Status
+	err    error
+}
+
+// ingestChunks reads the chunks from the given splitter and sends them using
+// the given amount of concurrent requests. Their statuses are merged in order.
+// All chunks are sent, even if some of them fail, but reading stops at the
+// first read error or when the context is canceled.
+func ingestChunks(ctx context.Context, s *DatasetsService, path string, typ ContentType, cs *chunkSplitter, concurrency int, progress *progressTracker) (ingest.Status, int, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		results []*chunkResult
+		readErr error
+		sem     = make(chan struct{}, concurrency)
+		wg      sync.WaitGroup
+	)
+	for {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			readErr = ctx.Err()
+		}
+		if readErr != nil {
+			break
+		}
+
+		chunk, records, err := cs.next()
+		if err != nil {
+			<-sem
+			if !errors.Is(err, io.EOF) {
+				readErr = err
+			}
+			break
+		}
+		progress.read(len(chunk)-len(cs.header), records)
+
+		result := new(chunkResult)
+		results = append(results, result)
+
+		wg.Add(1)
+		go func(chunk []byte, records int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if result.status, result.err = ingestChunk(ctx, s, path, typ, chunk, progress); result.err != nil {
+				s.client.metrics.recordFailedEvents(ctx, records)
+			}
+		}(chunk, records)
+	}
+	wg.Wait()
+
+	var (
+		res      ingest.Status
+		firstErr error
+		failed   int
+	)
+	for _, result := range results {
+		if result.err != nil {
+			if failed++; firstErr == nil {
+				firstErr = result.err
+			}
+			continue
+		}
+		res.Add(result.status)
+	}
+
+	switch {
+	case readErr != nil:
+		return res, len(results), fmt.Errorf("reading chunk %d: %w", len(results), readErr)
+	case firstErr != nil:
+		return res, len(results), fmt.Errorf("%d of %d ingest requests failed: %w", failed, len(results), firstErr)
+	}
+	return res, len(results), nil
+}
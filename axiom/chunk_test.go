@@ -0,0 +1,119 @@
+package axiom
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkSplitter(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		typ        ContentType
+		maxBytes   int
+		maxRecords int
+		exp        []string
+	}{
+		{
+			name:  "ndjson",
+			input: "{\"a\":1}\n{\"a\":2}\n",
+			typ:   NDJSON,
+			exp:   []string{"{\"a\":1}\n{\"a\":2}\n"},
+		},
+		{
+			name:     "ndjson max bytes",
+			input:    "{\"a\":1}\n{\"a\":2}\n{\"a\":3}",
+			typ:      NDJSON,
+			maxBytes: 16,
+			exp:      []string{"{\"a\":1}\n{\"a\":2}\n", "{\"a\":3}\n"},
+		},
+		{
+			name:     "ndjson record exceeding max bytes",
+			input:    "{\"a\":1}\n{\"a\":\"long\"}\n{\"a\":3}\n",
+			typ:      NDJSON,
+			maxBytes: 10,
+			exp:      []string{"{\"a\":1}\n", "{\"a\":\"long\"}\n", "{\"a\":3}\n"},
+		},
+		{
+			name:       "ndjson max records",
+			input:      "{\"a\":1}\n{\"a\":2}\n{\"a\":3}\n",
+			typ:        NDJSON,
+			maxRecords: 2,
+			exp:        []string{"{\"a\":1}\n{\"a\":2}\n", "{\"a\":3}\n"},
+		},
+		{
+			name:  "ndjson blank lines and bom",
+			input: "\ufeff{\"a\":1}\n\n  \n{\"a\":2}\n\n",
+			typ:   NDJSON,
+			exp:   []string{"{\"a\":1}\n{\"a\":2}\n"},
+		},
+		{
+			name:       "csv",
+			input:      "\ufeffa,b\n1,2\n3,4\n5,6\n",
+			typ:        CSV,
+			maxRecords: 2,
+			exp:        []string{"a,b\n1,2\n3,4\n", "a,b\n5,6\n"},
+		},
+		{
+			name:       "csv quoted newline",
+			input:      "a,b\n1,\"x\n\ny\"\n2,\"\"\"z\"\"\"\n3,4",
+			typ:        CSV,
+			maxRecords: 1,
+			exp:        []string{"a,b\n1,\"x\n\ny\"\n", "a,b\n2,\"\"\"z\"\"\"\n", "a,b\n3,4\n"},
+		},
+		{
+			name:  "csv header only",
+			input: "a,b\n",
+			typ:   CSV,
+		},
+		{
+			name: "empty",
+			typ:  NDJSON,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, err := newChunkSplitter(strings.NewReader(tt.input), tt.typ, tt.maxBytes, tt.maxRecords)
+			require.NoError(t, err)
+
+			var chunks []string
+			for {
+				chunk, _, err := cs.next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				chunks = append(chunks, string(chunk))
+			}
+
+			assert.Equal(t, tt.exp, chunks)
+		})
+	}
+}
+
+func TestChunkSplitter_LongLine(t *testing.T) {
+	// Lines exceeding the size of the read buffer must not be split.
+	line := `{"a":"` + strings.Repeat("x", 100*1024) + `"}` + "\n"
+
+	cs, err := newChunkSplitter(strings.NewReader(line+line), NDJSON, 1, 0)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		chunk, _, err := cs.next()
+		require.NoError(t, err)
+		assert.Equal(t, line, string(chunk))
+	}
+
+	_, _, err = cs.next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestChunkSplitter_InvalidContentType(t *testing.T) {
+	_, err := newChunkSplitter(strings.NewReader("[]"), JSON, 0, 0)
+	assert.EqualError(t, err, "content type application/json can't be split into chunks")
+}
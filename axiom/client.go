@@ -20,6 +20,7 @@ import (
 	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 
@@ -40,8 +41,13 @@ const (
 	mediaTypeNDJSON  = "application/x-ndjson"
 
 	otelTracerName = "github.com/axiomhq/axiom-go/axiom"
+	otelMeterName  = "github.com/axiomhq/axiom-go/axiom"
 )
 
+// errRetryableStatusCode signals that a request should be retried because of
+// the status code of its response.
+var errRetryableStatusCode = errors.New("retryable status code")
+
 var validOnlyAPITokenPaths = regexp.MustCompile(`^/api/v1/datasets/([^/]+/(ingest|query)|_apl)(\?.+)?$`)
 
 // service is the base service used by all Axiom API services.
@@ -78,6 +84,14 @@ type Client struct {
 	strictDecoding bool
 	noEnv          bool
 	noLimiting     bool
+	retryPolicy    RetryPolicy
+	limits         *limitTracker
+	limitWait      time.Duration
+	interceptors   []Interceptor
+	logger         Logger
+	logSampleRate  float64
+	logMaxBodySize int
+	metrics        *metrics
 
 	tracer trace.Tracer
 
@@ -105,7 +119,12 @@ func NewClient(options ...Option) (*Client, error) {
 
 		userAgent: "axiom-go",
 
-		httpClient: DefaultHTTPClient(),
+		httpClient:  DefaultHTTPClient(),
+		retryPolicy: DefaultRetryPolicy(),
+		limits:      newLimitTracker(),
+
+		logSampleRate:  1,
+		logMaxBodySize: defaultLogMaxBodySize,
 
 		tracer: otel.Tracer(otelTracerName),
 	}
@@ -173,7 +192,9 @@ func (c *Client) Call(ctx context.Context, method, path string, body, v any) err
 
 // NewRequest creates an API request. If specified, the value pointed to by body
 // will be included as the request body. If it is not an io.Reader, it will be
-// included as a JSON encoded request body.
+// included as a JSON encoded request body. An organization ID carried by the
+// context (see `WithOrganizationID`) takes precedence over the one configured
+// on the client.
 func (c *Client) NewRequest(ctx context.Context, method, path string, body any) (*http.Request, error) {
 	rel, err := url.ParseRequestURI(path)
 	if err != nil {
@@ -199,12 +220,35 @@ func (c *Client) NewRequest(ctx context.Context, method, path string, body any)
 		}
 	}
 
+	// Readers that can be rewound are wrapped so the transport doesn't close
+	// them after the first attempt. They are rewound to their current offset
+	// when the request is retried.
+	var (
+		rs     io.ReadSeeker
+		offset int64
+	)
+	if s, ok := r.(io.ReadSeeker); ok && !hasGetBody(r) {
+		if offset, err = s.Seek(0, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+		rs, r = s, io.NopCloser(s)
+	}
+
 	ctx = httptrace.WithClientTrace(ctx, otelhttptrace.NewClientTrace(ctx))
 	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), r)
 	if err != nil {
 		return nil, err
 	}
 
+	if rs != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			if _, err := rs.Seek(offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+			return io.NopCloser(rs), nil
+		}
+	}
+
 	// Set Content-Type.
 	if body != nil && !isReader {
 		req.Header.Set(headerContentType, mediaTypeJSON)
@@ -217,9 +261,14 @@ func (c *Client) NewRequest(ctx context.Context, method, path string, body any)
 		req.Header.Set(headerAuthorization, "Bearer "+c.config.AccessToken())
 	}
 
-	// Set organization ID header when using a personal token.
-	if config.IsPersonalToken(c.config.AccessToken()) && c.config.OrganizationID() != "" {
-		req.Header.Set(headerOrganizationID, c.config.OrganizationID())
+	// Set organization ID header when using a personal token. The one carried
+	// by the context takes precedence over the configured one.
+	organizationID := c.config.OrganizationID()
+	if id, ok := organizationIDFromContext(ctx); ok {
+		organizationID = id
+	}
+	if config.IsPersonalToken(c.config.AccessToken()) && organizationID != "" {
+		req.Header.Set(headerOrganizationID, organizationID)
 	}
 
 	// Set other headers.
@@ -232,28 +281,111 @@ func (c *Client) NewRequest(ctx context.Context, method, path string, body any)
 // Do sends an API request and returns the API response. The response body is
 // JSON decoded or directly written to v, depending on v being an io.Writer or
 // not.
+//
+// Requests failing with a status code >= 500 or a network error are retried
+// with an exponential backoff, as configured by the clients `RetryPolicy` (see
+// `SetRetryPolicy`), but only if their body can be replayed. That is the case
+// for requests without a body and for requests that have
+// `http.Request.GetBody` set. `NewRequest` sets it for all bodies that are not
+// an `io.Reader` as well as for `*bytes.Buffer`, `*bytes.Reader`,
+// `*strings.Reader` and any other `io.ReadSeeker`. Wrap a reader with
+// `NonReplayable` to make sure a request is never retried.
+//
+// The client keeps track of the limits reported by the server. A request that
+// is known to exceed one of them fails with a `*LimitError` without being sent,
+// until the limit resets. This can be disabled by using `SetNoLimiting`. If
+// enabled by `SetLimitWait`, the client waits for the limit to reset and sends
+// the request again instead of failing.
+//
+// Requests are passed through the interceptors registered with
+// `SetInterceptors` before being sent.
 func (c *Client) Do(req *http.Request, v any) (*Response, error) {
-	bck := backoff.NewExponentialBackOff()
-	bck.InitialInterval = 200 * time.Millisecond
-	bck.Multiplier = 2.0
-	bck.MaxElapsedTime = 10 * time.Second
+	send := func(req *http.Request) (*Response, error) {
+		return c.doWithLimitWait(req, v)
+	}
+
+	start := time.Now()
+	resp, err := intercept(send, c.interceptors)(req)
+	c.metrics.recordRequest(req.Context(), operationFromContext(req.Context()), start, resp, err)
+
+	return resp, err
+}
+
+func (c *Client) doWithLimitWait(req *http.Request, v any) (*Response, error) {
+	for {
+		resp, err := c.do(req, v)
 
-	var resp *Response
+		var limitErr *LimitError
+		if c.limitWait == 0 || !errors.As(err, &limitErr) || !isReplayable(req) {
+			return resp, err
+		} else if waitErr := c.waitForLimit(req.Context(), limitErr.Limit); waitErr != nil {
+			closeBody(req)
+			return resp, err
+		} else if rewindErr := rewindBody(req); rewindErr != nil {
+			return resp, rewindErr
+		}
+	}
+}
+
+func (c *Client) do(req *http.Request, v any) (*Response, error) {
+	if !c.noLimiting {
+		if err := c.limits.check(req); err != nil {
+			closeBody(req)
+			return nil, err
+		}
+	}
+
+	var (
+		resp      *Response
+		retryable = isReplayable(req) && c.retryPolicy.retryMethod(req.Method)
+		attempt   int
+	)
 	err := backoff.Retry(func() error {
-		httpResp, err := c.httpClient.Do(req)
+		if attempt++; attempt > 1 {
+			// Discard the response of the previous attempt.
+			if resp != nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+				resp = nil
+			}
+
+			// Make sure the body is rewound before sending the request again.
+			if err := rewindBody(req); err != nil {
+				return backoff.Permanent(err)
+			}
+		}
+
+		var (
+			httpResp *http.Response
+			err      error
+		)
+		if c.shouldLog() {
+			httpResp, err = c.sendAndLog(req)
+		} else {
+			httpResp, err = c.httpClient.Do(req)
+		}
 		if err != nil {
+			if !retryable || !c.retryPolicy.retryError(err) {
+				return backoff.Permanent(err)
+			}
 			return err
 		}
 
 		resp = newResponse(httpResp)
 
-		// We should only retry in the case the status code is >= 500, anything below isn't worth retrying.
-		if code := resp.StatusCode; code >= 500 {
-			return fmt.Errorf("got status code %d", code)
+		// Limits are tracked regardless of limiting, as they are also
+		// reported as metrics.
+		c.limits.update(req, resp.Limit)
+
+		// We should only retry in the case the status code is considered
+		// retryable, which by default is >= 500. Anything else isn't worth
+		// retrying. Requests which body can't be replayed are never retried.
+		if code := resp.StatusCode; retryable && c.retryPolicy.retryStatusCode(code) {
+			return errRetryableStatusCode
 		}
 
 		return nil
-	}, bck)
+	}, c.retryPolicy.backOff(req.Context()))
 
 	defer func() {
 		if resp != nil {
@@ -262,6 +394,12 @@ func (c *Client) Do(req *http.Request, v any) (*Response, error) {
 		}
 	}()
 
+	// If retrying didn't help, the response of the last attempt is handled
+	// like any other.
+	if errors.Is(err, errRetryableStatusCode) {
+		err = nil
+	}
+
 	if err != nil {
 		return resp, err
 	}
@@ -337,8 +475,125 @@ func (c *Client) Do(req *http.Request, v any) (*Response, error) {
 	return resp, nil
 }
 
-func (c *Client) trace(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
-	return c.tracer.Start(ctx, name, opts...)
+// NonReplayable wraps the given reader to mark it as not being replayable. A
+// request using it as its body is never retried, even if the underlying reader
+// could be rewound.
+func NonReplayable(r io.Reader) io.Reader {
+	return nonReplayableReader{r}
+}
+
+type nonReplayableReader struct {
+	io.Reader
+}
+
+type organizationIDContextKey struct{}
+
+// WithOrganizationID returns a copy of the context which carries the given
+// organization ID. Requests created with it by `Client.NewRequest` and thus by
+// all service methods are sent on behalf of that organization instead of the
+// one configured on the client. This allows a single client using a personal
+// token to target different organizations from concurrent goroutines. It has
+// no effect when using an API token, as those are bound to an organization.
+func WithOrganizationID(ctx context.Context, organizationID string) context.Context {
+	return context.WithValue(ctx, organizationIDContextKey{}, organizationID)
+}
+
+// organizationIDFromContext returns the organization ID carried by the
+// context, if any.
+func organizationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(organizationIDContextKey{}).(string)
+	return id, ok
+}
+
+// waitForLimit blocks until the given limit resets. It returns an error without
+// waiting, if the limit doesn't reset within the configured maximum wait time or
+// before the context expires.
+func (c *Client) waitForLimit(ctx context.Context, limit Limit) error {
+	if limit.Reset.IsZero() {
+		return errors.New("limit reset unknown")
+	}
+
+	// Limits are reset with a precision of one second, so wait for at least
+	// that long to not hammer the server when clocks are skewed.
+	wait := time.Until(limit.Reset)
+	if wait < time.Second {
+		wait = time.Second
+	}
+
+	if wait > c.limitWait {
+		return fmt.Errorf("limit resets in %s which exceeds the maximum wait time of %s", wait, c.limitWait)
+	} else if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		return context.DeadlineExceeded
+	}
+
+	trace.SpanFromContext(ctx).AddEvent("axiom.limit.wait", trace.WithAttributes(
+		attribute.String("axiom.limit.type", limit.limitType.String()),
+		attribute.String("axiom.limit.scope", limit.Scope.String()),
+		attribute.String("axiom.limit.reset", limit.Reset.String()),
+		attribute.String("axiom.limit.wait", wait.String()),
+	))
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// rewindBody replaces the body of the given request with a fresh copy, if the
+// request supports it.
+func rewindBody(req *http.Request) error {
+	if req.GetBody == nil {
+		return nil
+	}
+
+	// The body might not have been consumed and closed, yet.
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+
+	return nil
+}
+
+// closeBody closes the body of a request which is not sent (again). This
+// releases whatever feeds the body, e.g. the goroutine encoding the events to
+// ingest into a pipe.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
+
+// isReplayable returns true if the body of the given request can be sent again.
+func isReplayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// hasGetBody returns true if `http.NewRequest` sets `http.Request.GetBody` for
+// the given reader.
+func hasGetBody(r io.Reader) bool {
+	switch r.(type) {
+	case *bytes.Buffer, *bytes.Reader, *strings.Reader:
+		return true
+	}
+	return false
+}
+
+// trace starts a span for the service method performing the given operation,
+// which is named after it. The returned context also carries the operation,
+// which is handed to interceptors.
+func (c *Client) trace(ctx context.Context, op Operation, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return c.tracer.Start(withOperation(ctx, op), op.Name, opts...)
 }
 
 func spanError(span trace.Span, err error) error {
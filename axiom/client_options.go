@@ -1,7 +1,11 @@
 package axiom
 
 import (
+	"fmt"
 	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
 
 	"github.com/axiomhq/axiom-go/internal/config"
 )
@@ -28,7 +32,9 @@ func SetAccessToken(accessToken string) Option {
 
 // SetOrganizationID specifies the organization ID to use when connecting to
 // Axiom. When a personal token is used, this method can be used to switch
-// between organizations without creating a new client instance.
+// between organizations without creating a new client instance. To target
+// different organizations from concurrent goroutines, use
+// `WithOrganizationID` instead.
 //
 // Can also be specified using the `AXIOM_ORG_ID` environment variable.
 func SetOrganizationID(organizationID string) Option {
@@ -80,3 +86,120 @@ func SetNoEnv() Option {
 		return nil
 	}
 }
+
+// SetRetryPolicy specifies the policy used by the client to retry failed
+// requests. Use `DefaultRetryPolicy()` as a starting point for a custom policy.
+// Zero values of the policy select the defaults documented on its fields.
+func SetRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) error {
+		policy = policy.withDefaults()
+		if err := policy.validate(); err != nil {
+			return err
+		}
+		c.retryPolicy = policy
+		return nil
+	}
+}
+
+// SetNoRetry prevents the client from retrying failed requests.
+func SetNoRetry() Option {
+	return func(c *Client) error {
+		c.retryPolicy.MaxAttempts = 1
+		return nil
+	}
+}
+
+// SetNoLimiting prevents the client from failing requests which are known to
+// exceed the limits reported by the server before they are sent. Instead, they
+// are always sent and fail on the server side.
+func SetNoLimiting() Option {
+	return func(c *Client) error {
+		c.noLimiting = true
+		return nil
+	}
+}
+
+// SetLimitWait makes the client wait for an exceeded limit to reset and then
+// send the request again, instead of failing with a `*LimitError`. The client
+// only waits if the limit resets within the given maximum wait time and before
+// the requests context expires. Requests which body can't be replayed are never
+// sent again.
+func SetLimitWait(maxWait time.Duration) Option {
+	return func(c *Client) error {
+		c.limitWait = maxWait
+		return nil
+	}
+}
+
+// SetInterceptors specifies the interceptors every API request is passed
+// through before being sent. The first interceptor is the outermost one and
+// thus the first to see a request and the last to see its response. See
+// `Interceptor` on what interceptors can and can't change about a response.
+func SetInterceptors(interceptors ...Interceptor) Option {
+	return func(c *Client) error {
+		c.interceptors = interceptors
+		return nil
+	}
+}
+
+// SetLogger specifies a logger the client logs every API request and its
+// response to, including retries. Secrets, like the access token or the token
+// of a shared access signature, are redacted. Use `SetLogSampleRate` and
+// `SetLogMaxBodySize` to reduce the amount of logged data, e.g. in production.
+func SetLogger(logger Logger) Option {
+	return func(c *Client) error {
+		c.logger = logger
+		return nil
+	}
+}
+
+// SetLogSampleRate specifies the fraction of API requests that is logged by
+// the logger configured with `SetLogger`. Must be in the range of (0, 1].
+// Defaults to 1 which logs every request.
+func SetLogSampleRate(rate float64) Option {
+	return func(c *Client) error {
+		if rate <= 0 || rate > 1 {
+			return fmt.Errorf("invalid log sample rate %g: must be in range (0, 1]", rate)
+		}
+		c.logSampleRate = rate
+		return nil
+	}
+}
+
+// SetLogMaxBodySize specifies the maximum amount of bytes of request and
+// response bodies included in the entries passed to the logger configured with
+// `SetLogger`. Zero omits bodies entirely. Defaults to 1024 bytes.
+func SetLogMaxBodySize(size int) Option {
+	return func(c *Client) error {
+		if size < 0 {
+			return fmt.Errorf("invalid log max body size %d: must not be negative", size)
+		}
+		c.logMaxBodySize = size
+		return nil
+	}
+}
+
+// SetMeterProvider specifies the OpenTelemetry meter provider the client
+// records its metrics with. Metrics include the amount and duration of API
+// requests by operation and status code, the amount of events ingested and
+// failed as well as the bytes processed per dataset, the amount of rows
+// examined by queries and the remaining amount of the limits reported by the
+// server, which are also tracked if `SetNoLimiting` is used. The events sent
+// with a request that fails are counted as failed, except for the ones passed
+// to `DatasetsService.Ingest`, as their amount is unknown. By default, no
+// metrics are recorded. Setting another meter provider replaces the previous
+// one. A nil meter provider is ignored.
+func SetMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *Client) error {
+		if mp == nil {
+			return nil
+		}
+		m, err := newMetrics(mp, c.limits)
+		if err != nil {
+			return err
+		}
+		c.metrics.stop()
+		c.metrics = m
+		return nil
+	}
+}
@@ -5,17 +5,21 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	"github.com/axiomhq/axiom-go/internal/config"
 	"github.com/axiomhq/axiom-go/internal/test/testhelper"
@@ -321,6 +325,65 @@ func TestClient_Options_SetUserAgent(t *testing.T) {
 	assert.Equal(t, exp, client.userAgent)
 }
 
+func TestClient_Options_SetRetryPolicy(t *testing.T) {
+	client := newClient(t)
+
+	exp := DefaultRetryPolicy()
+	exp.MaxAttempts = 5
+	exp.MaxElapsedTime = time.Minute
+	opt := SetRetryPolicy(exp)
+
+	err := client.Options(opt)
+	assert.NoError(t, err)
+
+	assert.Equal(t, exp.MaxAttempts, client.retryPolicy.MaxAttempts)
+	assert.Equal(t, exp.MaxElapsedTime, client.retryPolicy.MaxElapsedTime)
+
+	invalid := DefaultRetryPolicy()
+	invalid.Jitter = 2
+	opt = SetRetryPolicy(invalid)
+
+	err = client.Options(opt)
+	assert.EqualError(t, err, "invalid retry jitter 2: must be in range [0, 1]")
+
+	invalid = RetryPolicy{Multiplier: 0.5}
+	opt = SetRetryPolicy(invalid)
+
+	err = client.Options(opt)
+	assert.EqualError(t, err, "invalid retry multiplier 0.5: must be at least 1")
+}
+
+func TestClient_Options_SetRetryPolicy_Defaults(t *testing.T) {
+	client := newClient(t)
+
+	err := client.Options(SetRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	require.NoError(t, err)
+
+	exp := DefaultRetryPolicy()
+	exp.MaxAttempts = 1
+	exp.MaxElapsedTime = 0
+	exp.Jitter = 0
+	assert.Equal(t, exp, client.retryPolicy)
+
+	err = client.Options(SetRetryPolicy(RetryPolicy{}))
+	require.NoError(t, err)
+
+	exp = DefaultRetryPolicy()
+	exp.Jitter = 0
+	assert.Equal(t, exp, client.retryPolicy)
+}
+
+func TestClient_Options_SetNoRetry(t *testing.T) {
+	client := newClient(t)
+
+	opt := SetNoRetry()
+
+	err := client.Options(opt)
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, 1, client.retryPolicy.MaxAttempts)
+}
+
 func TestClient_newRequest_BadURL(t *testing.T) {
 	client := newClient(t)
 
@@ -347,6 +410,37 @@ func TestClient_newRequest_EmptyBody(t *testing.T) {
 	assert.Empty(t, req.Body)
 }
 
+func TestClient_newRequest_OrganizationIDFromContext(t *testing.T) {
+	client := newClient(t)
+
+	err := client.Options(SetOrganizationID(organizationID))
+	require.NoError(t, err)
+
+	req, err := client.NewRequest(context.Background(), http.MethodGet, "/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, organizationID, req.Header.Get("X-Axiom-Org-Id"))
+
+	ctx := WithOrganizationID(context.Background(), "other-org")
+	req, err = client.NewRequest(ctx, http.MethodGet, "/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "other-org", req.Header.Get("X-Axiom-Org-Id"))
+
+	// The organization configured on the client must not be altered.
+	assert.Equal(t, organizationID, client.config.OrganizationID())
+}
+
+func TestClient_newRequest_OrganizationIDFromContext_APIToken(t *testing.T) {
+	client := newClient(t)
+
+	err := client.Options(SetAccessToken(apiToken))
+	require.NoError(t, err)
+
+	ctx := WithOrganizationID(context.Background(), "other-org")
+	req, err := client.NewRequest(ctx, http.MethodPost, "/api/v1/datasets/test/ingest", nil)
+	require.NoError(t, err)
+	assert.Empty(t, req.Header.Get("X-Axiom-Org-Id"))
+}
+
 func TestClient_do(t *testing.T) {
 	hf := func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, http.MethodGet, r.Method)
@@ -497,6 +591,159 @@ func TestClient_do_RateLimit(t *testing.T) {
 	assert.Equal(t, expErr.Limit, resp.Limit)
 }
 
+func TestClient_do_Limiting(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name         string
+		options      []Option
+		reset        time.Time
+		path         string
+		wantCalls    int
+		wantLimitErr bool
+	}{
+		{
+			name:         "same limit",
+			reset:        reset,
+			path:         "/api/v1/datasets/test/ingest",
+			wantCalls:    1,
+			wantLimitErr: true,
+		},
+		{
+			name:      "different limit",
+			reset:     reset,
+			path:      "/api/v1/datasets/test/query",
+			wantCalls: 2,
+		},
+		{
+			name:      "limit reset",
+			reset:     time.Now().Add(-time.Second),
+			path:      "/api/v1/datasets/test/ingest",
+			wantCalls: 2,
+		},
+		{
+			name:      "no limiting",
+			options:   []Option{SetNoLimiting()},
+			reset:     reset,
+			path:      "/api/v1/datasets/test/ingest",
+			wantCalls: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var currentCalls int
+			hf := func(w http.ResponseWriter, r *http.Request) {
+				currentCalls++
+				if r.URL.Path == "/api/v1/datasets/test/ingest" {
+					w.Header().Set(headerIngestLimit, "1000")
+					w.Header().Set(headerIngestRemaining, "0")
+					w.Header().Set(headerIngestReset, strconv.FormatInt(tt.reset.Unix(), 10))
+				}
+				w.WriteHeader(http.StatusOK)
+			}
+
+			r := http.NewServeMux()
+			r.HandleFunc("/api/v1/datasets/test/ingest", hf)
+			r.HandleFunc("/api/v1/datasets/test/query", hf)
+			client := setup(t, "/", r.ServeHTTP)
+
+			err := client.Options(tt.options...)
+			require.NoError(t, err)
+
+			// The first request exhausts the ingest limit.
+			req, err := client.NewRequest(context.Background(), http.MethodPost, "/api/v1/datasets/test/ingest", nil)
+			require.NoError(t, err)
+
+			_, err = client.Do(req, nil)
+			require.NoError(t, err)
+
+			req, err = client.NewRequest(context.Background(), http.MethodPost, tt.path, nil)
+			require.NoError(t, err)
+
+			_, err = client.Do(req, nil)
+			if tt.wantLimitErr {
+				var limitErr *LimitError
+				if assert.ErrorAs(t, err, &limitErr) {
+					assert.Equal(t, limitIngest, limitErr.Limit.limitType)
+					assert.Equal(t, reset, limitErr.Limit.Reset)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.wantCalls, currentCalls)
+		})
+	}
+}
+
+func TestClient_do_LimitWait(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxWait      time.Duration
+		wantCalls    int
+		wantLimitErr bool
+	}{
+		{
+			name:      "wait",
+			maxWait:   5 * time.Second,
+			wantCalls: 2,
+		},
+		{
+			name:         "max wait exceeded",
+			maxWait:      time.Millisecond,
+			wantCalls:    1,
+			wantLimitErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var currentCalls int
+			hf := func(w http.ResponseWriter, r *http.Request) {
+				b, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, "my body", string(b))
+
+				if currentCalls++; currentCalls == 1 {
+					reset := time.Now().Add(time.Second)
+					w.Header().Set(headerRateScope, "user")
+					w.Header().Set(headerRateLimit, "1000")
+					w.Header().Set(headerRateRemaining, "0")
+					w.Header().Set(headerRateReset, strconv.FormatInt(reset.Unix(), 10))
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}
+
+			client := setup(t, "/", hf)
+
+			err := client.Options(SetLimitWait(tt.maxWait))
+			require.NoError(t, err)
+
+			sr := tracetest.NewSpanRecorder()
+			ctx, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)).Tracer("test").Start(context.Background(), "test")
+
+			req, err := client.NewRequest(ctx, http.MethodPost, "/", strings.NewReader("my body"))
+			require.NoError(t, err)
+
+			_, err = client.Do(req, nil)
+			span.End()
+
+			if tt.wantLimitErr {
+				assert.ErrorAs(t, err, new(*LimitError))
+			} else if assert.NoError(t, err) {
+				// The span of the test is the last one to end.
+				spans := sr.Ended()
+				if events := spans[len(spans)-1].Events(); assert.Len(t, events, 1) {
+					assert.Equal(t, "axiom.limit.wait", events[0].Name)
+				}
+			}
+
+			assert.Equal(t, tt.wantCalls, currentCalls)
+		})
+	}
+}
+
 func TestClient_do_UnprivilegedToken(t *testing.T) {
 	client := setup(t, "/", nil)
 
@@ -591,6 +838,180 @@ func TestClient_do_Backoff_NoRetryOn400(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 }
 
+func TestClient_do_Backoff_NoRetry(t *testing.T) {
+	var currentCalls int
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		currentCalls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	client := setup(t, "/", hf)
+
+	err := client.Options(SetNoRetry())
+	require.NoError(t, err)
+
+	req, err := client.NewRequest(context.Background(), http.MethodGet, "/", nil)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req, nil)
+	assert.ErrorIs(t, err, &Error{
+		Status:  http.StatusInternalServerError,
+		Message: http.StatusText(http.StatusInternalServerError),
+	})
+
+	assert.Equal(t, 1, currentCalls)
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
+
+func TestClient_do_Backoff_RetryPolicy(t *testing.T) {
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = 3
+	policy.InitialInterval = time.Millisecond
+	policy.StatusCodes = []int{http.StatusServiceUnavailable}
+	policy.Methods = []string{http.MethodGet}
+
+	tests := []struct {
+		name      string
+		method    string
+		status    int
+		wantCalls int
+	}{
+		{
+			name:      "retryable",
+			method:    http.MethodGet,
+			status:    http.StatusServiceUnavailable,
+			wantCalls: 3,
+		},
+		{
+			name:      "non retryable status code",
+			method:    http.MethodGet,
+			status:    http.StatusInternalServerError,
+			wantCalls: 1,
+		},
+		{
+			name:      "non retryable method",
+			method:    http.MethodPost,
+			status:    http.StatusServiceUnavailable,
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var currentCalls int
+			hf := func(w http.ResponseWriter, r *http.Request) {
+				currentCalls++
+				w.WriteHeader(tt.status)
+			}
+
+			client := setup(t, "/", hf)
+
+			err := client.Options(SetRetryPolicy(policy))
+			require.NoError(t, err)
+
+			req, err := client.NewRequest(context.Background(), tt.method, "/", nil)
+			require.NoError(t, err)
+
+			resp, err := client.Do(req, nil)
+			assert.ErrorIs(t, err, &Error{
+				Status:  tt.status,
+				Message: http.StatusText(tt.status),
+			})
+
+			assert.Equal(t, tt.wantCalls, currentCalls)
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
+
+func TestClient_do_Backoff_ReplayBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body func() io.Reader
+	}{
+		{
+			name: "bytes reader",
+			body: func() io.Reader { return strings.NewReader("my body") },
+		},
+		{
+			name: "read seeker",
+			body: func() io.Reader { return readSeeker{strings.NewReader("my body")} },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var currentCalls int
+			hf := func(w http.ResponseWriter, r *http.Request) {
+				b, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, "my body", string(b))
+
+				if currentCalls++; currentCalls == 1 {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}
+
+			client := setup(t, "/", hf)
+
+			req, err := client.NewRequest(context.Background(), http.MethodPost, "/", tt.body())
+			require.NoError(t, err)
+
+			resp, err := client.Do(req, nil)
+			require.NoError(t, err)
+
+			assert.Equal(t, 2, currentCalls)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+}
+
+func TestClient_do_Backoff_NoRetryOnNonReplayableBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body func() io.Reader
+	}{
+		{
+			name: "pipe",
+			body: func() io.Reader {
+				pr, pw := io.Pipe()
+				go func() {
+					_, _ = pw.Write([]byte("my body"))
+					_ = pw.Close()
+				}()
+				return pr
+			},
+		},
+		{
+			name: "non replayable",
+			body: func() io.Reader { return NonReplayable(strings.NewReader("my body")) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var currentCalls int
+			hf := func(w http.ResponseWriter, r *http.Request) {
+				currentCalls++
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+
+			client := setup(t, "/", hf)
+
+			req, err := client.NewRequest(context.Background(), http.MethodPost, "/", tt.body())
+			require.NoError(t, err)
+
+			resp, err := client.Do(req, nil)
+			assert.ErrorIs(t, err, &Error{
+				Status:  http.StatusInternalServerError,
+				Message: http.StatusText(http.StatusInternalServerError),
+			})
+
+			assert.Equal(t, 1, currentCalls)
+			assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		})
+	}
+}
+
 func TestAPITokenPathRegex(t *testing.T) {
 	tests := []struct {
 		input string
@@ -640,6 +1061,12 @@ func TestAPITokenPathRegex(t *testing.T) {
 	}
 }
 
+// readSeeker hides all methods of the wrapped reader, except the ones of
+// `io.ReadSeeker`.
+type readSeeker struct {
+	io.ReadSeeker
+}
+
 // setup sets up a test HTTP server along with a client that is configured to
 // talk to that test server. Tests should pass a handler function which provides
 // the response for the API method being tested.
@@ -9,9 +9,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
+	"github.com/klauspost/compress/gzip"
 	"github.com/klauspost/compress/zstd"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -31,7 +34,9 @@ var (
 	ErrUnknownContentEncoding = errors.New("unknown content encoding")
 )
 
-// ContentType describes the content type of the data to ingest.
+// ContentType describes the content type of the data to ingest. Data in other
+// formats, like logfmt or syslog, can be converted to NDJSON using
+// `ingest.NewLogfmtReader` and `ingest.NewSyslogReader`.
 type ContentType uint8
 
 const (
@@ -40,7 +45,8 @@ const (
 	// NDJSON treats the data as newline delimited JSON objects. Preferred
 	// data format.
 	NDJSON // application/x-ndjson
-	// CSV treats the data as CSV content.
+	// CSV treats the data as CSV content. All values are ingested as strings,
+	// use `ingest.NewCSVReader` to convert them to NDJSON with typed values.
 	CSV // text/csv
 )
 
@@ -123,6 +129,25 @@ type aplQueryRequest struct {
 	EndTime time.Time `json:"endTime"`
 }
 
+// DatasetsAPI describes the dataset related operations of the Axiom API, as
+// implemented by `DatasetsService` and faked by `axiomtest.Datasets`.
+type DatasetsAPI interface {
+	List(ctx context.Context) ([]*Dataset, error)
+	Get(ctx context.Context, id string) (*Dataset, error)
+	Create(ctx context.Context, req DatasetCreateRequest) (*Dataset, error)
+	Update(ctx context.Context, id string, req DatasetUpdateRequest) (*Dataset, error)
+	Delete(ctx context.Context, id string) error
+	Trim(ctx context.Context, id string, maxDuration time.Duration) (*TrimResult, error)
+	Ingest(ctx context.Context, id string, r io.Reader, typ ContentType, enc ContentEncoding, options ...ingest.Option) (*ingest.Status, error)
+	IngestEvents(ctx context.Context, id string, events []Event, options ...ingest.Option) (*ingest.Status, error)
+	IngestChannel(ctx context.Context, id string, events <-chan Event, options ...ingest.Option) (*ingest.Status, error)
+	IngestFile(ctx context.Context, id string, r io.Reader, typ ContentType, options ...ingest.Option) (*ingest.Status, error)
+	Query(ctx context.Context, q query.Query, options ...query.Option) (*query.Result, error)
+	QueryLegacy(ctx context.Context, id string, q querylegacy.Query, opts querylegacy.Options) (*querylegacy.Result, error)
+}
+
+var _ DatasetsAPI = (*DatasetsService)(nil)
+
 // DatasetsService handles communication with the dataset related operations of
 // the Axiom API.
 //
@@ -131,7 +156,7 @@ type DatasetsService service
 
 // List all available datasets.
 func (s *DatasetsService) List(ctx context.Context) ([]*Dataset, error) {
-	ctx, span := s.client.trace(ctx, "Datasets.List")
+	ctx, span := s.client.trace(ctx, Operation{Name: "Datasets.List"})
 	defer span.End()
 
 	var res []*wrappedDataset
@@ -149,7 +174,7 @@ func (s *DatasetsService) List(ctx context.Context) ([]*Dataset, error) {
 
 // Get a dataset by id.
 func (s *DatasetsService) Get(ctx context.Context, id string) (*Dataset, error) {
-	ctx, span := s.client.trace(ctx, "Datasets.Get", trace.WithAttributes(
+	ctx, span := s.client.trace(ctx, Operation{Name: "Datasets.Get", DatasetID: id}, trace.WithAttributes(
 		attribute.String("axiom.dataset_id", id),
 	))
 	defer span.End()
@@ -166,7 +191,7 @@ func (s *DatasetsService) Get(ctx context.Context, id string) (*Dataset, error)
 
 // Create a dataset with the given properties.
 func (s *DatasetsService) Create(ctx context.Context, req DatasetCreateRequest) (*Dataset, error) {
-	ctx, span := s.client.trace(ctx, "Datasets.Create", trace.WithAttributes(
+	ctx, span := s.client.trace(ctx, Operation{Name: "Datasets.Create"}, trace.WithAttributes(
 		attribute.String("axiom.param.name", req.Name),
 		attribute.String("axiom.param.description", req.Description),
 	))
@@ -182,7 +207,7 @@ func (s *DatasetsService) Create(ctx context.Context, req DatasetCreateRequest)
 
 // Update the dataset identified by the given id with the given properties.
 func (s *DatasetsService) Update(ctx context.Context, id string, req DatasetUpdateRequest) (*Dataset, error) {
-	ctx, span := s.client.trace(ctx, "Datasets.Update", trace.WithAttributes(
+	ctx, span := s.client.trace(ctx, Operation{Name: "Datasets.Update", DatasetID: id}, trace.WithAttributes(
 		attribute.String("axiom.dataset_id", id),
 		attribute.String("axiom.param.description", req.Description),
 	))
@@ -200,7 +225,7 @@ func (s *DatasetsService) Update(ctx context.Context, id string, req DatasetUpda
 
 // Delete the dataset identified by the given id.
 func (s *DatasetsService) Delete(ctx context.Context, id string) error {
-	ctx, span := s.client.trace(ctx, "Datasets.Delete", trace.WithAttributes(
+	ctx, span := s.client.trace(ctx, Operation{Name: "Datasets.Delete", DatasetID: id}, trace.WithAttributes(
 		attribute.String("axiom.dataset_id", id),
 	))
 	defer span.End()
@@ -216,7 +241,7 @@ func (s *DatasetsService) Delete(ctx context.Context, id string) error {
 // given will mark the oldest timestamp an event can have. Older ones will be
 // deleted from the dataset.
 func (s *DatasetsService) Trim(ctx context.Context, id string, maxDuration time.Duration) (*TrimResult, error) {
-	ctx, span := s.client.trace(ctx, "Datasets.Trim", trace.WithAttributes(
+	ctx, span := s.client.trace(ctx, Operation{Name: "Datasets.Trim", DatasetID: id}, trace.WithAttributes(
 		attribute.String("axiom.dataset_id", id),
 		attribute.String("axiom.param.max_duration", maxDuration.String()),
 	))
@@ -238,10 +263,15 @@ func (s *DatasetsService) Trim(ctx context.Context, id string, maxDuration time.
 
 // Ingest data into the dataset identified by its id.
 //
+// The request is only retried on server errors if the reader can be rewound,
+// e.g. because it implements `io.Seeker`. Refer to `Client.Do` for details.
+// If the content type and encoding of the data are unknown, use
+// `DetectContent` to detect them.
+//
 // Restrictions for field names (JSON object keys) can be reviewed here:
 // https://www.axiom.co/docs/usage/field-restrictions.
 func (s *DatasetsService) Ingest(ctx context.Context, id string, r io.Reader, typ ContentType, enc ContentEncoding, options ...ingest.Option) (*ingest.Status, error) {
-	ctx, span := s.client.trace(ctx, "Datasets.Ingest", trace.WithAttributes(
+	ctx, span := s.client.trace(ctx, Operation{Name: "Datasets.Ingest", DatasetID: id}, trace.WithAttributes(
 		attribute.String("axiom.dataset_id", id),
 		attribute.String("axiom.param.content_type", typ.String()),
 		attribute.String("axiom.param.content_encoding", enc.String()),
@@ -254,6 +284,9 @@ func (s *DatasetsService) Ingest(ctx context.Context, id string, r io.Reader, ty
 		option(&opts)
 	}
 
+	progress := newProgressTracker(opts.Progress)
+	defer progress.done()
+
 	path, err := AddOptions(s.basePath+"/"+id+"/ingest", opts)
 	if err != nil {
 		return nil, spanError(span, err)
@@ -281,150 +314,119 @@ func (s *DatasetsService) Ingest(ctx context.Context, id string, r io.Reader, ty
 		return nil, spanError(span, err)
 	}
 
+	progress.trackBody(req, true)
+
 	var res ingest.Status
-	if _, err = s.client.Do(req, &res); err != nil {
+	_, err = s.client.Do(req, &res)
+	progress.completed()
+	if err != nil {
 		return nil, spanError(span, err)
 	}
 
 	setIngestResultOnSpan(span, res)
+	s.client.metrics.recordIngestStatus(ctx, id, res)
 
 	return &res, nil
 }
 
-// IngestEvents ingests events into the dataset identified by its id.
+// IngestEvents ingests events into the dataset identified by its id. Use
+// `FailedEvents` to find out which of the events failed to be ingested.
+//
+// If the events exceed the caps configured using `ingest.SetMaxEvents`,
+// `ingest.SetMaxBytes` or `ingest.SetMaxCompressedBytes`, they are split into
+// multiple requests, which are sent sequentially or, using
+// `ingest.SetConcurrency`, in parallel. Their statuses are merged into the one
+// returned. If some of the requests fail, the status of the successful ones is
+// returned along with the error.
 //
 // Restrictions for field names (JSON object keys) can be reviewed here:
 // https://www.axiom.co/docs/usage/field-restrictions.
 func (s *DatasetsService) IngestEvents(ctx context.Context, id string, events []Event, options ...ingest.Option) (*ingest.Status, error) {
-	ctx, span := s.client.trace(ctx, "Datasets.IngestEvents", trace.WithAttributes(
+	ctx, span := s.client.trace(ctx, Operation{Name: "Datasets.IngestEvents", DatasetID: id}, trace.WithAttributes(
 		attribute.String("axiom.dataset_id", id),
 		attribute.Int("axiom.events_to_ingest", len(events)),
 	))
 	defer span.End()
 
-	// Apply supplied options.
-	var opts ingest.Options
-	for _, option := range options {
-		option(&opts)
-	}
-
-	if len(events) == 0 {
-		return &ingest.Status{}, nil
-	}
-
-	path, err := AddOptions(s.basePath+"/"+id+"/ingest", opts)
-	if err != nil {
-		return nil, spanError(span, err)
-	}
-
-	pr, pw := io.Pipe()
-	go func() {
-		zsw, wErr := zstd.NewWriter(pw)
-		if wErr != nil {
-			_ = pw.CloseWithError(wErr)
-			return
-		}
-
-		var (
-			enc    = json.NewEncoder(zsw)
-			encErr error
-		)
-		for _, event := range events {
-			if encErr = enc.Encode(event); encErr != nil {
-				break
-			}
-		}
-
-		if closeErr := zsw.Close(); encErr == nil {
-			// If we have no error from encoding but from closing, capture that
-			// one.
-			encErr = closeErr
-		}
-		_ = pw.CloseWithError(encErr)
-	}()
-
-	req, err := s.client.NewRequest(ctx, http.MethodPost, path, pr)
-	if err != nil {
-		return nil, spanError(span, err)
-	}
-
-	req.Header.Set("Content-Type", NDJSON.String())
-	req.Header.Set("Content-Encoding", Zstd.String())
-
-	var res ingest.Status
-	if _, err = s.client.Do(req, &res); err != nil {
-		return nil, spanError(span, err)
-	}
-
-	setIngestResultOnSpan(span, res)
-
-	return &res, nil
+	return ingestValues(ctx, s, span, id, events, options, eventPreparer(options))
 }
 
 // IngestChannel ingests events from a channel into the dataset identified by
 // its id. As it keeps a connection open until the channel is closed, it is not
-// advised to use this method for long-running ingestions.
+// advised to use this method for long-running ingestions. The request is never
+// retried as events consumed from the channel can't be replayed.
+//
+// If the caps configured using `ingest.SetMaxEvents` or `ingest.SetMaxBytes`
+// are reached, the request is completed and the remaining events are sent in a
+// new one. Their statuses are merged into the one returned. As the events are
+// compressed while they are consumed, `ingest.SetMaxCompressedBytes` is not
+// supported. Consumption stops at the first request that fails. Events are
+// only consumed while a request is sent, so a request rejected before, e.g.
+// because a limit is exceeded, doesn't consume any, except for the one event
+// that is received ahead of every subsequent request.
 //
 // Restrictions for field names (JSON object keys) can be reviewed here:
 // https://www.axiom.co/docs/usage/field-restrictions.
 func (s *DatasetsService) IngestChannel(ctx context.Context, id string, events <-chan Event, options ...ingest.Option) (*ingest.Status, error) {
-	ctx, span := s.client.trace(ctx, "Datasets.IngestChannel", trace.WithAttributes(
+	ctx, span := s.client.trace(ctx, Operation{Name: "Datasets.IngestChannel", DatasetID: id}, trace.WithAttributes(
 		attribute.String("axiom.dataset_id", id),
 		attribute.Int("axiom.channel.capacity", cap(events)),
 	))
 	defer span.End()
 
+	return ingestChannel(ctx, s, span, id, events, options, eventPreparer(options))
+}
+
+// IngestFile ingests the uncompressed NDJSON or CSV content read from r, e.g.
+// an `*os.File`, into the dataset identified by its id. Other than `Ingest`,
+// it is meant for large inputs: The content is split into chunks of whole
+// records, which are zstd compressed and sent in requests of their own. For
+// CSV, the header is repeated at the start of every chunk. Compressed content
+// must be decompressed up front, `DetectContent` tells its type and encoding.
+//
+// Chunks are about 8 MiB of uncompressed content in size, which
+// `ingest.SetMaxBytes` changes. `ingest.SetMaxEvents` caps the amount of
+// records per chunk. Use `ingest.SetConcurrency` to compress and send multiple
+// chunks in parallel. Each chunk is retried on its own, according to the
+// `RetryPolicy` of the client.
+//
+// The statuses of the chunks are merged into the one returned. All chunks are
+// sent, even if some of them fail. In that case, the status of the successful
+// ones is returned along with the error. Reading stops at the first read error.
+// Processors and validators are not applied.
+func (s *DatasetsService) IngestFile(ctx context.Context, id string, r io.Reader, typ ContentType, options ...ingest.Option) (*ingest.Status, error) {
+	ctx, span := s.client.trace(ctx, Operation{Name: "Datasets.IngestFile", DatasetID: id}, trace.WithAttributes(
+		attribute.String("axiom.dataset_id", id),
+		attribute.String("axiom.param.content_type", typ.String()),
+	))
+	defer span.End()
+
 	// Apply supplied options.
 	var opts ingest.Options
 	for _, option := range options {
 		option(&opts)
 	}
 
+	progress := newProgressTracker(opts.Progress)
+	defer progress.done()
+
 	path, err := AddOptions(s.basePath+"/"+id+"/ingest", opts)
 	if err != nil {
 		return nil, spanError(span, err)
 	}
 
-	pr, pw := io.Pipe()
-	go func() {
-		zsw, wErr := zstd.NewWriter(pw)
-		if wErr != nil {
-			_ = pw.CloseWithError(wErr)
-			return
-		}
-
-		var (
-			enc    = json.NewEncoder(zsw)
-			encErr error
-		)
-		for event := range events {
-			if encErr = enc.Encode(event); encErr != nil {
-				break
-			}
-		}
-
-		if closeErr := zsw.Close(); encErr == nil {
-			// If we have no error from encoding but from closing, capture that
-			// one.
-			encErr = closeErr
-		}
-		_ = pw.CloseWithError(encErr)
-	}()
-
-	req, err := s.client.NewRequest(ctx, http.MethodPost, path, pr)
+	cs, err := newChunkSplitter(r, typ, opts.MaxBytes, opts.MaxEvents)
 	if err != nil {
 		return nil, spanError(span, err)
 	}
 
-	req.Header.Set("Content-Type", NDJSON.String())
-	req.Header.Set("Content-Encoding", Zstd.String())
-
-	var res ingest.Status
-	if _, err = s.client.Do(req, &res); err != nil {
-		return nil, spanError(span, err)
-	}
-
+	res, chunks, err := ingestChunks(ctx, s, path, typ, cs, opts.Concurrency, progress)
+	span.SetAttributes(attribute.Int("axiom.ingest.requests", chunks))
 	setIngestResultOnSpan(span, res)
+	s.client.metrics.recordIngestStatus(ctx, id, res)
+	if err != nil {
+		return &res, spanError(span, err)
+	}
 
 	return &res, nil
 }
@@ -444,7 +446,7 @@ func (s *DatasetsService) Query(ctx context.Context, q query.Query, options ...q
 		option(&opts.Options)
 	}
 
-	ctx, span := s.client.trace(ctx, "Datasets.Query", trace.WithAttributes(
+	ctx, span := s.client.trace(ctx, Operation{Name: "Datasets.Query"}, trace.WithAttributes(
 		attribute.String("axiom.param.query", string(q)),
 		attribute.String("axiom.param.start_time", opts.StartTime.String()),
 		attribute.String("axiom.param.end_time", opts.EndTime.String()),
@@ -483,6 +485,7 @@ func (s *DatasetsService) Query(ctx context.Context, q query.Query, options ...q
 	res.SavedQueryID = resp.Header.Get("X-Axiom-History-Query-Id")
 
 	setQueryResultOnSpan(span, res.Result)
+	s.client.metrics.recordQueryResult(ctx, res.Result)
 
 	return &res.Result, nil
 }
@@ -494,7 +497,7 @@ func (s *DatasetsService) Query(ctx context.Context, q query.Query, options ...q
 // Axiom Processing Language (APL) and the legacy query API will be removed in
 // the future. Use github.com/axiomhq/axiom-go/axiom/query instead.
 func (s *DatasetsService) QueryLegacy(ctx context.Context, id string, q querylegacy.Query, opts querylegacy.Options) (*querylegacy.Result, error) {
-	ctx, span := s.client.trace(ctx, "Datasets.QueryLegacy", trace.WithAttributes(
+	ctx, span := s.client.trace(ctx, Operation{Name: "Datasets.QueryLegacy", DatasetID: id}, trace.WithAttributes(
 		attribute.String("axiom.dataset_id", id),
 	))
 	defer span.End()
@@ -531,55 +534,831 @@ func (s *DatasetsService) QueryLegacy(ctx context.Context, id string, q queryleg
 	res.SavedQueryID = resp.Header.Get("X-Axiom-History-Query-Id")
 
 	setQueryResultOnSpan(span, query.Result(res.Result))
+	s.client.metrics.recordQueryResult(ctx, query.Result(res.Result))
 
 	return &res.Result, nil
 }
 
 // DetectContentType detects the content type of an io.Reader's data. The
-// returned io.Reader must be used instead of the passed one. Compressed content
-// is not detected.
+// returned io.Reader must be used instead of the passed one. It returns the
+// data as is, except for a leading UTF-8 byte order mark, which is removed.
+//
+// The content type is detected just like `DetectContent` does for
+// uncompressed data. Other than `DetectContent`, it doesn't look for the magic
+// bytes of gzip or zstd compressed data, so compressed content is not
+// detected.
 func DetectContentType(r io.Reader) (io.Reader, ContentType, error) {
+	br := bufio.NewReaderSize(r, detectPeekSize)
+
+	typ, err := detectType(br)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return br, typ, nil
+}
+
+// DetectContent detects the content type and content encoding of an
+// io.Reader's data. Gzip and zstd compressed content is recognized and its
+// content type is detected from the decompressed data. The returned io.Reader
+// must be used instead of the passed one. It returns the data as is, except
+// for a leading UTF-8 byte order mark, which is removed from uncompressed data.
+//
+// Detection only looks at the beginning of the data. A JSON array or a JSON
+// object which spans multiple lines is detected as `JSON`, single line JSON
+// objects as `NDJSON`.
+func DetectContent(r io.Reader) (io.Reader, ContentType, ContentEncoding, error) {
+	br := bufio.NewReaderSize(r, detectPeekSize)
+
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, 0, 0, err
+	}
+
+	var enc ContentEncoding
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		enc = Gzip
+	case bytes.HasPrefix(magic, zstdMagic):
+		enc = Zstd
+	default:
+		typ, err := detectType(br)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		return br, typ, Identity, nil
+	}
+
+	// Decompress the beginning of the data to detect its content type while
+	// keeping the compressed data consumed in order to return it.
 	var (
-		br  = bufio.NewReader(r)
-		typ ContentType
+		consumed bytes.Buffer
+		tr       = io.TeeReader(br, &consumed)
+		typ      ContentType
 	)
-	for {
-		var (
-			c   rune
-			err error
-		)
-		if c, _, err = br.ReadRune(); err == io.EOF {
-			return nil, 0, errors.New("couldn't find beginning of supported ingestion format")
-		} else if err != nil {
-			return nil, 0, err
-		} else if c == '[' {
-			typ = JSON
-		} else if c == '{' {
-			typ = NDJSON
-		} else if unicode.IsLetter(c) || c == '"' { // We assume a CSV table starts with a letter or a quote.
-			typ = CSV
-		} else if unicode.IsSpace(c) {
-			continue
+	switch enc {
+	case Gzip:
+		gzr, err := gzip.NewReader(tr)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		defer gzr.Close()
+
+		typ, err = detectType(bufio.NewReaderSize(gzr, detectPeekSize))
+		if err != nil {
+			return nil, 0, 0, err
+		}
+	case Zstd:
+		zsr, err := zstd.NewReader(tr, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		defer zsr.Close()
+
+		typ, err = detectType(bufio.NewReaderSize(zsr, detectPeekSize))
+		if err != nil {
+			return nil, 0, 0, err
+		}
+	}
+
+	return io.MultiReader(&consumed, br), typ, enc, nil
+}
+
+const detectPeekSize = 4096
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	utf8BOM   = []byte{0xef, 0xbb, 0xbf}
+)
+
+// detectType detects the content type of the data buffered by the given
+// reader, which looks at up to `detectPeekSize` bytes. A leading UTF-8 byte
+// order mark is discarded from the reader.
+func detectType(br *bufio.Reader) (ContentType, error) {
+	b, err := br.Peek(detectPeekSize)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	if bytes.HasPrefix(b, utf8BOM) {
+		if _, err = br.Discard(len(utf8BOM)); err != nil {
+			return 0, err
+		}
+		b = b[len(utf8BOM):]
+	}
+
+	b = bytes.TrimLeftFunc(b, unicode.IsSpace)
+	if len(b) == 0 {
+		return 0, errors.New("couldn't find beginning of supported ingestion format")
+	}
+
+	switch c, _ := utf8.DecodeRune(b); {
+	case c == '[':
+		return JSON, nil
+	case c == '{':
+		if spansMultipleLines(b) {
+			return JSON, nil
+		}
+		return NDJSON, nil
+	case unicode.IsLetter(c) || c == '"':
+		// We assume a CSV table starts with a header of letters or quotes.
+		return CSV, nil
+	}
+
+	// A CSV header might also start with other characters, but it contains
+	// more than one column.
+	line, _, _ := bytes.Cut(b, []byte{'\n'})
+	if bytes.ContainsRune(line, ',') {
+		return CSV, nil
+	}
+
+	return 0, errors.New("cannot determine content type")
+}
+
+// spansMultipleLines reports if the JSON object at the beginning of the given
+// data spans multiple lines, as found in pretty printed JSON. A JSON object
+// which doesn't end within the data is considered to be on a single line, if no
+// line break was found up to that point.
+func spansMultipleLines(b []byte) bool {
+	var (
+		depth    int
+		inString bool
+		escaped  bool
+	)
+	for _, c := range b {
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '\n':
+			return true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			if depth--; depth == 0 {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+// encodeZstdNDJSON returns a reader which streams the zstd compressed NDJSON
+// written by the given encode function. If onValue is given, it is called with
+// the size of every value encoded. Encoding only starts once the reader is
+// read from, so closing it before doesn't consume any values.
+func encodeZstdNDJSON(encode func(*json.Encoder) error, onValue func(n int)) *ndjsonEncoder {
+	pr, pw := io.Pipe()
+	r := &ndjsonEncoder{
+		PipeReader: pr,
+		done:       make(chan struct{}),
+	}
+	r.start = func() {
+		defer close(r.done)
+
+		zsw, wErr := getZstdWriter(pw, zstd.SpeedDefault)
+		if wErr != nil {
+			_ = pw.CloseWithError(wErr)
+			return
+		}
+		defer putZstdWriter(zsw, zstd.SpeedDefault)
+
+		var w io.Writer = zsw
+		if onValue != nil {
+			w = &valueCounter{Writer: zsw, onValue: onValue}
+		}
+
+		encErr := encode(json.NewEncoder(w))
+
+		if closeErr := zsw.Close(); encErr == nil {
+			// If we have no error from encoding but from closing, capture that
+			// one.
+			encErr = closeErr
+		}
+		_ = pw.CloseWithError(encErr)
+	}
+	return r
+}
+
+// ndjsonEncoder is the reader returned by `encodeZstdNDJSON`.
+type ndjsonEncoder struct {
+	*io.PipeReader
+
+	once  sync.Once
+	start func()
+	done  chan struct{}
+}
+
+// Read starts the encoding on the first call.
+func (r *ndjsonEncoder) Read(p []byte) (int, error) {
+	r.once.Do(func() { go r.start() })
+	return r.PipeReader.Read(p)
+}
+
+// Close stops the encoding or prevents it from starting at all.
+func (r *ndjsonEncoder) Close() error {
+	r.once.Do(func() { close(r.done) })
+	return r.PipeReader.Close()
+}
+
+// Done returns a channel that is closed once the encoding has finished or was
+// prevented from starting.
+func (r *ndjsonEncoder) Done() <-chan struct{} {
+	return r.done
+}
+
+// valueCounter calls a function with the size of every write. As a
+// `json.Encoder` writes each value in a single call, that is the size of every
+// value encoded.
+type valueCounter struct {
+	io.Writer
+
+	onValue func(n int)
+}
+
+func (w *valueCounter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.onValue(n)
+	return n, err
+}
+
+// ingestValues ingests the given values, which are encoded as JSON objects,
+// into the dataset identified by its id. If a prepare function is given, it is
+// applied to every value before it is sent. See `DatasetsService.IngestEvents`.
+func ingestValues[T any](ctx context.Context, s *DatasetsService, span trace.Span, id string, values []T, options []ingest.Option, prepare prepareFunc[T]) (*ingest.Status, error) {
+	// Apply supplied options.
+	var opts ingest.Options
+	for _, option := range options {
+		option(&opts)
+	}
+
+	progress := newProgressTracker(opts.Progress)
+	defer progress.done()
+
+	values, indices, rejected := prepareValues(values, prepare)
+
+	if len(values) == 0 {
+		setIngestResultOnSpan(span, rejected)
+		s.client.metrics.recordIngestStatus(ctx, id, rejected)
+		return &rejected, nil
+	}
+
+	path, err := AddOptions(s.basePath+"/"+id+"/ingest", opts)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+
+	batches, err := splitValues(values, opts)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+	span.SetAttributes(attribute.Int("axiom.ingest.requests", len(batches)))
+
+	if len(batches) == 1 {
+		res, err := ingestBatch(ctx, s, path, batches[0], progress)
+		if err != nil {
+			s.client.metrics.recordIngestStatus(ctx, id, rejected)
+			return nil, spanError(span, err)
+		}
+		indexFailures(res.Failures, batches[0].values, valueIndex(indices, 0), opts)
+
+		if rejected.Failed > 0 {
+			rejected.Add(res)
+			res = &rejected
+		}
+
+		setIngestResultOnSpan(span, *res)
+		s.client.metrics.recordIngestStatus(ctx, id, *res)
+
+		return res, nil
+	}
+
+	res, err := ingestBatches(ctx, s, path, batches, indices, opts, progress)
+	if rejected.Failed > 0 {
+		rejected.Add(&res)
+		res = rejected
+	}
+
+	setIngestResultOnSpan(span, res)
+	s.client.metrics.recordIngestStatus(ctx, id, res)
+
+	if err != nil {
+		return &res, spanError(span, err)
+	}
+	return &res, nil
+}
+
+// ingestChannel ingests the values consumed from the given channel, which are
+// encoded as JSON objects, into the dataset identified by its id. If a prepare
+// function is given, it is applied to every value before it is sent. See
+// `DatasetsService.IngestChannel`.
+func ingestChannel[T any](ctx context.Context, s *DatasetsService, span trace.Span, id string, values <-chan T, options []ingest.Option, prepare prepareFunc[T]) (*ingest.Status, error) {
+	// Apply supplied options.
+	var opts ingest.Options
+	for _, option := range options {
+		option(&opts)
+	}
+
+	progress := newProgressTracker(opts.Progress)
+	defer progress.done()
+
+	path, err := AddOptions(s.basePath+"/"+id+"/ingest", opts)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+
+	var (
+		res      ingest.Status
+		requests int
+
+		// pending is the value that was consumed from the channel but didn't
+		// fit into the previous request. closed is set as soon as the channel
+		// is drained. rejected holds the values rejected as invalid and
+		// consumed counts the values received from the channel. sent holds
+		// the values sent with the current request and sentIndices the order
+		// they were received in, to map the failures reported for them.
+		// They are only accessed by the encoding goroutine while a request is
+		// sent.
+		pending      T
+		pendingIndex int
+		hasPending   bool
+		closed       bool
+		rejected     ingest.Status
+		consumed     int
+		sent         []T
+		sentIndices  []int
+	)
+
+	// receive returns the next value from the channel which is to be sent,
+	// if any, and the index it was received at.
+	receive := func() (T, int, bool) {
+		for v := range values {
+			consumed++
+			if prepare == nil {
+				return v, consumed - 1, true
+			}
+			v, ok, failure := prepare(v)
+			if ok {
+				return v, consumed - 1, true
+			} else if failure != nil {
+				failure.Index = consumed - 1
+				rejected.Failed++
+				rejected.Rejected = append(rejected.Rejected, failure)
+			}
+		}
+		var zero T
+		return zero, 0, false
+	}
+
+	for requests == 0 || !closed {
+		// Don't start a request before there is another value to send.
+		if requests > 0 && !hasPending {
+			if pending, pendingIndex, hasPending = receive(); !hasPending {
+				break
+			}
+		}
+
+		sent, sentIndices = sent[:0], sentIndices[:0]
+		pr := encodeZstdNDJSON(func(enc *json.Encoder) error {
+			var count, size int
+			for {
+				if opts.MaxEvents > 0 && count >= opts.MaxEvents {
+					return nil
+				}
+
+				v, i := pending, pendingIndex
+				if !hasPending {
+					var ok bool
+					if v, i, ok = receive(); !ok {
+						closed = true
+						return nil
+					}
+				}
+				hasPending = false
+
+				if opts.MaxBytes <= 0 {
+					sent, sentIndices = append(sent, v), append(sentIndices, i)
+					if err := enc.Encode(v); err != nil {
+						return err
+					}
+					count++
+					continue
+				}
+
+				b, err := json.Marshal(v)
+				if err != nil {
+					return err
+				}
+				if count > 0 && size+len(b)+1 > opts.MaxBytes {
+					pending, pendingIndex, hasPending = v, i, true
+					return nil
+				}
+				sent, sentIndices = append(sent, v), append(sentIndices, i)
+				if err = enc.Encode(json.RawMessage(b)); err != nil {
+					return err
+				}
+				count++
+				size += len(b) + 1
+			}
+		}, progress.encoded)
+
+		req, err := s.client.NewRequest(ctx, http.MethodPost, path, pr)
+		if err != nil {
+			_ = pr.Close()
+			return nil, spanError(span, err)
+		}
+
+		req.Header.Set("Content-Type", NDJSON.String())
+		req.Header.Set("Content-Encoding", Zstd.String())
+		progress.trackBody(req, false)
+
+		var batchRes ingest.Status
+		_, err = s.client.Do(req, &batchRes)
+		progress.completed()
+		if err != nil {
+			// The values consumed for the failed request are lost, including
+			// the one which didn't fit into it anymore.
+			_ = pr.Close()
+			<-pr.Done()
+			failed := len(sent)
+			if hasPending {
+				failed++
+			}
+			s.client.metrics.recordFailedEvents(ctx, failed)
+
+			if rejected.Failed > 0 {
+				rejected.Add(&res)
+				res = rejected
+			}
+			s.client.metrics.recordIngestStatus(ctx, id, res)
+
+			if requests == 0 {
+				return nil, spanError(span, err)
+			}
+			setIngestResultOnSpan(span, res)
+			return &res, spanError(span, err)
+		}
+		<-pr.Done()
+		indexFailures(batchRes.Failures, sent, valueIndex(sentIndices, 0), opts)
+
+		if requests++; requests == 1 {
+			res = batchRes
 		} else {
-			return nil, 0, errors.New("cannot determine content type")
+			res.Add(&batchRes)
+		}
+	}
+	span.SetAttributes(attribute.Int("axiom.ingest.requests", requests))
+
+	if rejected.Failed > 0 {
+		rejected.Add(&res)
+		res = rejected
+	}
+
+	setIngestResultOnSpan(span, res)
+	s.client.metrics.recordIngestStatus(ctx, id, res)
+
+	return &res, nil
+}
+
+// prepareFunc prepares a value before it is sent. It returns the value to send
+// and if it is to be sent at all. If the value is rejected as invalid, it also
+// returns the failure to report for it. Values dropped otherwise, e.g. by a
+// processor, are not reported.
+type prepareFunc[T any] func(v T) (T, bool, *ingest.Failure)
+
+// eventPreparer returns a function which passes events through the processor
+// and then the validator configured in the given options, if any.
+func eventPreparer(options []ingest.Option) prepareFunc[Event] {
+	var opts ingest.Options
+	for _, option := range options {
+		option(&opts)
+	}
+
+	if opts.Processor == nil && opts.Validator == nil {
+		return nil
+	}
+
+	var v ingest.Validator
+	if opts.Validator != nil {
+		// The validator defaults to the timestamp settings of the ingestion.
+		v = *opts.Validator
+		if v.TimestampField == "" {
+			v.TimestampField = opts.TimestampField
+		}
+		if v.TimestampFormat == "" {
+			v.TimestampFormat = opts.TimestampFormat
+		}
+	}
+
+	return func(event Event) (Event, bool, *ingest.Failure) {
+		if opts.Processor != nil {
+			if event = opts.Processor.Process(event); event == nil {
+				return nil, false, nil
+			}
+		}
+
+		if opts.Validator == nil {
+			return event, true, nil
+		}
+
+		res, err := v.Validate(event)
+		if res != nil {
+			return res, true, nil
+		}
+
+		failure := &ingest.Failure{Error: err.Error()}
+		field := v.TimestampField
+		if field == "" {
+			field = ingest.TimestampField
 		}
+		failure.Timestamp, _ = eventTimestamp(event[field], v.TimestampFormat)
+
+		return nil, false, failure
+	}
+}
 
-		if err = br.UnreadRune(); err != nil {
-			return nil, 0, err
+// prepareValues returns the given values which are to be sent, as returned by
+// the given prepare function, the index of the value each one was prepared
+// from and the status of the ones rejected as invalid. The indices are nil, if
+// there is no prepare function. The failures of the rejected values are kept
+// apart from the ones reported by the server, as their order relative to each
+// other is unknown.
+func prepareValues[T any](values []T, prepare prepareFunc[T]) ([]T, []int, ingest.Status) {
+	var rejected ingest.Status
+	if prepare == nil {
+		return values, nil, rejected
+	}
+
+	var (
+		res     = make([]T, 0, len(values))
+		indices = make([]int, 0, len(values))
+	)
+	for i, v := range values {
+		v, ok, failure := prepare(v)
+		if ok {
+			res = append(res, v)
+			indices = append(indices, i)
+		} else if failure != nil {
+			failure.Index = i
+			rejected.Failed++
+			rejected.Rejected = append(rejected.Rejected, failure)
 		}
-		break
 	}
+	return res, indices, rejected
+}
 
-	// Create a new reader and prepend what we have already consumed in order to
-	// figure out the content type.
-	buf, err := br.Peek(br.Buffered())
+// valueIndex returns a function which returns the index of the k-th value of
+// a batch among the values passed to the ingest method, given the first value
+// of the batch and the indices returned by `prepareValues`.
+func valueIndex(indices []int, first int) func(k int) int {
+	return func(k int) int {
+		if indices == nil {
+			return first + k
+		}
+		return indices[first+k]
+	}
+}
+
+// valueBatch is a part of the values to ingest that is sent in a request of
+// its own. First is the position of its first value among all values to
+// ingest. If the values had to be encoded to determine their size, raw holds
+// them encoded as NDJSON. If they had to be compressed, too, data holds the
+// compressed raw values.
+type valueBatch[T any] struct {
+	values []T
+	first  int
+	raw    []byte
+	data   []byte
+}
+
+// splitValues splits the values into batches which respect the caps configured
+// in the given options. To respect a size cap, the values are encoded once and
+// the encoded values are kept to be sent as is.
+func splitValues[T any](values []T, opts ingest.Options) ([]valueBatch[T], error) {
+	if opts.MaxBytes <= 0 && opts.MaxCompressedBytes <= 0 {
+		if opts.MaxEvents <= 0 {
+			return []valueBatch[T]{{values: values}}, nil
+		}
+		var batches []valueBatch[T]
+		for start := 0; start < len(values); start += opts.MaxEvents {
+			end := start + opts.MaxEvents
+			if end > len(values) {
+				end = len(values)
+			}
+			batches = append(batches, valueBatch[T]{values: values[start:end:end], first: start})
+		}
+		return batches, nil
+	}
+
+	// offsets holds the offset of every encoded value in raw, followed by the
+	// length of raw.
+	var (
+		raw     bytes.Buffer
+		enc     = json.NewEncoder(&raw)
+		offsets = make([]int, 1, len(values)+1)
+	)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+		offsets = append(offsets, raw.Len())
+	}
+
+	var (
+		batches []valueBatch[T]
+		start   int
+	)
+	for i := range values {
+		if i > start && ((opts.MaxEvents > 0 && i-start >= opts.MaxEvents) ||
+			(opts.MaxBytes > 0 && offsets[i+1]-offsets[start] > opts.MaxBytes)) {
+			batches = append(batches, valueBatch[T]{
+				values: values[start:i:i],
+				first:  start,
+				raw:    raw.Bytes()[offsets[start]:offsets[i]:offsets[i]],
+			})
+			start = i
+		}
+	}
+	batches = append(batches, valueBatch[T]{
+		values: values[start:],
+		first:  start,
+		raw:    raw.Bytes()[offsets[start]:],
+	})
+
+	if opts.MaxCompressedBytes <= 0 {
+		return batches, nil
+	}
+
+	res := make([]valueBatch[T], 0, len(batches))
+	for _, batch := range batches {
+		n := len(batch.values)
+		batchOffsets := rebaseOffsets(offsets[batch.first : batch.first+n+1])
+
+		compressed, err := splitCompressed(batch, batchOffsets, opts.MaxCompressedBytes)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, compressed...)
+	}
+	return res, nil
+}
+
+// splitCompressed compresses the encoded values of the given batch and splits
+// them in halves until their compressed size doesn't exceed the given maximum.
+// The offsets locate the values in the raw values of the batch, followed by
+// their length.
+func splitCompressed[T any](batch valueBatch[T], offsets []int, max int) ([]valueBatch[T], error) {
+	data, err := compressChunk(batch.raw)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
+	}
+
+	if len(data) <= max || len(batch.values) == 1 {
+		batch.data = data
+		return []valueBatch[T]{batch}, nil
+	}
+
+	mid := len(batch.values) / 2
+	left, err := splitCompressed(valueBatch[T]{
+		values: batch.values[:mid:mid],
+		first:  batch.first,
+		raw:    batch.raw[:offsets[mid]:offsets[mid]],
+	}, offsets[:mid+1], max)
+	if err != nil {
+		return nil, err
+	}
+	right, err := splitCompressed(valueBatch[T]{
+		values: batch.values[mid:],
+		first:  batch.first + mid,
+		raw:    batch.raw[offsets[mid]:],
+	}, rebaseOffsets(offsets[mid:]), max)
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
+
+// rebaseOffsets returns the given offsets relative to the first one.
+func rebaseOffsets(offsets []int) []int {
+	res := make([]int, len(offsets))
+	for i, off := range offsets {
+		res[i] = off - offsets[0]
+	}
+	return res
+}
+
+// ingestBatch sends a single batch of values.
+func ingestBatch[T any](ctx context.Context, s *DatasetsService, path string, batch valueBatch[T], progress *progressTracker) (*ingest.Status, error) {
+	// Unless already encoded, the values are encoded on demand. This allows
+	// the encoding to be repeated, in case the request is retried. Only the
+	// first encoding counts towards the progress.
+	var body io.Reader
+	if batch.raw != nil {
+		data := batch.data
+		if data == nil {
+			var err error
+			if data, err = compressChunk(batch.raw); err != nil {
+				return nil, err
+			}
+		}
+		body = bytes.NewReader(data)
+		progress.read(len(batch.raw), len(batch.values))
+	} else {
+		body = encodeZstdNDJSON(valuesEncoder(batch.values), progress.encoded)
 	}
-	alreadyRead := bytes.NewReader(buf)
-	r = io.MultiReader(alreadyRead, r)
 
-	return r, typ, nil
+	req, err := s.client.NewRequest(ctx, http.MethodPost, path, body)
+	if err != nil {
+		return nil, err
+	}
+	if batch.raw == nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			return encodeZstdNDJSON(valuesEncoder(batch.values), nil), nil
+		}
+	}
+
+	req.Header.Set("Content-Type", NDJSON.String())
+	req.Header.Set("Content-Encoding", Zstd.String())
+	progress.trackBody(req, false)
+
+	var res ingest.Status
+	_, err = s.client.Do(req, &res)
+	progress.completed()
+	if err != nil {
+		s.client.metrics.recordFailedEvents(ctx, len(batch.values))
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ingestBatches sends the given batches of values using the configured amount
+// of concurrent requests and merges their statuses in order. All batches are
+// sent, even if some of them fail. The indices are the ones returned by
+// `prepareValues`.
+func ingestBatches[T any](ctx context.Context, s *DatasetsService, path string, batches []valueBatch[T], indices []int, opts ingest.Options, progress *progressTracker) (ingest.Status, error) {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		statuses = make([]*ingest.Status, len(batches))
+		errs     = make([]error, len(batches))
+		sem      = make(chan struct{}, concurrency)
+		wg       sync.WaitGroup
+	)
+	for i, batch := range batches {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, batch valueBatch[T]) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if statuses[i], errs[i] = ingestBatch(ctx, s, path, batch, progress); errs[i] == nil {
+				indexFailures(statuses[i].Failures, batch.values, valueIndex(indices, batch.first), opts)
+			}
+		}(i, batch)
+	}
+	wg.Wait()
+
+	var (
+		res      ingest.Status
+		firstErr error
+		failed   int
+	)
+	for i := range batches {
+		if errs[i] != nil {
+			if failed++; firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		res.Add(statuses[i])
+	}
+
+	if firstErr != nil {
+		return res, fmt.Errorf("%d of %d ingest requests failed: %w", failed, len(batches), firstErr)
+	}
+	return res, nil
+}
+
+// valuesEncoder returns a function for `encodeZstdNDJSON` which encodes the
+// given values.
+func valuesEncoder[T any](values []T) func(*json.Encoder) error {
+	return func(enc *json.Encoder) error {
+		for _, v := range values {
+			if err := enc.Encode(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 }
 
 func setIngestResultOnSpan(span trace.Span, res ingest.Status) {
@@ -3,13 +3,19 @@ package axiom
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
 	"time"
 
+	"github.com/klauspost/compress/gzip"
 	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -486,6 +492,590 @@ func TestDatasetsService_IngestEvents(t *testing.T) {
 	assert.Equal(t, exp, res)
 }
 
+func TestDatasetsService_IngestEvents_Retry(t *testing.T) {
+	var currentCalls int
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		zsr, err := zstd.NewReader(r.Body)
+		require.NoError(t, err)
+
+		var (
+			dec    = json.NewDecoder(zsr)
+			events int
+		)
+		for dec.More() {
+			var event Event
+			require.NoError(t, dec.Decode(&event))
+			events++
+		}
+		zsr.Close()
+
+		assert.Equal(t, 2, events)
+
+		if currentCalls++; currentCalls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err = fmt.Fprint(w, `{
+			"ingested": 2,
+			"failed": 0,
+			"failures": [],
+			"processedBytes": 630,
+			"blocksCreated": 0,
+			"walLength": 2
+		}`)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	events := []Event{
+		{"key": "value1"},
+		{"key": "value2"},
+	}
+
+	res, err := client.Datasets.IngestEvents(context.Background(), "test", events)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, currentCalls)
+	assert.EqualValues(t, 2, res.Ingested)
+}
+
+func TestDatasetsService_IngestEvents_Split(t *testing.T) {
+	events := make([]Event, 10)
+	for i := range events {
+		events[i] = Event{"n": i, "padding": strings.Repeat("a", 100)}
+	}
+
+	tests := []struct {
+		name    string
+		options []ingest.Option
+		exp     []int
+	}{
+		{
+			name: "no caps",
+			exp:  []int{10},
+		},
+		{
+			name:    "max events",
+			options: []ingest.Option{ingest.SetMaxEvents(4)},
+			exp:     []int{4, 4, 2},
+		},
+		{
+			name:    "max bytes",
+			options: []ingest.Option{ingest.SetMaxBytes(250)},
+			exp:     []int{2, 2, 2, 2, 2},
+		},
+		{
+			name:    "max bytes below single event",
+			options: []ingest.Option{ingest.SetMaxBytes(1), ingest.SetMaxEvents(8)},
+			exp:     []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+		},
+		{
+			name:    "max compressed bytes",
+			options: []ingest.Option{ingest.SetMaxCompressedBytes(1)},
+			exp:     []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+		},
+		{
+			name:    "concurrency",
+			options: []ingest.Option{ingest.SetMaxEvents(3), ingest.SetConcurrency(2)},
+			exp:     []int{3, 3, 3, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				requests []int
+				mtx      sync.Mutex
+			)
+			hf := func(w http.ResponseWriter, r *http.Request) {
+				zsr, err := zstd.NewReader(r.Body)
+				require.NoError(t, err)
+				defer zsr.Close()
+
+				var (
+					dec = json.NewDecoder(zsr)
+					n   int
+				)
+				for dec.More() {
+					var event Event
+					require.NoError(t, dec.Decode(&event))
+					n++
+				}
+
+				mtx.Lock()
+				requests = append(requests, n)
+				mtx.Unlock()
+
+				w.Header().Set("Content-Type", mediaTypeJSON)
+				_, err = fmt.Fprintf(w, `{"ingested":%d,"processedBytes":%d}`, n, 100*n)
+				assert.NoError(t, err)
+			}
+
+			client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+			res, err := client.Datasets.IngestEvents(context.Background(), "test", events, tt.options...)
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, tt.exp, requests)
+			assert.EqualValues(t, 10, res.Ingested)
+			assert.EqualValues(t, 1000, res.ProcessedBytes)
+		})
+	}
+}
+
+func TestDatasetsService_IngestEvents_SplitFailure(t *testing.T) {
+	var calls int
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		if calls++; calls == 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err := fmt.Fprint(w, `{"ingested":1}`)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	events := []Event{{"n": 1}, {"n": 2}, {"n": 3}}
+
+	res, err := client.Datasets.IngestEvents(context.Background(), "test", events, ingest.SetMaxEvents(1))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 of 3 ingest requests failed")
+
+	assert.Equal(t, 3, calls)
+	if assert.NotNil(t, res) {
+		assert.EqualValues(t, 2, res.Ingested)
+	}
+}
+
+func TestDatasetsService_IngestFile(t *testing.T) {
+	var (
+		chunks []string
+		mtx    sync.Mutex
+	)
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "text/csv", r.Header.Get("Content-Type"))
+		assert.Equal(t, "zstd", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "time", r.URL.Query().Get("timestamp-field"))
+
+		zsr, err := zstd.NewReader(r.Body)
+		require.NoError(t, err)
+		defer zsr.Close()
+
+		b, err := io.ReadAll(zsr)
+		require.NoError(t, err)
+
+		mtx.Lock()
+		chunks = append(chunks, string(b))
+		mtx.Unlock()
+
+		n := strings.Count(string(b), "\n") - 1
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err = fmt.Fprintf(w, `{"ingested":%d,"processedBytes":%d}`, n, len(b))
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	r := strings.NewReader("time,n\n1,1\n2,2\n3,3\n4,4\n5,5\n")
+
+	res, err := client.Datasets.IngestFile(context.Background(), "test", r, CSV,
+		ingest.SetTimestampField("time"),
+		ingest.SetMaxEvents(2),
+		ingest.SetConcurrency(2),
+	)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{
+		"time,n\n1,1\n2,2\n",
+		"time,n\n3,3\n4,4\n",
+		"time,n\n5,5\n",
+	}, chunks)
+	assert.EqualValues(t, 5, res.Ingested)
+	assert.EqualValues(t, 41, res.ProcessedBytes)
+}
+
+func TestDatasetsService_IngestFile_Retry(t *testing.T) {
+	var (
+		calls = make(map[string]int)
+		mtx   sync.Mutex
+	)
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		zsr, err := zstd.NewReader(r.Body)
+		require.NoError(t, err)
+		defer zsr.Close()
+
+		b, err := io.ReadAll(zsr)
+		require.NoError(t, err)
+
+		mtx.Lock()
+		calls[string(b)]++
+		n := calls[string(b)]
+		mtx.Unlock()
+
+		// The second chunk fails once and always, the third one.
+		if (string(b) == "{\"n\":2}\n" && n == 1) || string(b) == "{\"n\":3}\n" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err = fmt.Fprint(w, `{"ingested":1}`)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	policy := DefaultRetryPolicy()
+	policy.InitialInterval = time.Millisecond
+	policy.MaxAttempts = 3
+	require.NoError(t, client.Options(SetRetryPolicy(policy)))
+
+	r := strings.NewReader("{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n{\"n\":4}\n")
+
+	res, err := client.Datasets.IngestFile(context.Background(), "test", r, NDJSON, ingest.SetMaxEvents(1))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 of 4 ingest requests failed")
+
+	assert.Equal(t, map[string]int{
+		"{\"n\":1}\n": 1,
+		"{\"n\":2}\n": 2,
+		"{\"n\":3}\n": 3,
+		"{\"n\":4}\n": 1,
+	}, calls)
+	if assert.NotNil(t, res) {
+		assert.EqualValues(t, 3, res.Ingested)
+	}
+}
+
+func TestDatasetsService_IngestFile_ReadError(t *testing.T) {
+	var calls int
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err := fmt.Fprint(w, `{"ingested":1}`)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	readErr := errors.New("disk on fire")
+	r := io.MultiReader(strings.NewReader("{\"n\":1}\n"), iotest.ErrReader(readErr))
+
+	res, err := client.Datasets.IngestFile(context.Background(), "test", r, NDJSON, ingest.SetMaxEvents(1))
+	assert.ErrorIs(t, err, readErr)
+
+	assert.Equal(t, 1, calls)
+	if assert.NotNil(t, res) {
+		assert.EqualValues(t, 1, res.Ingested)
+	}
+}
+
+// exhaustIngestLimit sets up a client which ingest limit is exhausted.
+func exhaustIngestLimit(t *testing.T) *Client {
+	t.Helper()
+
+	var calls int
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		w.Header().Set(headerIngestLimit, "1000")
+		w.Header().Set(headerIngestRemaining, "0")
+		w.Header().Set(headerIngestReset, strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	_, err := client.Datasets.IngestEvents(context.Background(), "test", []Event{{"n": 1}})
+	require.ErrorAs(t, err, new(*LimitError))
+
+	t.Cleanup(func() { assert.Equal(t, 1, calls, "requests exceeding the limit must not be sent") })
+
+	return client
+}
+
+// assertNoGoroutineLeak asserts that the amount of goroutines returns to the
+// given one.
+func assertNoGoroutineLeak(t *testing.T, before int) {
+	t.Helper()
+
+	// Not using `assert.Eventually`, as it runs the condition in a goroutine
+	// of its own.
+	n := runtime.NumGoroutine()
+	for deadline := time.Now().Add(time.Second); n > before && time.Now().Before(deadline); n = runtime.NumGoroutine() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, n, before, "leaked %d goroutines", n-before)
+}
+
+func TestDatasetsService_Ingest_LimitExhausted(t *testing.T) {
+	client := exhaustIngestLimit(t)
+
+	before := runtime.NumGoroutine()
+
+	events := make(chan Event, 1)
+	events <- Event{"n": 1}
+	for i := 0; i < 50; i++ {
+		_, err := client.Datasets.IngestEvents(context.Background(), "test", []Event{{"n": 1}})
+		require.ErrorAs(t, err, new(*LimitError))
+
+		_, err = client.Datasets.IngestChannel(context.Background(), "test", events)
+		require.ErrorAs(t, err, new(*LimitError))
+	}
+
+	// The event is still there to be ingested later.
+	assert.Len(t, events, 1)
+
+	assertNoGoroutineLeak(t, before)
+}
+
+func TestDatasetsService_Ingest_LimitWaitCanceled(t *testing.T) {
+	client := exhaustIngestLimit(t)
+	require.NoError(t, client.Options(SetLimitWait(2*time.Hour)))
+
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := client.Datasets.IngestEvents(ctx, "test", []Event{{"n": 1}})
+		cancel()
+		require.ErrorAs(t, err, new(*LimitError))
+	}
+
+	assertNoGoroutineLeak(t, before)
+}
+
+func TestDatasetsService_IngestEvents_Validator(t *testing.T) {
+	var received []Event
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		zsr, err := zstd.NewReader(r.Body)
+		require.NoError(t, err)
+		defer zsr.Close()
+
+		var n int
+		for dec := json.NewDecoder(zsr); dec.More(); n++ {
+			var event Event
+			require.NoError(t, dec.Decode(&event))
+			received = append(received, event)
+		}
+
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err = fmt.Fprintf(w, `{"ingested":%d}`, n)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	events := []Event{
+		{"ts": "2020-03-18T13:56:21Z", "foo": "bar"},
+		{"ts": "2020-03-18T13:56:22Z", "_reserved": true},
+		{"ts": "yesterday", "foo": "baz"},
+	}
+
+	tests := []struct {
+		name        string
+		mode        ingest.ValidationMode
+		expReceived []Event
+		expIngested uint64
+		expRejected []*ingest.Failure
+	}{
+		{
+			name: "reject",
+			mode: ingest.ValidationReject,
+			expReceived: []Event{
+				{"ts": "2020-03-18T13:56:21Z", "foo": "bar"},
+			},
+			expIngested: 1,
+			expRejected: []*ingest.Failure{
+				{
+					Timestamp: time.Date(2020, 3, 18, 13, 56, 22, 0, time.UTC),
+					Error:     `invalid event: field "_reserved": field names starting with an underscore are reserved`,
+					Index:     1,
+				},
+				{
+					Error: `invalid event: field "ts": invalid timestamp`,
+					Index: 2,
+				},
+			},
+		},
+		{
+			name: "sanitize",
+			mode: ingest.ValidationSanitize,
+			expReceived: []Event{
+				{"ts": "2020-03-18T13:56:21Z", "foo": "bar"},
+				{"ts": "2020-03-18T13:56:22Z", "reserved": true},
+				{"ts_invalid": "yesterday", "foo": "baz"},
+			},
+			expIngested: 3,
+		},
+		{
+			name:        "report",
+			mode:        ingest.ValidationReport,
+			expReceived: events,
+			expIngested: 3,
+		},
+	}
+	for _, tt := range tests {
+		for name, ingestEvents := range map[string]func(...ingest.Option) (*ingest.Status, error){
+			"IngestEvents": func(options ...ingest.Option) (*ingest.Status, error) {
+				return client.Datasets.IngestEvents(context.Background(), "test", events, options...)
+			},
+			"IngestChannel": func(options ...ingest.Option) (*ingest.Status, error) {
+				eventCh := make(chan Event, len(events))
+				for _, event := range events {
+					eventCh <- event
+				}
+				close(eventCh)
+				return client.Datasets.IngestChannel(context.Background(), "test", eventCh, options...)
+			},
+		} {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				received = nil
+
+				var invalid int
+				validator := &ingest.Validator{
+					Mode:      tt.mode,
+					OnInvalid: func(map[string]any, *ingest.ValidationError) { invalid++ },
+				}
+
+				res, err := ingestEvents(
+					ingest.SetTimestampField("ts"),
+					ingest.SetValidator(validator),
+				)
+				require.NoError(t, err)
+
+				assert.Equal(t, tt.expReceived, received)
+				assert.Equal(t, tt.expIngested, res.Ingested)
+				assert.EqualValues(t, len(tt.expRejected), res.Failed)
+				assert.Equal(t, tt.expRejected, res.Rejected)
+				assert.Empty(t, res.Failures)
+				assert.Equal(t, 2, invalid)
+			})
+		}
+	}
+}
+
+func TestDatasetsService_IngestEvents_Processor(t *testing.T) {
+	var received []Event
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		zsr, err := zstd.NewReader(r.Body)
+		require.NoError(t, err)
+		defer zsr.Close()
+
+		var n int
+		for dec := json.NewDecoder(zsr); dec.More(); n++ {
+			var event Event
+			require.NoError(t, dec.Decode(&event))
+			received = append(received, event)
+		}
+
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err = fmt.Fprintf(w, `{"ingested":%d}`, n)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	events := []Event{
+		{"level": "info", "_secret": "a"},
+		{"level": "debug", "_secret": "b"},
+	}
+
+	res, err := client.Datasets.IngestEvents(context.Background(), "test", events,
+		ingest.SetProcessor(ingest.Pipeline{
+			ingest.Filter(func(event map[string]any) bool { return event["level"] != "debug" }),
+			ingest.DropFields("_secret"),
+		}),
+		// The processor runs first, so the validator doesn't see the reserved
+		// field anymore.
+		ingest.SetValidator(&ingest.Validator{}),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Event{{"level": "info"}}, received)
+	assert.EqualValues(t, 1, res.Ingested)
+	assert.Zero(t, res.Failed)
+}
+
+func TestDatasetsService_IngestEvents_FailureIndex(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		zsr, err := zstd.NewReader(r.Body)
+		require.NoError(t, err)
+		defer zsr.Close()
+
+		var res ingest.Status
+		for dec := json.NewDecoder(zsr); dec.More(); {
+			var event Event
+			require.NoError(t, dec.Decode(&event))
+
+			if event["fail"] != true {
+				res.Ingested++
+				continue
+			}
+			ts, err := time.Parse(time.RFC3339, event[ingest.TimestampField].(string))
+			require.NoError(t, err)
+			res.Failed++
+			res.Failures = append(res.Failures, &ingest.Failure{Timestamp: ts, Error: "invalid event"})
+		}
+
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		assert.NoError(t, json.NewEncoder(w).Encode(res))
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	var (
+		t1 = "2022-01-01T00:00:01Z"
+		t2 = "2022-01-01T00:00:02Z"
+	)
+	// The event dropped by the processor shares the timestamp of the one that
+	// fails, but must not be mistaken for it.
+	events := []Event{
+		{"_time": t1, "level": "info"},
+		{"_time": t2, "level": "debug"},
+		{"_time": t1, "level": "info"},
+		{"_time": t2, "level": "info", "fail": true},
+		{"_time": t1, "level": "info", "fail": true},
+	}
+	options := []ingest.Option{
+		ingest.SetProcessor(ingest.Filter(func(event map[string]any) bool { return event["level"] != "debug" })),
+		ingest.SetMaxEvents(2),
+	}
+
+	for name, ingestEvents := range map[string]func() (*ingest.Status, error){
+		"IngestEvents": func() (*ingest.Status, error) {
+			return client.Datasets.IngestEvents(context.Background(), "test", events, options...)
+		},
+		"IngestChannel": func() (*ingest.Status, error) {
+			eventCh := make(chan Event, len(events))
+			for _, event := range events {
+				eventCh <- event
+			}
+			close(eventCh)
+			return client.Datasets.IngestChannel(context.Background(), "test", eventCh, options...)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			res, err := ingestEvents()
+			require.NoError(t, err)
+
+			assert.EqualValues(t, 2, res.Ingested)
+			assert.EqualValues(t, 2, res.Failed)
+			assert.Equal(t, []FailedEvent{
+				{Event: events[3], Timestamp: time.Date(2022, 1, 1, 0, 0, 2, 0, time.UTC), Error: "invalid event"},
+				{Event: events[4], Timestamp: time.Date(2022, 1, 1, 0, 0, 1, 0, time.UTC), Error: "invalid event"},
+			}, FailedEvents(events, res))
+		})
+	}
+}
+
 func TestDatasetsService_IngestChannel(t *testing.T) {
 	exp := &ingest.Status{
 		Ingested:       2,
@@ -558,6 +1148,73 @@ func TestDatasetsService_IngestChannel(t *testing.T) {
 	assert.Equal(t, exp, res)
 }
 
+func TestDatasetsService_IngestChannel_Split(t *testing.T) {
+	var requests []int
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		zsr, err := zstd.NewReader(r.Body)
+		require.NoError(t, err)
+		defer zsr.Close()
+
+		var (
+			dec = json.NewDecoder(zsr)
+			n   int
+		)
+		for dec.More() {
+			var event Event
+			require.NoError(t, dec.Decode(&event))
+			n++
+		}
+		requests = append(requests, n)
+
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err = fmt.Fprintf(w, `{"ingested":%d}`, n)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	tests := []struct {
+		name    string
+		events  []Event
+		options []ingest.Option
+		exp     []int
+	}{
+		{
+			name:    "max events",
+			events:  []Event{{"n": 1}, {"n": 2}, {"n": 3}, {"n": 4}},
+			options: []ingest.Option{ingest.SetMaxEvents(2)},
+			exp:     []int{2, 2},
+		},
+		{
+			name:    "max bytes",
+			events:  []Event{{"n": 1}, {"n": 2}, {"n": 3}},
+			options: []ingest.Option{ingest.SetMaxBytes(16)},
+			exp:     []int{2, 1},
+		},
+		{
+			name: "empty",
+			exp:  []int{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+
+			eventCh := make(chan Event, len(tt.events))
+			for _, event := range tt.events {
+				eventCh <- event
+			}
+			close(eventCh)
+
+			res, err := client.Datasets.IngestChannel(context.Background(), "test", eventCh, tt.options...)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.exp, requests)
+			assert.EqualValues(t, len(tt.events), res.Ingested)
+		})
+	}
+}
+
 // TODO(lukasmalkmus): Write an ingest test that contains some failures in the
 // server response.
 
@@ -641,13 +1298,16 @@ func TestDetectContentType(t *testing.T) {
 		want    ContentType
 		wantErr string
 	}{
-		// FIXME(lukasmalkmus): The function incorrectly detects the content
-		// type as NDJSON as it strips newline characters from the input.
-		// {
-		// 	name:  "json - pretty",
-		// 	input: "{\n\"a\":\"b\"\n}",
-		// 	want:  JSON,
-		// },
+		{
+			name:  "json - pretty",
+			input: "{\n\"a\":\"b\"\n}",
+			want:  JSON,
+		},
+		{
+			name:  "json - pretty array",
+			input: "[\n  {\n    \"a\": \"b\"\n  }\n]",
+			want:  JSON,
+		},
 		{
 			name:  "json - multiline",
 			input: `[{"a":"b"}, {"c":"d"}]`,
@@ -671,6 +1331,16 @@ func TestDetectContentType(t *testing.T) {
 				2000,Mercury,Cougar,2.38`,
 			want: CSV,
 		},
+		{
+			name:  "ndjson - line break in string",
+			input: `{"a":"b\nc"}` + "\n" + `{"d":"}{"}`,
+			want:  NDJSON,
+		},
+		{
+			name:  "csv - numeric header",
+			input: "1,2\n3,4",
+			want:  CSV,
+		},
 		{
 			name:    "eof",
 			input:   "",
@@ -699,6 +1369,97 @@ func TestDetectContentType(t *testing.T) {
 	}
 }
 
+func TestDetectContent(t *testing.T) {
+	const ndjson = `{"a":"b"}` + "\n" + `{"c":"d"}`
+
+	tests := []struct {
+		name    string
+		input   string
+		encoder ContentEncoder
+		wantTyp ContentType
+		wantEnc ContentEncoding
+		want    string
+	}{
+		{
+			name:    "identity",
+			input:   ndjson,
+			wantTyp: NDJSON,
+			wantEnc: Identity,
+			want:    ndjson,
+		},
+		{
+			name:    "identity with bom",
+			input:   "\xef\xbb\xbf[\n{\"a\":\"b\"}\n]",
+			wantTyp: JSON,
+			wantEnc: Identity,
+			want:    "[\n{\"a\":\"b\"}\n]",
+		},
+		{
+			name:    "gzip",
+			input:   "Year,Make\n1997,Ford",
+			encoder: GzipEncoder,
+			wantTyp: CSV,
+			wantEnc: Gzip,
+			want:    "Year,Make\n1997,Ford",
+		},
+		{
+			name:    "gzip with bom",
+			input:   "\xef\xbb\xbf{\n\"a\":\"b\"\n}",
+			encoder: GzipEncoder,
+			wantTyp: JSON,
+			wantEnc: Gzip,
+			want:    "\xef\xbb\xbf{\n\"a\":\"b\"\n}",
+		},
+		{
+			name:    "zstd",
+			input:   strings.Repeat(ndjson+"\n", 1000),
+			encoder: ZstdEncoder,
+			wantTyp: NDJSON,
+			wantEnc: Zstd,
+			want:    strings.Repeat(ndjson+"\n", 1000),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r io.Reader = strings.NewReader(tt.input)
+			if tt.encoder != nil {
+				var err error
+				r, err = tt.encoder(r)
+				require.NoError(t, err)
+			}
+
+			r, typ, enc, err := DetectContent(r)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantTyp.String(), typ.String())
+			assert.Equal(t, tt.wantEnc.String(), enc.String())
+
+			switch enc {
+			case Gzip:
+				r, err = gzip.NewReader(r)
+				require.NoError(t, err)
+			case Zstd:
+				zsr, err := zstd.NewReader(r)
+				require.NoError(t, err)
+				defer zsr.Close()
+				r = zsr
+			}
+
+			if b, err := io.ReadAll(r); assert.NoError(t, err) {
+				assert.Equal(t, tt.want, string(b))
+			}
+		})
+	}
+}
+
+func TestDetectContent_Invalid(t *testing.T) {
+	r, err := GzipEncoder(strings.NewReader("123"))
+	require.NoError(t, err)
+
+	_, _, _, err = DetectContent(r)
+	assert.EqualError(t, err, "cannot determine content type")
+}
+
 func assertValidJSON(t *testing.T, r io.Reader) {
 	dec := json.NewDecoder(r)
 	var v any
@@ -1,12 +1,21 @@
 package axiom
 
 import (
+	"fmt"
 	"io"
+	"sync"
 
 	"github.com/klauspost/compress/gzip"
 	"github.com/klauspost/compress/zstd"
 )
 
+// Compressing writers are expensive to create, so they are pooled per
+// compression level and reset for every use.
+var (
+	gzipWriterPools [gzip.BestCompression - gzip.StatelessCompression + 1]sync.Pool
+	zstdWriterPools [zstd.SpeedBestCompression + 1]sync.Pool
+)
+
 // ContentEncoder is a function that wraps a given `io.Reader` with encoding
 // functionality and returns that enhanced reader. The content type of the
 // encoded content must obviously be accepted by the server.
@@ -26,12 +35,14 @@ func GzipEncoderWithLevel(level int) ContentEncoder {
 	return func(r io.Reader) (io.Reader, error) {
 		pr, pw := io.Pipe()
 
-		gzw, err := gzip.NewWriterLevel(pw, level)
+		gzw, err := getGzipWriter(pw, level)
 		if err != nil {
 			return nil, err
 		}
 
 		go func() {
+			defer putGzipWriter(gzw, level)
+
 			_, err := io.Copy(gzw, r)
 			if closeErr := gzw.Close(); err == nil {
 				// If we have no error from copying but from closing, capture
@@ -46,24 +57,77 @@ func GzipEncoderWithLevel(level int) ContentEncoder {
 }
 
 // ZstdEncoder is a `ContentEncoder` that zstd compresses the data it reads
-// from the provided reader.
+// from the provided reader. The compression level defaults to
+// `zstd.SpeedDefault`.
 func ZstdEncoder(r io.Reader) (io.Reader, error) {
-	pr, pw := io.Pipe()
+	return ZstdEncoderWithLevel(zstd.SpeedDefault)(r)
+}
 
-	zsw, err := zstd.NewWriter(pw)
-	if err != nil {
-		return nil, err
-	}
+// ZstdEncoderWithLevel returns a `ContentEncoder` that zstd compresses data
+// using the specified compression level.
+func ZstdEncoderWithLevel(level zstd.EncoderLevel) ContentEncoder {
+	return func(r io.Reader) (io.Reader, error) {
+		pr, pw := io.Pipe()
 
-	go func() {
-		_, err := io.Copy(zsw, r)
-		if closeErr := zsw.Close(); err == nil {
-			// If we have no error from copying but from closing, capture that
-			// one.
-			err = closeErr
+		zsw, err := getZstdWriter(pw, level)
+		if err != nil {
+			return nil, err
 		}
-		_ = pw.CloseWithError(err)
-	}()
 
-	return pr, nil
+		go func() {
+			defer putZstdWriter(zsw, level)
+
+			_, err := io.Copy(zsw, r)
+			if closeErr := zsw.Close(); err == nil {
+				// If we have no error from copying but from closing, capture
+				// that one.
+				err = closeErr
+			}
+			_ = pw.CloseWithError(err)
+		}()
+
+		return pr, nil
+	}
+}
+
+// getGzipWriter returns a pooled gzip writer with the given compression level
+// which writes to the given writer. It must be returned to the pool using
+// `putGzipWriter` once closed.
+func getGzipWriter(w io.Writer, level int) (*gzip.Writer, error) {
+	if level < gzip.StatelessCompression || level > gzip.BestCompression {
+		return nil, fmt.Errorf("invalid gzip compression level: %d", level)
+	}
+
+	if gzw, ok := gzipWriterPools[level-gzip.StatelessCompression].Get().(*gzip.Writer); ok {
+		gzw.Reset(w)
+		return gzw, nil
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+func putGzipWriter(gzw *gzip.Writer, level int) {
+	// Don't keep a reference to the destination around.
+	gzw.Reset(io.Discard)
+	gzipWriterPools[level-gzip.StatelessCompression].Put(gzw)
+}
+
+// getZstdWriter returns a pooled zstd writer with the given compression level
+// which writes to the given writer. It must be returned to the pool using
+// `putZstdWriter` once closed.
+func getZstdWriter(w io.Writer, level zstd.EncoderLevel) (*zstd.Encoder, error) {
+	if level < zstd.SpeedFastest || level > zstd.SpeedBestCompression {
+		return nil, fmt.Errorf("invalid zstd compression level: %d", level)
+	}
+
+	if zsw, ok := zstdWriterPools[level].Get().(*zstd.Encoder); ok {
+		zsw.Reset(w)
+		return zsw, nil
+	}
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(level))
+}
+
+func putZstdWriter(zsw *zstd.Encoder, level zstd.EncoderLevel) {
+	// Don't keep a reference to the destination around.
+	zsw.Reset(nil)
+	zstdWriterPools[level].Put(zsw)
 }
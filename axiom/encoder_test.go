@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -51,6 +52,60 @@ func TestZstdEncoder(t *testing.T) {
 	assert.Equal(t, exp, string(act))
 }
 
+func TestGzipEncoderWithLevel(t *testing.T) {
+	exp := "Some fox jumps over a fence."
+
+	for level := gzip.StatelessCompression; level <= gzip.BestCompression; level++ {
+		t.Run(strconv.Itoa(level), func(t *testing.T) {
+			// Run twice to make sure pooled encoders are reset properly.
+			for i := 0; i < 2; i++ {
+				r, err := GzipEncoderWithLevel(level)(strings.NewReader(exp))
+				require.NoError(t, err)
+
+				gzr, err := gzip.NewReader(r)
+				require.NoError(t, err)
+
+				act, err := io.ReadAll(gzr)
+				require.NoError(t, err)
+				require.NoError(t, gzr.Close())
+
+				assert.Equal(t, exp, string(act))
+			}
+		})
+	}
+}
+
+func TestZstdEncoderWithLevel(t *testing.T) {
+	exp := "Some fox jumps over a fence."
+
+	for level := zstd.SpeedFastest; level <= zstd.SpeedBestCompression; level++ {
+		t.Run(level.String(), func(t *testing.T) {
+			// Run twice to make sure pooled encoders are reset properly.
+			for i := 0; i < 2; i++ {
+				r, err := ZstdEncoderWithLevel(level)(strings.NewReader(exp))
+				require.NoError(t, err)
+
+				zsr, err := zstd.NewReader(r)
+				require.NoError(t, err)
+
+				act, err := io.ReadAll(zsr)
+				zsr.Close()
+				require.NoError(t, err)
+
+				assert.Equal(t, exp, string(act))
+			}
+		})
+	}
+}
+
+func TestEncoderWithLevel_Invalid(t *testing.T) {
+	_, err := GzipEncoderWithLevel(42)(strings.NewReader(""))
+	assert.EqualError(t, err, "invalid gzip compression level: 42")
+
+	_, err = ZstdEncoderWithLevel(42)(strings.NewReader(""))
+	assert.EqualError(t, err, "invalid zstd compression level: 42")
+}
+
 func BenchmarkEncoder(b *testing.B) {
 	data := testdata.Load(b)
 
@@ -66,9 +121,18 @@ func BenchmarkEncoder(b *testing.B) {
 			name:    "zstd",
 			encoder: ZstdEncoder,
 		},
+		{
+			name:    "zstd-fastest",
+			encoder: ZstdEncoderWithLevel(zstd.SpeedFastest),
+		},
+		{
+			name:    "zstd-best",
+			encoder: ZstdEncoderWithLevel(zstd.SpeedBestCompression),
+		},
 	}
 	for _, bb := range benchmarks {
 		b.Run(fmt.Sprintf("encoder=%s", bb.name), func(b *testing.B) {
+			b.ReportAllocs()
 			for i := 0; i < b.N; i++ {
 				r, err := bb.encoder(bytes.NewReader(data))
 				require.NoError(b, err)
@@ -82,3 +146,35 @@ func BenchmarkEncoder(b *testing.B) {
 		})
 	}
 }
+
+// BenchmarkZstdWriter compares creating a new zstd writer for every small batch,
+// as the logging adapters send them, to reusing a pooled one.
+func BenchmarkZstdWriter(b *testing.B) {
+	data := []byte(strings.Repeat(`{"_time":"2022-01-01T00:00:00Z","level":"info","msg":"my message"}`+"\n", 10))
+
+	b.Run("pooled=false", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			zsw, err := zstd.NewWriter(io.Discard)
+			require.NoError(b, err)
+
+			_, err = zsw.Write(data)
+			require.NoError(b, err)
+			require.NoError(b, zsw.Close())
+		}
+	})
+
+	b.Run("pooled=true", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			zsw, err := getZstdWriter(io.Discard, zstd.SpeedDefault)
+			require.NoError(b, err)
+
+			_, err = zsw.Write(data)
+			require.NoError(b, err)
+			require.NoError(b, zsw.Close())
+
+			putZstdWriter(zsw, zstd.SpeedDefault)
+		}
+	})
+}
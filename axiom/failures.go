@@ -0,0 +1,229 @@
+package axiom
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+)
+
+// FailedEvent is an event that failed to be ingested.
+type FailedEvent struct {
+	// Event that failed to be ingested. Nil, if the failure reported by the
+	// server couldn't be mapped back to one of the events submitted.
+	Event Event
+	// Timestamp of the event, as reported by the server. Zero, if the whole
+	// batch the event was part of failed to be sent.
+	Timestamp time.Time
+	// Error that made the event fail to be ingested.
+	Error string
+}
+
+// FailedEvents maps the failures reported in the given status back to the
+// events which were submitted in the ingestion the status was returned for.
+// The failed events are returned in the order they were submitted, followed by
+// the failures which couldn't be mapped.
+//
+// Failures are mapped by their index, which is set by the methods ingesting
+// events, e.g. `DatasetsService.IngestEvents` or `Spool.IngestEvents`. Events
+// rejected by the client, e.g. by a validator, carry the index of their event.
+// The server only reports the timestamp of an event which failed to be
+// ingested. Thus, its failures are matched against the events sent in the same
+// request by their timestamp, as found in the configured timestamp field. As
+// failures are reported in the order the events were submitted, events
+// sharing the same timestamp are mapped in order, too. Events without a
+// timestamp are assigned the ingestion time by the server. The failures
+// matching none of the events are mapped to them in order, but only if there
+// are as many of those failures as there are events without a timestamp.
+// Failures which can't be mapped are returned without an event.
+func FailedEvents(events []Event, status *ingest.Status) []FailedEvent {
+	if status == nil || (len(status.Failures) == 0 && len(status.Rejected) == 0) {
+		return nil
+	}
+
+	var (
+		res = make([]FailedEvent, 0, len(status.Rejected)+len(status.Failures))
+		// indices holds the index of the event of each failed event in res,
+		// -1 if it couldn't be mapped.
+		indices = make([]int, 0, cap(res))
+	)
+	add := func(failure *ingest.Failure) {
+		fe := FailedEvent{
+			Timestamp: failure.Timestamp,
+			Error:     failure.Error,
+		}
+		i := failure.Index
+		if i >= 0 && i < len(events) {
+			fe.Event = events[i]
+		} else {
+			i = -1
+		}
+		res = append(res, fe)
+		indices = append(indices, i)
+	}
+
+	for _, failure := range status.Rejected {
+		add(failure)
+	}
+	for _, failure := range status.Failures {
+		add(failure)
+	}
+
+	sort.Stable(byEventIndex{res, indices})
+
+	return res
+}
+
+// indexFailures sets the index of the failures the server reported for the
+// given values, which were sent in a single request, by matching their
+// timestamps. The index of a value among the values passed to the ingest
+// method is returned by indexOf. Failures which can't be matched get an index
+// of -1. See `FailedEvents` for details.
+func indexFailures[T any](failures []*ingest.Failure, values []T, indexOf func(k int) int, opts ingest.Options) {
+	if len(failures) == 0 {
+		return
+	}
+
+	field := opts.TimestampField
+	if field == "" {
+		field = ingest.TimestampField
+	}
+
+	var (
+		timestamps   = make([]time.Time, len(values))
+		hasTimestamp = make([]bool, len(values))
+		matched      = make([]bool, len(values))
+	)
+	for k, v := range values {
+		timestamps[k], hasTimestamp[k] = valueTimestamp(v, field, opts.TimestampFormat)
+	}
+
+	match := func(from int, ts time.Time) int {
+		for k := from; k < len(values); k++ {
+			if !matched[k] && hasTimestamp[k] && timestamps[k].Equal(ts) {
+				return k
+			}
+		}
+		return -1
+	}
+
+	var (
+		next      int
+		unmatched []*ingest.Failure
+	)
+	for _, failure := range failures {
+		k := match(next, failure.Timestamp)
+		if k < 0 {
+			// Don't rely on the order of the failures, if it isn't kept.
+			k = match(0, failure.Timestamp)
+		}
+		if k < 0 {
+			failure.Index = -1
+			unmatched = append(unmatched, failure)
+			continue
+		}
+		failure.Index = indexOf(k)
+		matched[k] = true
+		next = k + 1
+	}
+
+	if len(unmatched) > 0 {
+		var untimed []int
+		for k := range values {
+			if !matched[k] && !hasTimestamp[k] {
+				untimed = append(untimed, k)
+			}
+		}
+		if len(untimed) == len(unmatched) {
+			for j, failure := range unmatched {
+				failure.Index = indexOf(untimed[j])
+			}
+		}
+	}
+}
+
+// byEventIndex sorts failed events by the index of their event. Failed events
+// without an event are sorted last.
+type byEventIndex struct {
+	events  []FailedEvent
+	indices []int
+}
+
+func (s byEventIndex) Len() int { return len(s.events) }
+
+func (s byEventIndex) Less(i, j int) bool {
+	a, b := s.indices[i], s.indices[j]
+	if a < 0 || b < 0 {
+		return b < 0 && a >= 0
+	}
+	return a < b
+}
+
+func (s byEventIndex) Swap(i, j int) {
+	s.events[i], s.events[j] = s.events[j], s.events[i]
+	s.indices[i], s.indices[j] = s.indices[j], s.indices[i]
+}
+
+// valueTimestamp returns the time the given timestamp field of the given value
+// represents. Values other than events are looked at as they are encoded.
+func valueTimestamp[T any](v T, field, format string) (time.Time, bool) {
+	if event, ok := any(v).(Event); ok {
+		return eventTimestamp(event[field], format)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return time.Time{}, false
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var fields map[string]any
+	if err = dec.Decode(&fields); err != nil {
+		return time.Time{}, false
+	}
+	return eventTimestamp(fields[field], format)
+}
+
+// eventTimestamp returns the time the given value of an events timestamp
+// field represents.
+func eventTimestamp(v any, format string) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		if format == "" {
+			format = time.RFC3339Nano
+		}
+		ts, err := time.Parse(format, v)
+		return ts, err == nil
+	case json.Number:
+		f, err := v.Float64()
+		return unixTimestamp(f), err == nil
+	case float64:
+		return unixTimestamp(v), true
+	case float32:
+		return unixTimestamp(float64(v)), true
+	case int:
+		return unixTimestamp(float64(v)), true
+	case int64:
+		return unixTimestamp(float64(v)), true
+	}
+	return time.Time{}, false
+}
+
+// unixTimestamp interprets the given number as a unix timestamp, with a
+// precision from seconds to nanoseconds guessed by its magnitude.
+func unixTimestamp(f float64) time.Time {
+	switch {
+	case f < 1e11:
+		return time.Unix(0, int64(f*float64(time.Second))).UTC()
+	case f < 1e14:
+		return time.Unix(0, int64(f*float64(time.Millisecond))).UTC()
+	case f < 1e17:
+		return time.Unix(0, int64(f*float64(time.Microsecond))).UTC()
+	default:
+		return time.Unix(0, int64(f)).UTC()
+	}
+}
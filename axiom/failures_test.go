@@ -0,0 +1,198 @@
+package axiom
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+)
+
+func TestFailedEvents(t *testing.T) {
+	t1 := time.Date(2022, 1, 1, 0, 0, 1, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		events   []Event
+		rejected []*ingest.Failure
+		failures []*ingest.Failure
+		exp      []FailedEvent
+	}{
+		{
+			name:   "no failures",
+			events: []Event{{"n": 1}},
+		},
+		{
+			name:     "by index",
+			events:   []Event{{"n": 1}, {"n": 2}, {"n": 3}},
+			rejected: []*ingest.Failure{{Index: 2, Error: "rejected"}},
+			failures: []*ingest.Failure{{Timestamp: t1, Index: 0, Error: "invalid event"}},
+			exp: []FailedEvent{
+				{Event: Event{"n": 1}, Timestamp: t1, Error: "invalid event"},
+				{Event: Event{"n": 3}, Error: "rejected"},
+			},
+		},
+		{
+			name:     "unknown index last",
+			events:   []Event{{"n": 1}, {"n": 2}},
+			rejected: []*ingest.Failure{{Index: 2, Error: "rejected"}},
+			failures: []*ingest.Failure{
+				{Timestamp: t1, Index: -1, Error: "invalid event"},
+				{Index: 1, Error: "invalid event"},
+			},
+			exp: []FailedEvent{
+				{Event: Event{"n": 2}, Error: "invalid event"},
+				{Error: "rejected"},
+				{Timestamp: t1, Error: "invalid event"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &ingest.Status{
+				Failed:   uint64(len(tt.rejected) + len(tt.failures)),
+				Failures: tt.failures,
+				Rejected: tt.rejected,
+			}
+
+			failed := FailedEvents(tt.events, status)
+
+			assert.Equal(t, tt.exp, failed)
+		})
+	}
+}
+
+func TestIndexFailures(t *testing.T) {
+	var (
+		t1 = time.Date(2022, 1, 1, 0, 0, 1, 0, time.UTC)
+		t2 = time.Date(2022, 1, 1, 0, 0, 2, 0, time.UTC)
+	)
+
+	tests := []struct {
+		name       string
+		events     []Event
+		timestamps []time.Time
+		options    []ingest.Option
+		exp        []int
+	}{
+		{
+			name:       "all failed",
+			events:     []Event{{"n": 1}, {"n": 2}},
+			timestamps: []time.Time{{}, {}},
+			exp:        []int{0, 1},
+		},
+		{
+			name: "match by timestamp",
+			events: []Event{
+				{"_time": t1.Format(time.RFC3339), "n": 1},
+				{"_time": t2, "n": 2},
+				{"_time": t1.Format(time.RFC3339Nano), "n": 3},
+			},
+			timestamps: []time.Time{t2},
+			exp:        []int{1},
+		},
+		{
+			name: "match by numeric timestamp",
+			events: []Event{
+				{"_time": json.Number("1640995201000"), "n": 1},
+				{"_time": float64(1640995202), "n": 2},
+			},
+			timestamps: []time.Time{t1},
+			exp:        []int{0},
+		},
+		{
+			name: "same timestamp in order",
+			events: []Event{
+				{"_time": t1, "n": 1},
+				{"_time": t1, "n": 2},
+				{"_time": t1, "n": 3},
+			},
+			timestamps: []time.Time{t1, t1},
+			exp:        []int{0, 1},
+		},
+		{
+			name: "failures out of order",
+			events: []Event{
+				{"_time": t1, "n": 1},
+				{"_time": t2, "n": 2},
+				{"n": 3},
+			},
+			timestamps: []time.Time{t2, t1},
+			exp:        []int{1, 0},
+		},
+		{
+			name: "custom timestamp field and format",
+			events: []Event{
+				{"ts": "01/01/2022 00:00:01", "n": 1},
+				{"ts": "01/01/2022 00:00:02", "n": 2},
+			},
+			options: []ingest.Option{
+				ingest.SetTimestampField("ts"),
+				ingest.SetTimestampFormat("01/02/2006 15:04:05"),
+			},
+			timestamps: []time.Time{t1},
+			exp:        []int{0},
+		},
+		{
+			name:       "unmatched",
+			events:     []Event{{"n": 1}, {"n": 2}},
+			timestamps: []time.Time{t1},
+			exp:        []int{-1},
+		},
+		{
+			name: "unmatched timestamp",
+			events: []Event{
+				{"_time": t1, "n": 1},
+				{"_time": t2, "n": 2},
+			},
+			timestamps: []time.Time{t1.Add(time.Hour), t2},
+			exp:        []int{-1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts ingest.Options
+			for _, option := range tt.options {
+				option(&opts)
+			}
+
+			failures := make([]*ingest.Failure, len(tt.timestamps))
+			for i, ts := range tt.timestamps {
+				failures[i] = &ingest.Failure{Timestamp: ts, Error: "invalid event", Index: -1}
+			}
+
+			// The events are the ones at odd indices of the ingestion.
+			indexFailures(failures, tt.events, func(k int) int { return 2*k + 1 }, opts)
+
+			indices := make([]int, len(failures))
+			for i, failure := range failures {
+				indices[i] = failure.Index
+			}
+			for i, idx := range tt.exp {
+				if idx >= 0 {
+					tt.exp[i] = 2*idx + 1
+				}
+			}
+			assert.Equal(t, tt.exp, indices)
+		})
+	}
+}
+
+func TestIndexFailures_Values(t *testing.T) {
+	type value struct {
+		Time time.Time `json:"ts"`
+		N    int       `json:"n"`
+	}
+
+	var (
+		t1 = time.Date(2022, 1, 1, 0, 0, 1, 0, time.UTC)
+		t2 = time.Date(2022, 1, 1, 0, 0, 2, 0, time.UTC)
+	)
+
+	failures := []*ingest.Failure{{Timestamp: t2, Error: "invalid event", Index: -1}}
+	indexFailures(failures, []value{{t1, 1}, {t2, 2}}, valueIndex(nil, 0), ingest.Options{TimestampField: "ts"})
+
+	assert.Equal(t, 1, failures[0].Index)
+}
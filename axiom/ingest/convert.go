@@ -0,0 +1,136 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+//go:generate go run golang.org/x/tools/cmd/stringer -type=MalformedPolicy -linecomment -output=convert_string.go
+
+const defaultRawField = "raw"
+
+// timeNow returns the current time. It is replaced in tests.
+var timeNow = time.Now
+
+// MalformedPolicy controls what a converter does with lines it can't parse.
+type MalformedPolicy uint8
+
+// All available policies for malformed lines.
+const (
+	// MalformedSkip skips malformed lines.
+	MalformedSkip MalformedPolicy = iota // skip
+	// MalformedError stops the conversion with a `*MalformedLineError`.
+	MalformedError // error
+	// MalformedRaw converts a malformed line into an event which holds the
+	// line as is in its raw field.
+	MalformedRaw // raw
+)
+
+// MalformedLineError is returned by a converter for a line it can't parse,
+// if its policy is `MalformedError`.
+type MalformedLineError struct {
+	// Line is the number of the malformed line, starting at one.
+	Line int
+	// Err is the reason the line can't be parsed.
+	Err error
+}
+
+// Error implements the `error` interface.
+func (e *MalformedLineError) Error() string {
+	return fmt.Sprintf("malformed line %d: %s", e.Line, e.Err)
+}
+
+// Unwrap returns the reason the line can't be parsed.
+func (e *MalformedLineError) Unwrap() error {
+	return e.Err
+}
+
+// ConverterConfig configures the converters which turn line based log formats
+// into NDJSON, like `NewLogfmtReader` and `NewSyslogReader`. The zero value is
+// a valid configuration.
+type ConverterConfig struct {
+	// Malformed controls what happens to lines which can't be parsed. Defaults
+	// to `MalformedSkip`.
+	Malformed MalformedPolicy
+	// RawField is the field a malformed line is stored in, if the policy is
+	// `MalformedRaw`. Defaults to "raw".
+	RawField string
+	// TimestampField is the logfmt key the time of a line is read from. If
+	// empty, the first of "time", "ts" and "timestamp" present is used. Not
+	// used for syslog, which has a dedicated timestamp.
+	TimestampField string
+	// TimestampFormat is the format of the logfmt timestamp. Defaults to
+	// RFC 3339.
+	TimestampFormat string
+	// Location is the time zone of RFC 3164 syslog timestamps, which carry
+	// none. Defaults to the local time zone.
+	Location *time.Location
+}
+
+func (c ConverterConfig) rawField() string {
+	if c.RawField != "" {
+		return c.RawField
+	}
+	return defaultRawField
+}
+
+// lineParser parses a single, non-empty line into an event.
+type lineParser func(line string) (map[string]any, error)
+
+// convertLines returns a reader which streams the events parsed from the lines
+// of the given reader as NDJSON.
+func convertLines(r io.Reader, config ConverterConfig, parse lineParser) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		var (
+			br  = bufio.NewReader(r)
+			enc = json.NewEncoder(pw)
+		)
+		for n := 1; ; n++ {
+			line, readErr := br.ReadBytes('\n')
+			if readErr != nil && readErr != io.EOF {
+				_ = pw.CloseWithError(readErr)
+				return
+			}
+
+			line = bytes.TrimRight(line, "\r\n")
+			if err := convertLine(enc, string(line), n, config, parse); err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+
+			if readErr == io.EOF {
+				_ = pw.Close()
+				return
+			}
+		}
+	}()
+	return pr
+}
+
+// convertLine parses the given line and encodes the resulting event, applying
+// the malformed policy of the given configuration if it can't be parsed.
+func convertLine(enc *json.Encoder, line string, n int, config ConverterConfig, parse lineParser) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+
+	event, err := parse(line)
+	if err != nil {
+		switch config.Malformed {
+		case MalformedError:
+			return &MalformedLineError{Line: n, Err: err}
+		case MalformedRaw:
+			event = map[string]any{config.rawField(): line}
+		default:
+			return nil
+		}
+	}
+
+	return enc.Encode(event)
+}
@@ -0,0 +1,322 @@
+package ingest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:generate go run golang.org/x/tools/cmd/stringer -type=ColumnType -linecomment -output=csv_string.go
+
+const defaultInferRows = 100
+
+// ColumnType is the type the values of a CSV column are converted to.
+type ColumnType uint8
+
+// All available column types.
+const (
+	// ColumnString keeps the values as strings.
+	ColumnString ColumnType = iota // string
+	// ColumnInt converts the values to 64 bit integers.
+	ColumnInt // int
+	// ColumnFloat converts the values to 64 bit floating point numbers.
+	ColumnFloat // float
+	// ColumnBool converts the values to booleans, as accepted by
+	// `strconv.ParseBool`.
+	ColumnBool // bool
+	// ColumnTime converts the values to times, using the layout of the column.
+	ColumnTime // time
+)
+
+// Column describes the type of a CSV column.
+type Column struct {
+	// Type the values of the column are converted to.
+	Type ColumnType
+	// Layout of the values of a `ColumnTime` column. Defaults to RFC 3339.
+	Layout string
+}
+
+func (c Column) layout() string {
+	if c.Layout != "" {
+		return c.Layout
+	}
+	return time.RFC3339Nano
+}
+
+// CSVConfig configures the conversion of CSV data to NDJSON by
+// `NewCSVReader`. The zero value is a valid configuration.
+type CSVConfig struct {
+	// Delimiter separates the fields of a row. Defaults to a comma.
+	Delimiter rune
+	// Rename maps the names in the header row to the field names to use in the
+	// events instead.
+	Rename map[string]string
+	// Schema maps field names, after renaming, to the type of their columns.
+	Schema map[string]Column
+	// InferRows is the amount of rows the types of the columns missing from
+	// the schema are inferred from. Defaults to 100. A negative value disables
+	// inference, which keeps the values of those columns as strings. A later
+	// value which doesn't match the inferred type widens the column for the
+	// rest of the rows: integer columns to floats, if the value is a number,
+	// and all others to strings.
+	InferRows int
+	// Malformed controls what happens to rows which have the wrong amount of
+	// fields or values which can't be converted to the type of their column in
+	// the schema. `MalformedRaw` keeps such values as strings. Rows which
+	// aren't valid CSV are skipped in that case. Defaults to `MalformedSkip`.
+	Malformed MalformedPolicy
+}
+
+func (c CSVConfig) inferRows() int {
+	if c.InferRows != 0 {
+		return c.InferRows
+	}
+	return defaultInferRows
+}
+
+// NewCSVReader returns a reader which converts the CSV data read from the given
+// reader into NDJSON, which can be passed to `Datasets.Ingest`. In contrast to
+// ingesting CSV data directly, which makes every value a string, the values
+// are converted according to the schema of the configuration or the types
+// inferred from the first rows. This allows numeric aggregations on them.
+//
+// The first row is the header, which holds the names of the fields. Quoted
+// values can span multiple lines. Empty values of columns which aren't of type
+// `ColumnString` are omitted from the events. Time values are encoded as RFC
+// 3339 and can be used as timestamp field by passing `SetTimestampField` to
+// `Datasets.Ingest`. Closing the reader before it is drained stops the
+// conversion.
+func NewCSVReader(r io.Reader, config CSVConfig) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(convertCSV(r, pw, config))
+	}()
+	return pr
+}
+
+// csvRecord is a row read from CSV data along with the line it starts at and
+// the error reading it, if any.
+type csvRecord struct {
+	fields []string
+	line   int
+	err    error
+}
+
+// convertCSV converts the CSV data read from the given reader into NDJSON
+// written to the given writer.
+func convertCSV(r io.Reader, w io.Writer, config CSVConfig) error {
+	cr := csv.NewReader(r)
+	if config.Delimiter != 0 {
+		cr.Comma = config.Delimiter
+	}
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	fields := make([]string, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, utf8BOM)
+		}
+		if renamed, ok := config.Rename[name]; ok {
+			name = renamed
+		}
+		fields[i] = name
+	}
+
+	read := func() (csvRecord, bool) {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return csvRecord{}, false
+		}
+		var (
+			line     int
+			parseErr *csv.ParseError
+		)
+		if errors.As(err, &parseErr) {
+			line = parseErr.StartLine
+		} else if len(rec) > 0 {
+			line, _ = cr.FieldPos(0)
+		}
+		return csvRecord{fields: rec, line: line, err: err}, true
+	}
+
+	// Buffer the first rows to infer the types of the columns missing from
+	// the schema.
+	var buffered []csvRecord
+	if config.inferRows() > 0 {
+		for len(buffered) < config.inferRows() {
+			rec, ok := read()
+			if !ok {
+				break
+			}
+			buffered = append(buffered, rec)
+		}
+	}
+
+	columns := make([]csvColumn, len(fields))
+	for i, name := range fields {
+		if col, ok := config.Schema[name]; ok {
+			columns[i] = csvColumn{Column: col}
+		} else {
+			columns[i] = csvColumn{Column: inferColumn(buffered, i), inferred: true}
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	convert := func(rec csvRecord) error {
+		event, err := convertRecord(rec, fields, columns, config.Malformed)
+		if err != nil {
+			if config.Malformed == MalformedError {
+				return &MalformedLineError{Line: rec.line, Err: err}
+			}
+			return nil
+		}
+		return enc.Encode(event)
+	}
+
+	for _, rec := range buffered {
+		if err := convert(rec); err != nil {
+			return err
+		}
+	}
+	for {
+		rec, ok := read()
+		if !ok {
+			return nil
+		}
+		if err := convert(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// csvColumn is a column of the CSV data being converted.
+type csvColumn struct {
+	Column
+
+	// inferred is true if the type of the column was inferred, which makes it
+	// widen to values not matching the type.
+	inferred bool
+	// omitEmpty is true if the column was widened to strings, which still
+	// omits empty values, like before.
+	omitEmpty bool
+}
+
+// widen widens the type of the column to one the given value can be converted
+// to.
+func (c *csvColumn) widen(value string) {
+	if c.Type == ColumnInt {
+		if _, err := convertValue(value, Column{Type: ColumnFloat}); err == nil {
+			c.Type = ColumnFloat
+			return
+		}
+	}
+	c.Column, c.omitEmpty = Column{Type: ColumnString}, true
+}
+
+// convertRecord converts the given record into an event. Values which can't be
+// converted are kept as strings if the policy is `MalformedRaw`. Inferred
+// columns are widened to values which can't be converted instead.
+func convertRecord(rec csvRecord, fields []string, columns []csvColumn, policy MalformedPolicy) (map[string]any, error) {
+	if rec.err != nil && (!errors.Is(rec.err, csv.ErrFieldCount) || policy != MalformedRaw) {
+		var parseErr *csv.ParseError
+		if errors.As(rec.err, &parseErr) {
+			return nil, parseErr.Err
+		}
+		return nil, rec.err
+	}
+
+	event := make(map[string]any, len(rec.fields))
+	for i, value := range rec.fields {
+		if i >= len(fields) {
+			// Only rows with the wrong amount of fields kept by the
+			// `MalformedRaw` policy have more fields than the header.
+			event["column"+strconv.Itoa(i+1)] = value
+			continue
+		}
+
+		col := &columns[i]
+		if value == "" && (col.Type != ColumnString || col.omitEmpty) {
+			continue
+		}
+
+		v, err := convertValue(value, col.Column)
+		if err != nil && col.inferred {
+			col.widen(value)
+			v, err = convertValue(value, col.Column)
+		}
+		if err != nil {
+			if policy != MalformedRaw {
+				return nil, fmt.Errorf("field %q: %w", fields[i], err)
+			}
+			v = value
+		}
+		event[fields[i]] = v
+	}
+	return event, nil
+}
+
+// convertValue converts the given value to the type of the given column.
+func convertValue(value string, col Column) (any, error) {
+	switch col.Type {
+	case ColumnInt:
+		return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	case ColumnFloat:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			// Neither can be encoded as JSON.
+			err = fmt.Errorf("invalid number %q", value)
+		}
+		return f, err
+	case ColumnBool:
+		return strconv.ParseBool(strings.TrimSpace(value))
+	case ColumnTime:
+		return time.Parse(col.layout(), strings.TrimSpace(value))
+	}
+	return value, nil
+}
+
+// inferColumn returns the narrowest column type all non-empty values of the
+// column at the given index in the given records can be converted to.
+func inferColumn(records []csvRecord, index int) Column {
+	candidates := []Column{
+		{Type: ColumnInt},
+		{Type: ColumnFloat},
+		{Type: ColumnBool},
+		{Type: ColumnTime},
+	}
+
+	var values int
+	for _, rec := range records {
+		if rec.err != nil || index >= len(rec.fields) || rec.fields[index] == "" {
+			continue
+		}
+		values++
+
+		valid := candidates[:0]
+		for _, col := range candidates {
+			if _, err := convertValue(rec.fields[index], col); err == nil {
+				valid = append(valid, col)
+			}
+		}
+		if candidates = valid; len(candidates) == 0 {
+			break
+		}
+	}
+
+	if values == 0 || len(candidates) == 0 {
+		return Column{Type: ColumnString}
+	}
+	return candidates[0]
+}
@@ -0,0 +1,166 @@
+package ingest
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCSVReader(t *testing.T) {
+	const input = "\ufeffdate,amount,count,paid,note\n" +
+		"2023-01-02T00:00:00Z,12.50,3,true,\"multi\nline\"\n" +
+		"2023-01-03T00:00:00Z,7,,false,\n"
+
+	tests := []struct {
+		name   string
+		config CSVConfig
+		want   []map[string]any
+	}{
+		{
+			name: "inferred",
+			want: []map[string]any{
+				{"date": "2023-01-02T00:00:00Z", "amount": 12.5, "count": 3.0, "paid": true, "note": "multi\nline"},
+				{"date": "2023-01-03T00:00:00Z", "amount": 7.0, "paid": false, "note": ""},
+			},
+		},
+		{
+			name: "schema and rename",
+			config: CSVConfig{
+				Rename: map[string]string{"date": "_time", "note": "comment"},
+				Schema: map[string]Column{
+					"_time":  {Type: ColumnTime},
+					"amount": {Type: ColumnString},
+				},
+			},
+			want: []map[string]any{
+				{"_time": "2023-01-02T00:00:00Z", "amount": "12.50", "count": 3.0, "paid": true, "comment": "multi\nline"},
+				{"_time": "2023-01-03T00:00:00Z", "amount": "7", "paid": false, "comment": ""},
+			},
+		},
+		{
+			name:   "no inference",
+			config: CSVConfig{InferRows: -1},
+			want: []map[string]any{
+				{"date": "2023-01-02T00:00:00Z", "amount": "12.50", "count": "3", "paid": "true", "note": "multi\nline"},
+				{"date": "2023-01-03T00:00:00Z", "amount": "7", "count": "", "paid": "false", "note": ""},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewCSVReader(strings.NewReader(input), tt.config)
+			defer r.Close()
+
+			assert.Equal(t, tt.want, decodeNDJSON(t, r))
+		})
+	}
+}
+
+func TestNewCSVReader_Malformed(t *testing.T) {
+	const input = "a;b\n1;2\nx;3\n4\n"
+
+	tests := []struct {
+		name    string
+		policy  MalformedPolicy
+		want    []map[string]any
+		wantErr string
+	}{
+		{
+			name:   "skip",
+			policy: MalformedSkip,
+			want: []map[string]any{
+				{"a": 1.0, "b": 2.0},
+			},
+		},
+		{
+			name:   "raw",
+			policy: MalformedRaw,
+			want: []map[string]any{
+				{"a": 1.0, "b": 2.0},
+				{"a": "x", "b": 3.0},
+				{"a": 4.0},
+			},
+		},
+		{
+			name:    "error",
+			policy:  MalformedError,
+			wantErr: `malformed line 3: field "a": strconv.ParseInt: parsing "x": invalid syntax`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewCSVReader(strings.NewReader(input), CSVConfig{
+				Delimiter: ';',
+				Schema:    map[string]Column{"a": {Type: ColumnInt}},
+				Malformed: tt.policy,
+			})
+			defer r.Close()
+
+			if tt.wantErr != "" {
+				_, err := io.ReadAll(r)
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, decodeNDJSON(t, r))
+		})
+	}
+}
+
+func TestNewCSVReader_Widen(t *testing.T) {
+	const input = "id,amount,paid\n" +
+		"1,2,true\n" +
+		"2,3,false\n" +
+		"3,4.5,yes\n" +
+		"4,x,\n" +
+		"5,6,true\n"
+
+	r := NewCSVReader(strings.NewReader(input), CSVConfig{InferRows: 2})
+	defer r.Close()
+
+	assert.Equal(t, []map[string]any{
+		{"id": 1.0, "amount": 2.0, "paid": true},
+		{"id": 2.0, "amount": 3.0, "paid": false},
+		{"id": 3.0, "amount": 4.5, "paid": "yes"},
+		{"id": 4.0, "amount": "x"},
+		{"id": 5.0, "amount": "6", "paid": "true"},
+	}, decodeNDJSON(t, r))
+}
+
+func TestInferColumn(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   ColumnType
+	}{
+		{[]string{"1", "", "-2"}, ColumnInt},
+		{[]string{"1", "2.5"}, ColumnFloat},
+		{[]string{"true", "F"}, ColumnBool},
+		{[]string{"2023-01-02T00:00:00Z"}, ColumnTime},
+		{[]string{"1", "NaN"}, ColumnString},
+		{[]string{"", ""}, ColumnString},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.values, ","), func(t *testing.T) {
+			records := make([]csvRecord, len(tt.values))
+			for i, v := range tt.values {
+				records[i] = csvRecord{fields: []string{v}}
+			}
+			assert.Equal(t, tt.want.String(), inferColumn(records, 0).Type.String())
+		})
+	}
+}
+
+func decodeNDJSON(t *testing.T, r io.Reader) []map[string]any {
+	t.Helper()
+
+	var events []map[string]any
+	for dec := json.NewDecoder(r); dec.More(); {
+		var event map[string]any
+		require.NoError(t, dec.Decode(&event))
+		events = append(events, event)
+	}
+	return events
+}
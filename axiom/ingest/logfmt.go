@@ -0,0 +1,135 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logfmtTimestampFields are the keys the time of a logfmt line is looked up
+// in, if none is configured.
+var logfmtTimestampFields = []string{"time", "ts", "timestamp"}
+
+// NewLogfmtReader returns a reader which converts the logfmt lines read from
+// the given reader into NDJSON, which can be passed to `Datasets.Ingest`:
+//
+//	r := ingest.NewLogfmtReader(f, ingest.ConverterConfig{})
+//	defer r.Close()
+//
+//	res, err := client.Datasets.Ingest(ctx, dataset, r, axiom.NDJSON, axiom.Identity)
+//
+// Every line becomes an event with its keys as fields. Values are kept as
+// strings and keys without a value are set to true. The timestamp of a line is
+// parsed and moved to the `_time` field. If it can't be parsed, it is kept as
+// is and the server assigns the time of ingestion. Closing the reader before
+// it is drained stops the conversion.
+func NewLogfmtReader(r io.Reader, config ConverterConfig) io.ReadCloser {
+	return convertLines(r, config, func(line string) (map[string]any, error) {
+		return parseLogfmt(line, config)
+	})
+}
+
+// parseLogfmt parses a single logfmt line into an event.
+func parseLogfmt(line string, config ConverterConfig) (map[string]any, error) {
+	event := make(map[string]any)
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+
+		// Parse the key, which ends at the equal sign or the next space.
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' && line[i] != '\t' {
+			if line[i] == '"' {
+				return nil, fmt.Errorf("unexpected quote in key at column %d", i+1)
+			}
+			i++
+		}
+		key := line[start:i]
+		if key == "" {
+			return nil, fmt.Errorf("missing key at column %d", i+1)
+		}
+
+		if i == len(line) || line[i] != '=' {
+			event[key] = true
+			continue
+		}
+		i++ // Skip the equal sign.
+
+		// Parse the value, which is either quoted or ends at the next space.
+		if i < len(line) && line[i] == '"' {
+			end, err := quotedEnd(line, i)
+			if err != nil {
+				return nil, err
+			}
+			value, err := strconv.Unquote(line[i:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted value of key %q: %w", key, err)
+			}
+			if end < len(line) && line[end] != ' ' && line[end] != '\t' {
+				return nil, fmt.Errorf("unexpected character after quoted value of key %q", key)
+			}
+			event[key] = value
+			i = end
+			continue
+		}
+
+		start = i
+		for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+			if line[i] == '"' {
+				return nil, fmt.Errorf("unexpected quote in value of key %q", key)
+			}
+			i++
+		}
+		event[key] = line[start:i]
+	}
+
+	extractLogfmtTimestamp(event, config)
+
+	return event, nil
+}
+
+// quotedEnd returns the index following the closing quote of the quoted
+// string starting at the given index.
+func quotedEnd(line string, start int) (int, error) {
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, errors.New("unterminated quoted value")
+}
+
+// extractLogfmtTimestamp moves the timestamp of the given event to the
+// `_time` field, if it can be parsed.
+func extractLogfmtTimestamp(event map[string]any, config ConverterConfig) {
+	fields := logfmtTimestampFields
+	if config.TimestampField != "" {
+		fields = []string{config.TimestampField}
+	}
+
+	format := config.TimestampFormat
+	if format == "" {
+		format = time.RFC3339Nano
+	}
+
+	for _, field := range fields {
+		value, ok := event[field].(string)
+		if !ok {
+			continue
+		}
+
+		if ts, err := time.Parse(format, strings.TrimSpace(value)); err == nil {
+			delete(event, field)
+			event[TimestampField] = ts
+		}
+		return
+	}
+}
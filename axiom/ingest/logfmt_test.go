@@ -0,0 +1,132 @@
+package ingest
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogfmt(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ConverterConfig
+		line    string
+		want    map[string]any
+		wantErr string
+	}{
+		{
+			name: "simple",
+			line: `level=info msg="hello \"world\"" debug empty=`,
+			want: map[string]any{
+				"level": "info",
+				"msg":   `hello "world"`,
+				"debug": true,
+				"empty": "",
+			},
+		},
+		{
+			name: "timestamp",
+			line: "ts=2020-03-18T13:56:21.5Z level=warn",
+			want: map[string]any{
+				"_time": time.Date(2020, 3, 18, 13, 56, 21, 5e8, time.UTC),
+				"level": "warn",
+			},
+		},
+		{
+			name: "invalid timestamp",
+			line: "time=yesterday",
+			want: map[string]any{"time": "yesterday"},
+		},
+		{
+			name: "custom timestamp",
+			config: ConverterConfig{
+				TimestampField:  "at",
+				TimestampFormat: time.RFC1123,
+			},
+			line: `at="Wed, 18 Mar 2020 13:56:21 UTC" time=now`,
+			want: map[string]any{
+				"_time": time.Date(2020, 3, 18, 13, 56, 21, 0, time.UTC),
+				"time":  "now",
+			},
+		},
+		{
+			name:    "unterminated quote",
+			line:    `msg="hello`,
+			wantErr: "unterminated quoted value",
+		},
+		{
+			name:    "missing key",
+			line:    "a=b =c",
+			wantErr: "missing key at column 5",
+		},
+		{
+			name:    "garbage after quote",
+			line:    `msg="a"b`,
+			wantErr: `unexpected character after quoted value of key "msg"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLogfmt(tt.line, tt.config)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			if ts, ok := got[TimestampField].(time.Time); ok {
+				got[TimestampField] = ts.UTC()
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewLogfmtReader(t *testing.T) {
+	input := "a=1\r\n\nb=\"2\nc=3"
+
+	tests := []struct {
+		name    string
+		policy  MalformedPolicy
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "skip",
+			policy: MalformedSkip,
+			want:   `{"a":"1"}` + "\n" + `{"c":"3"}` + "\n",
+		},
+		{
+			name:   "raw",
+			policy: MalformedRaw,
+			want:   `{"a":"1"}` + "\n" + `{"raw":"b=\"2"}` + "\n" + `{"c":"3"}` + "\n",
+		},
+		{
+			name:    "error",
+			policy:  MalformedError,
+			want:    `{"a":"1"}` + "\n",
+			wantErr: "malformed line 3: unterminated quoted value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewLogfmtReader(strings.NewReader(input), ConverterConfig{Malformed: tt.policy})
+			defer r.Close()
+
+			b, err := io.ReadAll(r)
+			if tt.wantErr != "" {
+				var lineErr *MalformedLineError
+				require.ErrorAs(t, err, &lineErr)
+				assert.Equal(t, 3, lineErr.Line)
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, string(b))
+		})
+	}
+}
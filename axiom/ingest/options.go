@@ -17,6 +17,29 @@ type Options struct {
 	// CSVDelimiter is the delimiter that separates CSV fields. Only valid when
 	// the content to be ingested is CSV formatted.
 	CSVDelimiter string `url:"csv-delimiter,omitempty"`
+
+	// MaxEvents caps the amount of events sent in a single request. Zero
+	// means the amount is not capped.
+	MaxEvents int `url:"-" json:"-"`
+	// MaxBytes caps the size of the uncompressed data sent in a single
+	// request. Zero means the size is not capped.
+	MaxBytes int `url:"-" json:"-"`
+	// MaxCompressedBytes caps the size of the compressed data sent in a single
+	// request. Zero means the size is not capped.
+	MaxCompressedBytes int `url:"-" json:"-"`
+	// Concurrency is the amount of requests sent in parallel when the events
+	// to ingest are split into multiple requests. Defaults to one, which sends
+	// them sequentially.
+	Concurrency int `url:"-" json:"-"`
+	// Validator validates the events before they are sent. Nil means events
+	// are not validated.
+	Validator *Validator `url:"-" json:"-"`
+	// Processor transforms the events before they are validated and sent. Nil
+	// means events are sent as is.
+	Processor Processor `url:"-" json:"-"`
+	// Progress is called with the progress of the ingestion. Nil means
+	// progress is not reported.
+	Progress ProgressFunc `url:"-" json:"-"`
 }
 
 // An Option applies an optional parameter to an ingest.
@@ -40,3 +63,55 @@ func SetTimestampFormat(format string) Option {
 func SetCSVDelimiter(delim string) Option {
 	return func(o *Options) { o.CSVDelimiter = delim }
 }
+
+// SetMaxEvents caps the amount of events sent in a single request. Events
+// exceeding the cap are split into multiple requests. Not every ingest method
+// supports splitting, refer to their documentation.
+func SetMaxEvents(n int) Option {
+	return func(o *Options) { o.MaxEvents = n }
+}
+
+// SetMaxBytes caps the size of the uncompressed, JSON encoded events sent in a
+// single request. Events exceeding the cap are split into multiple requests. A
+// single event exceeding the cap is sent in a request of its own.
+func SetMaxBytes(n int) Option {
+	return func(o *Options) { o.MaxBytes = n }
+}
+
+// SetMaxCompressedBytes caps the size of the compressed events sent in a
+// single request. Events exceeding the cap are split into multiple requests. A
+// single event exceeding the cap is sent in a request of its own.
+func SetMaxCompressedBytes(n int) Option {
+	return func(o *Options) { o.MaxCompressedBytes = n }
+}
+
+// SetConcurrency specifies the amount of requests sent in parallel if the
+// events to ingest are split into multiple requests because they exceed the
+// configured caps.
+func SetConcurrency(n int) Option {
+	return func(o *Options) { o.Concurrency = n }
+}
+
+// SetValidator specifies a validator which checks the events before they are
+// sent and rejects, sanitizes or reports invalid ones, depending on its mode.
+// Rejected events are not sent but counted as failed in the ingestion status
+// and reported in `Status.Rejected`. Only applies to ingest methods which are
+// passed individual events, not readers or arbitrary values.
+func SetValidator(v *Validator) Option {
+	return func(o *Options) { o.Validator = v }
+}
+
+// SetProcessor specifies a processor which transforms the events before they
+// are sent, e.g. a `Pipeline`. Events it drops are neither sent nor
+// counted as failed. It runs before the validator, if one is set. Only applies
+// to ingest methods which are passed individual events.
+func SetProcessor(p Processor) Option {
+	return func(o *Options) { o.Processor = p }
+}
+
+// SetProgress specifies a function which is called with the progress of the
+// ingestion, e.g. to render a progress bar. It is called at most ten times a
+// second and once more when the ingestion is done, whether it succeeded or not.
+func SetProgress(f ProgressFunc) Option {
+	return func(o *Options) { o.Progress = f }
+}
@@ -0,0 +1,137 @@
+package ingest
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// A Processor transforms events before they are ingested. Pass it to an
+// ingestion using `SetProcessor`.
+type Processor interface {
+	// Process returns the event to ingest in place of the given one or nil to
+	// drop it. The given event must not be modified, return a modified copy
+	// instead.
+	Process(event map[string]any) map[string]any
+}
+
+// The ProcessorFunc type is an adapter to allow the use of ordinary functions
+// as `Processor`.
+type ProcessorFunc func(event map[string]any) map[string]any
+
+// Process implements `Processor`.
+func (f ProcessorFunc) Process(event map[string]any) map[string]any {
+	return f(event)
+}
+
+// Pipeline is a `Processor` which passes events through the processors it is
+// composed of, in order. An event dropped by one of them isn't passed to the
+// following ones.
+type Pipeline []Processor
+
+// Process implements `Processor`.
+func (p Pipeline) Process(event map[string]any) map[string]any {
+	for _, processor := range p {
+		if event = processor.Process(event); event == nil {
+			return nil
+		}
+	}
+	return event
+}
+
+// NewProcessingReader returns a reader which passes the events of the NDJSON
+// read from the given reader through the given processor and streams the
+// processed events as NDJSON. This allows processing data passed to
+// `Datasets.Ingest`. Numbers are kept as is to not lose precision. Closing the
+// reader before it is drained stops the processing.
+func NewProcessingReader(r io.Reader, p Processor) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+
+		enc := json.NewEncoder(pw)
+		enc.SetEscapeHTML(false)
+
+		for {
+			var event map[string]any
+			if err := dec.Decode(&event); err == io.EOF {
+				_ = pw.Close()
+				return
+			} else if err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+
+			if event = p.Process(event); event == nil {
+				continue
+			}
+			if err := enc.Encode(event); err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
+
+// AddFields returns a `Processor` which enriches events with the given static
+// fields. Fields already present on an event are not overwritten.
+func AddFields(fields map[string]any) Processor {
+	return ProcessorFunc(func(event map[string]any) map[string]any {
+		res := make(map[string]any, len(event)+len(fields))
+		for k, v := range fields {
+			res[k] = v
+		}
+		for k, v := range event {
+			res[k] = v
+		}
+		return res
+	})
+}
+
+// RenameFields returns a `Processor` which renames the fields of events, as
+// given by the mapping of old to new field names. A renamed field overwrites
+// a field already present under its new name.
+func RenameFields(names map[string]string) Processor {
+	return ProcessorFunc(func(event map[string]any) map[string]any {
+		res := copyEvent(event)
+		for from, to := range names {
+			if v, ok := event[from]; ok {
+				delete(res, from)
+				res[to] = v
+			}
+		}
+		return res
+	})
+}
+
+// DropFields returns a `Processor` which removes the fields with the given
+// names from events.
+func DropFields(names ...string) Processor {
+	return ProcessorFunc(func(event map[string]any) map[string]any {
+		res := copyEvent(event)
+		for _, name := range names {
+			delete(res, name)
+		}
+		return res
+	})
+}
+
+// Filter returns a `Processor` which drops all events the given function
+// doesn't report to keep.
+func Filter(keep func(event map[string]any) bool) Processor {
+	return ProcessorFunc(func(event map[string]any) map[string]any {
+		if !keep(event) {
+			return nil
+		}
+		return event
+	})
+}
+
+func copyEvent(event map[string]any) map[string]any {
+	res := make(map[string]any, len(event))
+	for k, v := range event {
+		res[k] = v
+	}
+	return res
+}
@@ -0,0 +1,45 @@
+package ingest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeline(t *testing.T) {
+	var calls int
+	count := ProcessorFunc(func(event map[string]any) map[string]any {
+		calls++
+		return event
+	})
+
+	p := Pipeline{
+		count,
+		AddFields(map[string]any{"service": "api", "env": "prod"}),
+		RenameFields(map[string]string{"msg": "message"}),
+		DropFields("password"),
+		Filter(func(event map[string]any) bool { return event["level"] != "debug" }),
+		count,
+	}
+
+	event := map[string]any{
+		"env":      "dev",
+		"msg":      "hello",
+		"password": "secret",
+	}
+	assert.Equal(t, map[string]any{
+		"env":     "dev",
+		"service": "api",
+		"message": "hello",
+	}, p.Process(event))
+
+	// The event passed must not be modified.
+	assert.Equal(t, map[string]any{
+		"env":      "dev",
+		"msg":      "hello",
+		"password": "secret",
+	}, event)
+
+	assert.Nil(t, p.Process(map[string]any{"level": "debug"}))
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,46 @@
+package ingest
+
+import "time"
+
+// Progress is a snapshot of the progress of an ingestion. All counts are
+// cumulative.
+type Progress struct {
+	// BytesRead is the amount of uncompressed bytes read. For events and
+	// values, this is the size of their JSON encoding. For content passed as a
+	// reader, it is the amount of bytes read from it.
+	BytesRead uint64
+	// BytesSent is the amount of bytes sent in request bodies, after
+	// compression. Requests which are retried count again.
+	BytesSent uint64
+	// EventsEncoded is the amount of events read or encoded. It is only known
+	// for events, values and files split into records.
+	EventsEncoded uint64
+	// RequestsCompleted is the amount of requests which completed, successfully
+	// or not.
+	RequestsCompleted uint64
+	// Elapsed is the time passed since the ingestion started.
+	Elapsed time.Duration
+}
+
+// BytesPerSecond returns the average rate uncompressed bytes have been read at
+// since the ingestion started.
+func (p Progress) BytesPerSecond() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.BytesRead) / p.Elapsed.Seconds()
+}
+
+// EventsPerSecond returns the average rate events have been encoded at since
+// the ingestion started.
+func (p Progress) EventsPerSecond() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.EventsEncoded) / p.Elapsed.Seconds()
+}
+
+// A ProgressFunc is called with the progress of an ingestion. It is called
+// from the goroutines doing the work, but never concurrently, so it should
+// return quickly.
+type ProgressFunc func(Progress)
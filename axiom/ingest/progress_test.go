@@ -0,0 +1,21 @@
+package ingest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress_PerSecond(t *testing.T) {
+	p := Progress{
+		BytesRead:     4096,
+		EventsEncoded: 100,
+		Elapsed:       2 * time.Second,
+	}
+	assert.Equal(t, 2048.0, p.BytesPerSecond())
+	assert.Equal(t, 50.0, p.EventsPerSecond())
+
+	assert.Zero(t, Progress{BytesRead: 1}.BytesPerSecond())
+	assert.Zero(t, Progress{EventsEncoded: 1}.EventsPerSecond())
+}
@@ -0,0 +1,278 @@
+package ingest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:generate go run golang.org/x/tools/cmd/stringer -type=RedactAction -linecomment -output=redact_string.go
+
+const defaultMask = "[REDACTED]"
+
+// Patterns matching common personally identifiable information, for use in a
+// `RedactRule`.
+var (
+	// EmailPattern matches email addresses.
+	EmailPattern = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
+	// IPv4Pattern matches IPv4 addresses.
+	IPv4Pattern = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`)
+	// IPv6Pattern matches IPv6 addresses, including abbreviated ones.
+	IPv6Pattern = regexp.MustCompile(`(?i)\b(?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}\b|\b(?:[0-9a-f]{1,4}:){1,7}:(?:[0-9a-f]{1,4}(?::[0-9a-f]{1,4}){0,6})?\b|::(?:[0-9a-f]{1,4}(?::[0-9a-f]{1,4}){0,6})\b`)
+	// CreditCardPattern matches credit card numbers of 13 to 16 digits, which
+	// are optionally grouped by spaces or dashes. Used in a `RedactRule`, only
+	// numbers with a valid Luhn checksum are redacted, which rules out most
+	// other numbers of that length, e.g. timestamps in milliseconds.
+	CreditCardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,15}\b`)
+)
+
+// matchCheckers holds additional checks for the matches of some patterns,
+// which can't be expressed by the pattern itself.
+var matchCheckers = map[*regexp.Regexp]func(match string) bool{
+	CreditCardPattern: validLuhn,
+}
+
+// RedactAction is the action a `RedactRule` takes on the values it matches.
+type RedactAction uint8
+
+// All available redact actions.
+const (
+	// RedactMask replaces the matched values with the mask of the
+	// `Redactor`.
+	RedactMask RedactAction = iota // mask
+	// RedactDrop removes the fields holding matched values from the event.
+	RedactDrop // drop
+	// RedactHash replaces the matched values with the hex encoded HMAC-SHA256
+	// of them, keyed with the hash key of the `Redactor`. Equal values result
+	// in equal hashes, which allows joining on them without revealing them.
+	RedactHash // hash
+)
+
+// RedactRule describes which values of an event to redact and how.
+type RedactRule struct {
+	// Path of the field to redact, with the names of nested fields separated
+	// by dots, e.g. "user.email". Without a pattern, the whole value of the
+	// field is redacted, which can also be an object. With a pattern, the rule
+	// is restricted to the values of the field and the fields nested in it.
+	// Optional, if a pattern is given.
+	Path string
+	// Pattern to match the string and number values of the event against.
+	// Numbers are matched in their decimal notation. Only the matching parts
+	// of a value are masked or hashed, which turns a number into a string.
+	// Optional, if a path is given.
+	Pattern *regexp.Regexp
+	// Action to take on the matched values. Defaults to `RedactMask`.
+	Action RedactAction
+}
+
+// RedactorConfig configures a `Redactor`.
+type RedactorConfig struct {
+	// Rules to apply to the events, in order.
+	Rules []RedactRule
+	// Mask replaces values redacted by `RedactMask` rules. Defaults to
+	// "[REDACTED]".
+	Mask string
+	// HashKey is the secret key values redacted by `RedactHash` rules are
+	// hashed with. Required, if any of the rules hashes.
+	HashKey []byte
+}
+
+// Redactor is a `Processor` which redacts personally identifiable information
+// from events, as described by its rules. Pass it to `SetProcessor` to redact
+// the events passed to `Datasets.IngestEvents` and `Datasets.IngestChannel`, to
+// the `SetProcessor` option of the adapters to redact logs or to
+// `NewProcessingReader` to redact NDJSON passed to `Datasets.Ingest`.
+//
+// Arrays are traversed, with their elements sharing the path of the field
+// holding the array. The given events are never modified.
+type Redactor struct {
+	rules []RedactRule
+	mask  string
+
+	hashPool sync.Pool
+}
+
+var _ Processor = (*Redactor)(nil)
+
+// NewRedactor returns a new `Redactor` with the given configuration.
+func NewRedactor(config RedactorConfig) (*Redactor, error) {
+	r := &Redactor{
+		rules: config.Rules,
+		mask:  config.Mask,
+	}
+	if r.mask == "" {
+		r.mask = defaultMask
+	}
+
+	for i, rule := range config.Rules {
+		if rule.Path == "" && rule.Pattern == nil {
+			return nil, fmt.Errorf("redact rule %d has neither path nor pattern", i)
+		} else if rule.Action > RedactHash {
+			return nil, fmt.Errorf("redact rule %d has invalid action %s", i, rule.Action)
+		} else if rule.Action == RedactHash && len(config.HashKey) == 0 {
+			return nil, errors.New("hash key is required for redact rules which hash")
+		}
+	}
+
+	key := config.HashKey
+	r.hashPool.New = func() any { return hmac.New(sha256.New, key) }
+
+	return r, nil
+}
+
+// Process implements `Processor`. It returns a redacted copy of the given
+// event.
+func (r *Redactor) Process(event map[string]any) map[string]any {
+	return r.redactObject(event, "")
+}
+
+// redactObject returns a redacted copy of the given object at the given path.
+func (r *Redactor) redactObject(obj map[string]any, path string) map[string]any {
+	res := make(map[string]any, len(obj))
+	for name, value := range obj {
+		if value, keep := r.redactField(value, joinPath(path, name)); keep {
+			res[name] = value
+		}
+	}
+	return res
+}
+
+// redactField returns the redacted value of the field at the given path and
+// if the field is to be kept at all.
+func (r *Redactor) redactField(value any, path string) (any, bool) {
+	for _, rule := range r.rules {
+		if rule.Pattern != nil || rule.Path != path {
+			continue
+		}
+		switch rule.Action {
+		case RedactDrop:
+			return nil, false
+		case RedactHash:
+			return r.hash(fmt.Sprint(value)), true
+		default:
+			return r.mask, true
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return r.redactString(v, path)
+	case json.Number:
+		return r.redactNumber(v, string(v), path)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return r.redactNumber(v, fmt.Sprint(v), path)
+	case float32:
+		return r.redactNumber(v, strconv.FormatFloat(float64(v), 'f', -1, 32), path)
+	case float64:
+		return r.redactNumber(v, strconv.FormatFloat(v, 'f', -1, 64), path)
+	case []any:
+		res := make([]any, 0, len(v))
+		for _, elem := range v {
+			if elem, keep := r.redactField(elem, path); keep {
+				res = append(res, elem)
+			}
+		}
+		return res, true
+	}
+
+	if obj, ok := asObject(value); ok {
+		return r.redactObject(obj, path), true
+	}
+	return value, true
+}
+
+// redactNumber applies the pattern rules matching the given path to the given
+// number, formatted as s. The number is kept as is, unless a rule redacts it.
+func (r *Redactor) redactNumber(value any, s, path string) (any, bool) {
+	res, keep := r.redactString(s, path)
+	if keep && res == s {
+		return value, true
+	}
+	return res, keep
+}
+
+// redactString applies the pattern rules matching the given path to the given
+// string value.
+func (r *Redactor) redactString(s, path string) (any, bool) {
+	for _, rule := range r.rules {
+		if rule.Pattern == nil || !withinPath(path, rule.Path) {
+			continue
+		}
+
+		check := matchCheckers[rule.Pattern]
+		if check == nil {
+			switch rule.Action {
+			case RedactDrop:
+				if rule.Pattern.MatchString(s) {
+					return nil, false
+				}
+			case RedactHash:
+				s = rule.Pattern.ReplaceAllStringFunc(s, r.hash)
+			default:
+				s = rule.Pattern.ReplaceAllLiteralString(s, r.mask)
+			}
+			continue
+		}
+
+		var matched bool
+		s = rule.Pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if !check(match) {
+				return match
+			}
+			matched = true
+			if rule.Action == RedactHash {
+				return r.hash(match)
+			}
+			return r.mask
+		})
+		if matched && rule.Action == RedactDrop {
+			return nil, false
+		}
+	}
+	return s, true
+}
+
+// hash returns the hex encoded HMAC of the given value.
+func (r *Redactor) hash(s string) string {
+	h := r.hashPool.Get().(hash.Hash)
+	defer r.hashPool.Put(h)
+
+	h.Reset()
+	_, _ = h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// validLuhn reports if the digits of the given string have a valid Luhn
+// checksum. Other characters are ignored.
+func validLuhn(s string) bool {
+	var sum, digits int
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits > 0 && sum%10 == 0
+}
+
+// withinPath reports if the given path is the given parent path or nested in
+// it. Every path is within the empty parent path.
+func withinPath(path, parent string) bool {
+	return parent == "" || path == parent || strings.HasPrefix(path, parent+".")
+}
@@ -0,0 +1,152 @@
+package ingest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor(t *testing.T) {
+	key := []byte("secret")
+	hash := func(s string) string {
+		h := hmac.New(sha256.New, key)
+		_, _ = h.Write([]byte(s))
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	r, err := NewRedactor(RedactorConfig{
+		Rules: []RedactRule{
+			{Path: "user.password", Action: RedactDrop},
+			{Path: "user.id", Action: RedactHash},
+			{Path: "session"},
+			{Path: "user", Pattern: EmailPattern, Action: RedactHash},
+			{Pattern: EmailPattern},
+			{Pattern: IPv4Pattern},
+			{Pattern: IPv6Pattern},
+			{Pattern: CreditCardPattern},
+			{Pattern: regexp.MustCompile(`^DROP`), Action: RedactDrop},
+		},
+		HashKey: key,
+	})
+	require.NoError(t, err)
+
+	type object map[string]any
+
+	event := map[string]any{
+		"user": object{
+			"id":       42,
+			"email":    "jane@example.com",
+			"password": "hunter2",
+		},
+		"session": map[string]any{"token": "abc"},
+		"message": "jane@example.com logged in from 192.168.0.1 and 2001:db8::1",
+		"payment": "paid with 4111 1111 1111 1111",
+		"tags":    []any{"ok", "DROP me", "10.0.0.1"},
+		"count":   3,
+	}
+
+	got := r.Process(event)
+
+	assert.Equal(t, map[string]any{
+		"user": map[string]any{
+			"id":    hash("42"),
+			"email": hash("jane@example.com"),
+		},
+		"session": "[REDACTED]",
+		"message": "[REDACTED] logged in from [REDACTED] and [REDACTED]",
+		"payment": "paid with [REDACTED]",
+		"tags":    []any{"ok", "[REDACTED]"},
+		"count":   3,
+	}, got)
+
+	// The event passed must not be modified.
+	assert.Equal(t, "hunter2", event["user"].(object)["password"])
+}
+
+func TestRedactor_Numbers(t *testing.T) {
+	r, err := NewRedactor(RedactorConfig{
+		Rules: []RedactRule{{Pattern: CreditCardPattern}},
+	})
+	require.NoError(t, err)
+
+	got := r.Process(map[string]any{
+		"number":  json.Number("4111111111111111"),
+		"int":     4111111111111111,
+		"float":   float64(4111111111111111),
+		"uint":    uint64(5500005555555559),
+		"amount":  json.Number("12.5"),
+		"count":   42,
+		"ts":      int64(1700000000000),
+		"message": "sent at 1700000000000 with 4111-1111-1111-1111",
+	})
+
+	assert.Equal(t, map[string]any{
+		"number":  "[REDACTED]",
+		"int":     "[REDACTED]",
+		"float":   "[REDACTED]",
+		"uint":    "[REDACTED]",
+		"amount":  json.Number("12.5"),
+		"count":   42,
+		"ts":      int64(1700000000000),
+		"message": "sent at 1700000000000 with [REDACTED]",
+	}, got)
+}
+
+func TestValidLuhn(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		want  bool
+	}{
+		{"4111111111111111", true},
+		{"4111 1111 1111 1111", true},
+		{"5500-0055-5555-5559", true},
+		{"4111111111111112", false},
+		{"1700000000000", false},
+		{"", false},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			assert.Equal(t, tt.want, validLuhn(tt.input))
+		})
+	}
+}
+
+func TestNewRedactor_Invalid(t *testing.T) {
+	_, err := NewRedactor(RedactorConfig{Rules: []RedactRule{{}}})
+	assert.EqualError(t, err, "redact rule 0 has neither path nor pattern")
+
+	_, err = NewRedactor(RedactorConfig{Rules: []RedactRule{{Path: "a", Action: RedactHash}}})
+	assert.EqualError(t, err, "hash key is required for redact rules which hash")
+
+	_, err = NewRedactor(RedactorConfig{Rules: []RedactRule{{Path: "a", Action: 5}}})
+	assert.EqualError(t, err, "redact rule 0 has invalid action RedactAction(5)")
+}
+
+func TestNewProcessingReader(t *testing.T) {
+	r, err := NewRedactor(RedactorConfig{
+		Rules: []RedactRule{{Pattern: EmailPattern}},
+		Mask:  "***",
+	})
+	require.NoError(t, err)
+
+	input := `{"msg":"mail jane@example.com","n":12345678901234567890}` + "\n" +
+		`{"msg":"<none>"}` + "\n"
+
+	pr := NewProcessingReader(strings.NewReader(input), Pipeline{
+		r,
+		Filter(func(event map[string]any) bool { return event["msg"] != "<none>" }),
+	})
+	defer pr.Close()
+
+	b, err := io.ReadAll(pr)
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"msg":"mail ***","n":12345678901234567890}`+"\n", string(b))
+}
@@ -0,0 +1,178 @@
+package ingest
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SampleRateField is the field a `Sampler` sets on the events it keeps. It
+// holds the rate the event was sampled at, which is the amount of events it
+// represents. Multiplying by it restores the original counts in queries, e.g.
+// `summarize sum(_sample_rate)` instead of `summarize count()`.
+const SampleRateField = "_sample_rate"
+
+const defaultSampleWindow = 30 * time.Second
+
+// SamplerConfig configures a `Sampler`. The zero value keeps all events.
+type SamplerConfig struct {
+	// Rate keeps one in Rate events, at random. With dynamic sampling, it is
+	// the minimum rate of each key. Defaults to one, which keeps all events.
+	Rate int
+	// KeyFields enables dynamic sampling if set. The values of the given
+	// fields make up the key of an event, e.g. its route and status. Each key
+	// is sampled at a rate of its own which keeps about `TargetPerKey` events
+	// per window, so rare keys are kept while frequent ones are thinned out.
+	KeyFields []string
+	// TargetPerKey is the amount of events to keep per key and window. Only
+	// used for dynamic sampling. Defaults to 10.
+	TargetPerKey int
+	// Window is the interval the frequency of keys is measured over. Only used
+	// for dynamic sampling. Defaults to 30 seconds.
+	Window time.Duration
+	// AlwaysKeep, if set, reports if an event must be kept regardless of its
+	// rate, e.g. because it is an error. Such events are kept with a rate of
+	// one. `FieldEquals` creates such a function.
+	AlwaysKeep func(event map[string]any) bool
+}
+
+// Sampler is a `Processor` which keeps only a sample of the events, to reduce
+// the amount of data ingested. Each event kept carries its sample rate in the
+// `SampleRateField`. Pass it to `SetProcessor` to sample the events passed to
+// `Datasets.IngestEvents` and `Datasets.IngestChannel` or to the
+// `SetProcessor` option of the adapters to sample logs. It is safe for
+// concurrent use.
+type Sampler struct {
+	config SamplerConfig
+
+	mtx         sync.Mutex
+	rand        *rand.Rand
+	windowStart time.Time
+	keys        map[string]*keyCount
+}
+
+// keyCount counts the events of a key in the current and previous window.
+type keyCount struct {
+	current, previous int
+}
+
+var _ Processor = (*Sampler)(nil)
+
+// NewSampler returns a new `Sampler` with the given configuration.
+func NewSampler(config SamplerConfig) *Sampler {
+	if config.Rate < 1 {
+		config.Rate = 1
+	}
+	if config.TargetPerKey < 1 {
+		config.TargetPerKey = 10
+	}
+	if config.Window <= 0 {
+		config.Window = defaultSampleWindow
+	}
+
+	return &Sampler{
+		config: config,
+		rand:   rand.New(rand.NewSource(timeNow().UnixNano())), //nolint:gosec // Sampling doesn't need to be secure.
+		keys:   make(map[string]*keyCount),
+	}
+}
+
+// Process implements `Processor`. It returns a copy of the given event with
+// its sample rate set or nil, if the event is not part of the sample.
+func (s *Sampler) Process(event map[string]any) map[string]any {
+	rate := 1
+	if s.config.AlwaysKeep == nil || !s.config.AlwaysKeep(event) {
+		s.mtx.Lock()
+		rate = s.rate(event)
+		keep := rate == 1 || s.rand.Intn(rate) == 0
+		s.mtx.Unlock()
+
+		if !keep {
+			return nil
+		}
+	}
+
+	res := copyEvent(event)
+	res[SampleRateField] = rate
+	return res
+}
+
+// rate returns the rate to sample the given event at. The lock must be held.
+func (s *Sampler) rate(event map[string]any) int {
+	if len(s.config.KeyFields) == 0 {
+		return s.config.Rate
+	}
+
+	now := timeNow()
+	if elapsed := now.Sub(s.windowStart); elapsed >= s.config.Window {
+		for key, count := range s.keys {
+			// Forget about keys which have not been seen for a whole window.
+			if count.current == 0 || elapsed >= 2*s.config.Window {
+				delete(s.keys, key)
+				continue
+			}
+			count.previous, count.current = count.current, 0
+		}
+		s.windowStart = now
+	}
+
+	key := sampleKey(event, s.config.KeyFields)
+	count, ok := s.keys[key]
+	if !ok {
+		count = new(keyCount)
+		s.keys[key] = count
+	}
+	count.current++
+
+	// Base the rate on the previous window, unless the key is already more
+	// frequent in the current one, to react to sudden spikes.
+	seen := count.previous
+	if count.current > seen {
+		seen = count.current
+	}
+
+	rate := (seen + s.config.TargetPerKey - 1) / s.config.TargetPerKey
+	if rate < s.config.Rate {
+		rate = s.config.Rate
+	}
+	return rate
+}
+
+// sampleKey returns the key made up of the values of the given fields.
+func sampleKey(event map[string]any, fields []string) string {
+	var sb strings.Builder
+	for i, field := range fields {
+		if i > 0 {
+			sb.WriteByte(0)
+		}
+		if v, ok := event[field]; ok {
+			fmt.Fprint(&sb, v)
+		}
+	}
+	return sb.String()
+}
+
+// FieldEquals returns a function which reports if the value of the given field
+// of an event equals one of the given values, e.g. to always keep errors when
+// sampling:
+//
+//	ingest.NewSampler(ingest.SamplerConfig{
+//		Rate:       10,
+//		AlwaysKeep: ingest.FieldEquals("level", "error", "fatal"),
+//	})
+func FieldEquals(field string, values ...any) func(event map[string]any) bool {
+	return func(event map[string]any) bool {
+		v, ok := event[field]
+		if !ok {
+			return false
+		}
+		for _, value := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
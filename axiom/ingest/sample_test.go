@@ -0,0 +1,113 @@
+package ingest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampler_Rate(t *testing.T) {
+	s := NewSampler(SamplerConfig{Rate: 4})
+
+	var kept int
+	for i := 0; i < 10000; i++ {
+		if event := s.Process(map[string]any{"n": i}); event != nil {
+			assert.Equal(t, 4, event[SampleRateField])
+			kept++
+		}
+	}
+
+	// Allow for the randomness of the sample.
+	assert.InDelta(t, 2500, kept, 250)
+}
+
+func TestSampler_KeepAll(t *testing.T) {
+	s := NewSampler(SamplerConfig{})
+
+	event := map[string]any{"a": 1}
+	assert.Equal(t, map[string]any{"a": 1, SampleRateField: 1}, s.Process(event))
+
+	// The event passed must not be modified.
+	assert.NotContains(t, event, SampleRateField)
+}
+
+func TestSampler_AlwaysKeep(t *testing.T) {
+	s := NewSampler(SamplerConfig{
+		Rate:       1000000,
+		AlwaysKeep: FieldEquals("level", "error", "fatal"),
+	})
+
+	for i := 0; i < 100; i++ {
+		event := s.Process(map[string]any{"level": "error"})
+		require.NotNil(t, event)
+		assert.Equal(t, 1, event[SampleRateField])
+	}
+}
+
+func TestSampler_Dynamic(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+
+	s := NewSampler(SamplerConfig{
+		KeyFields:    []string{"route", "status"},
+		TargetPerKey: 10,
+		Window:       time.Minute,
+	})
+
+	process := func(route string, n int) (kept int, rate int) {
+		for i := 0; i < n; i++ {
+			if event := s.Process(map[string]any{"route": route, "status": 200}); event != nil {
+				kept++
+				rate = event[SampleRateField].(int)
+			}
+		}
+		return kept, rate
+	}
+
+	// A rare key is kept completely.
+	kept, rate := process("/rare", 5)
+	assert.Equal(t, 5, kept)
+	assert.Equal(t, 1, rate)
+
+	// A frequent key is thinned out as soon as it exceeds the target.
+	kept, _ = process("/hot", 1000)
+	assert.Less(t, kept, 200)
+
+	// In the next window, the frequent key is sampled at the rate of the
+	// previous window right away.
+	now = now.Add(time.Minute)
+	s.mtx.Lock()
+	rate = s.rate(map[string]any{"route": "/hot", "status": 200})
+	s.mtx.Unlock()
+	assert.Equal(t, 100, rate)
+
+	// Keys not seen for a whole window are forgotten.
+	now = now.Add(time.Minute)
+	kept, _ = process("/hot", 1)
+	assert.LessOrEqual(t, kept, 1)
+	assert.NotContains(t, s.keys, "/rare\x00200")
+	assert.Contains(t, s.keys, "/hot\x00200")
+}
+
+func TestFieldEquals(t *testing.T) {
+	f := FieldEquals("status", 500, "error")
+
+	for _, tt := range []struct {
+		event map[string]any
+		want  bool
+	}{
+		{map[string]any{"status": 500}, true},
+		{map[string]any{"status": "error"}, true},
+		{map[string]any{"status": 200}, false},
+		{map[string]any{"status": map[string]any{}}, false},
+		{map[string]any{}, false},
+	} {
+		t.Run(fmt.Sprint(tt.event), func(t *testing.T) {
+			assert.Equal(t, tt.want, f(tt.event))
+		})
+	}
+}
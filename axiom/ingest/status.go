@@ -1,15 +1,25 @@
 package ingest
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Status is the status of an event ingestion operation.
 type Status struct {
 	// Ingested is the amount of events that have been ingested.
 	Ingested uint64 `json:"ingested"`
-	// Failed is the amount of events that failed to ingest.
+	// Failed is the amount of events that failed to ingest, including the
+	// ones in Rejected.
 	Failed uint64 `json:"failed"`
-	// Failures are the ingestion failures, if any.
+	// Failures are the ingestion failures reported by the server, if any, in
+	// the order the events were submitted.
 	Failures []*Failure `json:"failures"`
+	// Rejected are the failures of the events the client rejected before
+	// sending them, e.g. because a `Validator` found them invalid, in the
+	// order the events were submitted. Each one carries the index of its
+	// event.
+	Rejected []*Failure `json:"-"`
 	// ProcessedBytes is the number of bytes processed.
 	ProcessedBytes uint64 `json:"processedBytes"`
 	// BlocksCreated is the amount of blocks created.
@@ -18,10 +28,42 @@ type Status struct {
 	WALLength uint32 `json:"walLength"`
 }
 
+// Add adds the status of another ingestion operation to the status. It is used
+// to merge the statuses of multiple requests, e.g. when the events to ingest
+// are split into multiple requests. Failures are appended in order.
+func (s *Status) Add(other *Status) {
+	if other == nil {
+		return
+	}
+	s.Ingested += other.Ingested
+	s.Failed += other.Failed
+	s.Failures = append(s.Failures, other.Failures...)
+	s.Rejected = append(s.Rejected, other.Rejected...)
+	s.ProcessedBytes += other.ProcessedBytes
+	s.BlocksCreated += other.BlocksCreated
+	if other.WALLength > s.WALLength {
+		s.WALLength = other.WALLength
+	}
+}
+
 // Failure describes the ingestion failure of a single event.
 type Failure struct {
 	// Timestamp of the event that failed to ingest.
 	Timestamp time.Time `json:"timestamp"`
 	// Error that made the event fail to ingest.
 	Error string `json:"error"`
+	// Index of the event among the events passed to the ingest method. The
+	// server doesn't report it, but the methods ingesting events map the
+	// failures it reports back to the events they sent. -1, if unknown.
+	Index int `json:"-"`
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`. It is in place to mark the index
+// of a failure reported by the server as unknown, as it is not part of the
+// response.
+func (f *Failure) UnmarshalJSON(b []byte) error {
+	type localFailure *Failure
+
+	f.Index = -1
+	return json.Unmarshal(b, localFailure(f))
 }
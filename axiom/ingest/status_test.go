@@ -0,0 +1,58 @@
+package ingest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatus_Add(t *testing.T) {
+	var (
+		f1 = &Failure{Timestamp: time.Unix(1, 0), Error: "first"}
+		f2 = &Failure{Timestamp: time.Unix(2, 0), Error: "second"}
+		r1 = &Failure{Error: "rejected", Index: 3}
+	)
+
+	s := Status{
+		Ingested:       2,
+		Failed:         1,
+		Failures:       []*Failure{f1},
+		ProcessedBytes: 100,
+		BlocksCreated:  1,
+		WALLength:      5,
+	}
+	s.Add(&Status{
+		Ingested:       3,
+		Failed:         2,
+		Failures:       []*Failure{f2},
+		Rejected:       []*Failure{r1},
+		ProcessedBytes: 200,
+		WALLength:      8,
+	})
+	s.Add(nil)
+
+	assert.Equal(t, Status{
+		Ingested:       5,
+		Failed:         3,
+		Failures:       []*Failure{f1, f2},
+		Rejected:       []*Failure{r1},
+		ProcessedBytes: 300,
+		BlocksCreated:  1,
+		WALLength:      8,
+	}, s)
+}
+
+func TestFailure_UnmarshalJSON(t *testing.T) {
+	var s Status
+	err := json.Unmarshal([]byte(`{"failed":1,"failures":[{"timestamp":"2022-01-01T00:00:00Z","error":"invalid event"}]}`), &s)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*Failure{{
+		Timestamp: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Error:     "invalid event",
+		Index:     -1,
+	}}, s.Failures)
+}
@@ -0,0 +1,244 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	nilValue = "-"
+	utf8BOM  = "\ufeff"
+)
+
+var (
+	syslogFacilities = [...]string{
+		"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+		"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console",
+		"solaris-cron", "local0", "local1", "local2", "local3", "local4",
+		"local5", "local6", "local7",
+	}
+	syslogSeverities = [...]string{
+		"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+	}
+)
+
+// NewSyslogReader returns a reader which converts the syslog lines read from
+// the given reader into NDJSON, which can be passed to `Datasets.Ingest`. Both
+// RFC 5424 and RFC 3164 (BSD) formatted lines are supported and can be mixed.
+//
+// Every line becomes an event with the fields "facility", "severity",
+// "hostname", "appname", "procid" and "message". RFC 5424 lines additionally
+// have the fields "version", "msgid" and "structured_data", which maps the ids
+// of the structured data elements to their parameters. Fields with a nil value
+// are omitted. The timestamp of a line is set as the `_time` field. As RFC 3164
+// timestamps carry neither year nor time zone, the current year and the
+// configured location are assumed. Closing the reader before it is drained
+// stops the conversion.
+func NewSyslogReader(r io.Reader, config ConverterConfig) io.ReadCloser {
+	return convertLines(r, config, func(line string) (map[string]any, error) {
+		return parseSyslog(line, config)
+	})
+}
+
+// parseSyslog parses a single RFC 5424 or RFC 3164 syslog line into an event.
+func parseSyslog(line string, config ConverterConfig) (map[string]any, error) {
+	if !strings.HasPrefix(line, "<") {
+		return nil, errors.New("missing priority")
+	}
+
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return nil, errors.New("invalid priority")
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri > 191 {
+		return nil, fmt.Errorf("invalid priority %q", line[1:end])
+	}
+
+	event := map[string]any{
+		"facility": syslogFacilities[pri/8],
+		"severity": syslogSeverities[pri%8],
+	}
+
+	// RFC 5424 lines continue with a version, which is "1" at the time of
+	// writing, RFC 3164 lines with the month of their timestamp.
+	rest := line[end+1:]
+	if version, tail, ok := strings.Cut(rest, " "); ok && version != "" && isDigits(version) {
+		event["version"], _ = strconv.Atoi(version)
+		err = parseSyslog5424(event, tail)
+	} else {
+		err = parseSyslog3164(event, rest, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// parseSyslog5424 parses the part of an RFC 5424 line following the version
+// into the given event.
+func parseSyslog5424(event map[string]any, s string) error {
+	fields := strings.SplitN(s, " ", 6)
+	if len(fields) < 6 {
+		return errors.New("incomplete header")
+	}
+
+	if ts := fields[0]; ts != nilValue {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q", ts)
+		}
+		event[TimestampField] = t
+	}
+
+	for i, name := range []string{"hostname", "appname", "procid", "msgid"} {
+		if value := fields[i+1]; value != nilValue {
+			event[name] = value
+		}
+	}
+
+	sd, msg, err := parseStructuredData(fields[5])
+	if err != nil {
+		return err
+	}
+	if sd != nil {
+		event["structured_data"] = sd
+	}
+
+	if msg = strings.TrimPrefix(msg, utf8BOM); msg != "" {
+		event["message"] = msg
+	}
+
+	return nil
+}
+
+// parseStructuredData parses the structured data at the beginning of the given
+// string and returns it along with the remaining message.
+func parseStructuredData(s string) (map[string]any, string, error) {
+	if s == nilValue || strings.HasPrefix(s, nilValue+" ") {
+		return nil, strings.TrimPrefix(s[len(nilValue):], " "), nil
+	} else if !strings.HasPrefix(s, "[") {
+		return nil, "", errors.New("invalid structured data")
+	}
+
+	sd := make(map[string]any)
+	for strings.HasPrefix(s, "[") {
+		s = s[1:]
+
+		end := strings.IndexAny(s, " ]")
+		if end <= 0 {
+			return nil, "", errors.New("invalid structured data element")
+		}
+		id := s[:end]
+		s = s[end:]
+
+		params := make(map[string]any)
+		for strings.HasPrefix(s, " ") {
+			s = s[1:]
+
+			name, tail, ok := strings.Cut(s, `="`)
+			if !ok || name == "" {
+				return nil, "", fmt.Errorf("invalid parameter in structured data element %q", id)
+			}
+
+			var (
+				value strings.Builder
+				i     int
+			)
+			for ; i < len(tail) && tail[i] != '"'; i++ {
+				// Only quotes, backslashes and closing brackets are escaped.
+				if tail[i] == '\\' && i+1 < len(tail) && strings.IndexByte(`"\]`, tail[i+1]) >= 0 {
+					i++
+				}
+				value.WriteByte(tail[i])
+			}
+			if i == len(tail) {
+				return nil, "", fmt.Errorf("unterminated parameter %q in structured data element %q", name, id)
+			}
+
+			params[name] = value.String()
+			s = tail[i+1:]
+		}
+
+		if !strings.HasPrefix(s, "]") {
+			return nil, "", fmt.Errorf("unterminated structured data element %q", id)
+		}
+		s = s[1:]
+
+		sd[id] = params
+	}
+
+	if s != "" && !strings.HasPrefix(s, " ") {
+		return nil, "", errors.New("invalid structured data")
+	}
+
+	return sd, strings.TrimPrefix(s, " "), nil
+}
+
+// parseSyslog3164 parses the part of an RFC 3164 line following the priority
+// into the given event.
+func parseSyslog3164(event map[string]any, s string, config ConverterConfig) error {
+	// The timestamp has a fixed length, e.g. "Oct 11 22:14:15", with single
+	// digit days padded by a space.
+	const layout = "Jan _2 15:04:05"
+	if len(s) < len(layout) {
+		return errors.New("missing timestamp")
+	}
+
+	loc := config.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	ts, err := time.ParseInLocation(layout, s[:len(layout)], loc)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", s[:len(layout)])
+	}
+
+	// Assume the timestamp to be from the current year, unless it would be in
+	// the future, which happens at the turn of the year.
+	now := timeNow().In(loc)
+	ts = time.Date(now.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), 0, loc)
+	if ts.After(now.Add(24 * time.Hour)) {
+		ts = ts.AddDate(-1, 0, 0)
+	}
+	event[TimestampField] = ts
+
+	rest := strings.TrimPrefix(s[len(layout):], " ")
+
+	hostname, rest, _ := strings.Cut(rest, " ")
+	if hostname != "" {
+		event["hostname"] = hostname
+	}
+
+	// The tag is the name of the program, optionally followed by its process
+	// id in brackets, and is terminated by a colon.
+	if tag, msg, ok := strings.Cut(rest, ": "); ok && tag != "" && !strings.ContainsRune(tag, ' ') {
+		if name, pid, ok := strings.Cut(tag, "["); ok && strings.HasSuffix(pid, "]") {
+			tag = name
+			event["procid"] = strings.TrimSuffix(pid, "]")
+		}
+		event["appname"] = tag
+		rest = msg
+	}
+
+	if rest != "" {
+		event["message"] = rest
+	}
+
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
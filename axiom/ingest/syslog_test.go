@@ -0,0 +1,131 @@
+package ingest
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSyslog(t *testing.T) {
+	now := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+
+	tests := []struct {
+		name    string
+		line    string
+		want    map[string]any
+		wantErr string
+	}{
+		{
+			name: "rfc 5424",
+			line: "<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - \ufeff'su root' failed",
+			want: map[string]any{
+				"_time":    time.Date(2003, 10, 11, 22, 14, 15, 3e6, time.UTC),
+				"facility": "auth",
+				"severity": "crit",
+				"version":  1,
+				"hostname": "mymachine.example.com",
+				"appname":  "su",
+				"msgid":    "ID47",
+				"message":  "'su root' failed",
+			},
+		},
+		{
+			name: "rfc 5424 structured data",
+			line: `<165>1 - host app 1234 - [exampleSDID@32473 iut="3" eventSource="App\"lication\]"][examplePriority@32473 class="high"]`,
+			want: map[string]any{
+				"facility": "local4",
+				"severity": "notice",
+				"version":  1,
+				"hostname": "host",
+				"appname":  "app",
+				"procid":   "1234",
+				"structured_data": map[string]any{
+					"exampleSDID@32473": map[string]any{
+						"iut":         "3",
+						"eventSource": `App"lication]`,
+					},
+					"examplePriority@32473": map[string]any{
+						"class": "high",
+					},
+				},
+			},
+		},
+		{
+			name: "rfc 3164",
+			line: "<13>Dec 31 23:59:59 myhost sshd[42]: Accepted publickey",
+			want: map[string]any{
+				"_time":    time.Date(2020, 12, 31, 23, 59, 59, 0, time.UTC),
+				"facility": "user",
+				"severity": "notice",
+				"hostname": "myhost",
+				"appname":  "sshd",
+				"procid":   "42",
+				"message":  "Accepted publickey",
+			},
+		},
+		{
+			name: "rfc 3164 without tag",
+			line: "<0>Jan  2 00:00:00 myhost kernel panic",
+			want: map[string]any{
+				"_time":    time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+				"facility": "kern",
+				"severity": "emerg",
+				"hostname": "myhost",
+				"message":  "kernel panic",
+			},
+		},
+		{
+			name:    "missing priority",
+			line:    "Oct 11 22:14:15 myhost hello",
+			wantErr: "missing priority",
+		},
+		{
+			name:    "invalid priority",
+			line:    "<200>1 - - - - - -",
+			wantErr: `invalid priority "200"`,
+		},
+		{
+			name:    "invalid timestamp",
+			line:    "<34>1 yesterday host app - - -",
+			wantErr: `invalid timestamp "yesterday"`,
+		},
+		{
+			name:    "unterminated structured data",
+			line:    `<34>1 - host app - - [id a="b"`,
+			wantErr: `unterminated structured data element "id"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSyslog(tt.line, ConverterConfig{Location: time.UTC})
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewSyslogReader(t *testing.T) {
+	input := "<34>1 2003-10-11T22:14:15Z host app - - - hello\nnot syslog\n"
+
+	r := NewSyslogReader(strings.NewReader(input), ConverterConfig{
+		Malformed: MalformedRaw,
+		RawField:  "line",
+	})
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"_time":"2003-10-11T22:14:15Z","appname":"app","facility":"auth","hostname":"host","message":"hello","severity":"crit","version":1}`+"\n"+
+		`{"line":"not syslog"}`+"\n", string(b))
+}
@@ -0,0 +1,377 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:generate go run golang.org/x/tools/cmd/stringer -type=ValidationMode -linecomment -output=validator_string.go
+
+const (
+	defaultMaxFieldNameLength = 200
+	defaultMaxDepth           = 10
+	defaultMaxFields          = 256
+)
+
+// ValidationMode controls what a `Validator` does with invalid events.
+type ValidationMode uint8
+
+// All available validation modes.
+const (
+	// ValidationReject rejects invalid events. They are not ingested.
+	ValidationReject ValidationMode = iota // reject
+	// ValidationSanitize fixes invalid events by renaming and flattening their
+	// fields. Events that can't be fixed are rejected.
+	ValidationSanitize // sanitize
+	// ValidationReport only reports invalid events. They are ingested as is.
+	ValidationReport // report
+)
+
+// FieldError describes why a field of an event is invalid.
+type FieldError struct {
+	// Field is the path of the invalid field, with the names of nested fields
+	// separated by dots. Empty, if the event as a whole is invalid.
+	Field string
+	// Reason the field is invalid.
+	Reason string
+}
+
+// Error implements the `error` interface.
+func (e FieldError) Error() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("field %q: %s", e.Field, e.Reason)
+}
+
+// ValidationError is returned by `Validator.Validate` for an invalid event.
+type ValidationError struct {
+	// Errors are the reasons the event is invalid.
+	Errors []FieldError
+}
+
+// Error implements the `error` interface.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "invalid event: " + strings.Join(msgs, "; ")
+}
+
+// Validator validates events before they are sent to the server, which would
+// otherwise only report violations of its field restrictions as ingestion
+// failures once the events are shipped. Zero values select the defaults
+// documented on each field. Pass it to an ingestion using `SetValidator`.
+type Validator struct {
+	// Mode controls what happens to invalid events. Defaults to
+	// `ValidationReject`.
+	Mode ValidationMode
+	// MaxFieldNameLength is the maximum length of a field name in bytes.
+	// Defaults to 200.
+	MaxFieldNameLength int
+	// MaxDepth is the maximum nesting depth of objects. Top-level fields are
+	// at a depth of one. Defaults to 10.
+	MaxDepth int
+	// MaxFields is the maximum amount of fields of an event, counting the
+	// fields of nested objects instead of the objects themselves. Defaults to
+	// 256.
+	MaxFields int
+	// AllowReservedFields allows top-level fields prefixed with an underscore,
+	// which are reserved for use by the server. The timestamp field and the
+	// `SampleRateField` are always allowed.
+	AllowReservedFields bool
+	// TimestampField is the field the time of an event is read from. Defaults
+	// to the timestamp field of the ingestion or `TimestampField`.
+	TimestampField string
+	// TimestampFormat is the format of the timestamp field, if it holds a
+	// string. Defaults to the timestamp format of the ingestion or RFC 3339.
+	TimestampFormat string
+	// OnInvalid, if set, is called with every invalid event, as it was passed
+	// to the validator, and the reason it is invalid, regardless of the mode.
+	OnInvalid func(event map[string]any, err *ValidationError)
+}
+
+// Validate validates the given event. It returns the event to ingest and, if
+// the event is invalid, a `*ValidationError`. Depending on the mode, the event
+// returned is the given one, a sanitized copy of it or nil, if it must not be
+// ingested. The given event is never modified.
+func (v *Validator) Validate(event map[string]any) (map[string]any, error) {
+	errs := v.check(event)
+	if len(errs) == 0 {
+		return event, nil
+	}
+
+	err := &ValidationError{Errors: errs}
+	if v.OnInvalid != nil {
+		v.OnInvalid(event, err)
+	}
+
+	switch v.Mode {
+	case ValidationReport:
+		return event, err
+	case ValidationSanitize:
+		sanitized := v.sanitize(event)
+		if len(v.check(sanitized)) > 0 {
+			return nil, err
+		}
+		return sanitized, err
+	default:
+		return nil, err
+	}
+}
+
+// check returns all reasons the given event is invalid.
+func (v *Validator) check(event map[string]any) []FieldError {
+	var errs []FieldError
+
+	tsField := v.timestampField()
+	for _, name := range sortedKeys(event) {
+		if isReserved(name, tsField) && !v.AllowReservedFields {
+			errs = append(errs, FieldError{Field: name, Reason: "field names starting with an underscore are reserved"})
+		}
+	}
+
+	fields := v.checkObject(event, "", 1, &errs)
+	if max := v.maxFields(); fields > max {
+		errs = append(errs, FieldError{Reason: fmt.Sprintf("%d fields exceed maximum of %d", fields, max)})
+	}
+
+	if ts, ok := event[tsField]; ok && !v.validTimestamp(ts) {
+		errs = append(errs, FieldError{Field: tsField, Reason: "invalid timestamp"})
+	}
+
+	return errs
+}
+
+// checkObject checks the field names and nesting depth of the given object at
+// the given path and depth and returns the amount of fields it holds.
+func (v *Validator) checkObject(obj map[string]any, path string, depth int, errs *[]FieldError) int {
+	var fields int
+	for _, name := range sortedKeys(obj) {
+		fieldPath := joinPath(path, name)
+		if reason := v.checkName(name); reason != "" {
+			*errs = append(*errs, FieldError{Field: fieldPath, Reason: reason})
+		}
+
+		nested, ok := asObject(obj[name])
+		if !ok {
+			fields++
+			continue
+		}
+		if depth >= v.maxDepth() {
+			*errs = append(*errs, FieldError{Field: fieldPath, Reason: fmt.Sprintf("nesting exceeds maximum depth of %d", v.maxDepth())})
+		}
+		fields += v.checkObject(nested, fieldPath, depth+1, errs)
+	}
+	return fields
+}
+
+// checkName returns why the given field name is invalid, if it is.
+func (v *Validator) checkName(name string) string {
+	switch {
+	case name == "":
+		return "field name is empty"
+	case len(name) > v.maxFieldNameLength():
+		return fmt.Sprintf("field name exceeds maximum length of %d bytes", v.maxFieldNameLength())
+	case !utf8.ValidString(name):
+		return "field name is not valid UTF-8"
+	case strings.IndexFunc(name, unicode.IsControl) >= 0:
+		return "field name contains control characters"
+	}
+	return ""
+}
+
+// validTimestamp reports if the given value of the timestamp field can be
+// parsed as a time.
+func (v *Validator) validTimestamp(ts any) bool {
+	switch ts := ts.(type) {
+	case nil, time.Time, float64, float32, int, int64, int32, uint, uint64, uint32:
+		return true
+	case json.Number:
+		_, err := ts.Float64()
+		return err == nil
+	case string:
+		format := v.TimestampFormat
+		if format == "" {
+			format = time.RFC3339Nano
+		}
+		_, err := time.Parse(format, ts)
+		return err == nil
+	}
+	return false
+}
+
+// sanitize returns a copy of the given event with invalid field names fixed,
+// reserved fields and unparsable timestamp fields renamed and objects nested
+// too deep flattened.
+func (v *Validator) sanitize(event map[string]any) map[string]any {
+	res := v.sanitizeObject(event, 1)
+
+	tsField := v.timestampField()
+	if ts, ok := res[tsField]; ok && !v.validTimestamp(ts) {
+		delete(res, tsField)
+		res[uniqueName(res, strings.TrimLeft(tsField, "_")+"_invalid")] = ts
+	}
+
+	if !v.AllowReservedFields {
+		for _, name := range sortedKeys(res) {
+			if !isReserved(name, tsField) {
+				continue
+			}
+			val := res[name]
+			delete(res, name)
+			res[uniqueName(res, v.sanitizeName(strings.TrimLeft(name, "_")))] = val
+		}
+	}
+
+	return res
+}
+
+// sanitizeObject returns a copy of the given object at the given depth with
+// invalid field names fixed and objects nested too deep flattened.
+func (v *Validator) sanitizeObject(obj map[string]any, depth int) map[string]any {
+	res := make(map[string]any, len(obj))
+	for _, name := range sortedKeys(obj) {
+		val := obj[name]
+		name = uniqueName(res, v.sanitizeName(name))
+
+		nested, ok := asObject(val)
+		switch {
+		case !ok:
+			res[name] = val
+		case depth >= v.maxDepth():
+			v.flatten(res, name, nested)
+		default:
+			res[name] = v.sanitizeObject(nested, depth+1)
+		}
+	}
+	return res
+}
+
+// flatten adds the fields of the given object to the given destination, with
+// their names prefixed by the given one.
+func (v *Validator) flatten(dst map[string]any, prefix string, obj map[string]any) {
+	for _, name := range sortedKeys(obj) {
+		flatName := v.sanitizeName(joinPath(prefix, name))
+		if nested, ok := asObject(obj[name]); ok {
+			v.flatten(dst, flatName, nested)
+			continue
+		}
+		dst[uniqueName(dst, flatName)] = obj[name]
+	}
+}
+
+// sanitizeName returns a valid version of the given field name.
+func (v *Validator) sanitizeName(name string) string {
+	name = strings.ToValidUTF8(name, "_")
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	if max := v.maxFieldNameLength(); len(name) > max {
+		// Make sure not to cut a multi-byte character in half.
+		for max > 0 && !utf8.RuneStart(name[max]) {
+			max--
+		}
+		name = name[:max]
+	}
+
+	if name == "" {
+		name = "unnamed"
+	}
+	return name
+}
+
+func (v *Validator) timestampField() string {
+	if v.TimestampField != "" {
+		return v.TimestampField
+	}
+	return TimestampField
+}
+
+func (v *Validator) maxFieldNameLength() int {
+	if v.MaxFieldNameLength > 0 {
+		return v.MaxFieldNameLength
+	}
+	return defaultMaxFieldNameLength
+}
+
+func (v *Validator) maxDepth() int {
+	if v.MaxDepth > 0 {
+		return v.MaxDepth
+	}
+	return defaultMaxDepth
+}
+
+func (v *Validator) maxFields() int {
+	if v.MaxFields > 0 {
+		return v.MaxFields
+	}
+	return defaultMaxFields
+}
+
+// isReserved reports if the given top-level field name is reserved for use by
+// the server.
+func isReserved(name, tsField string) bool {
+	return strings.HasPrefix(name, "_") && name != tsField && name != SampleRateField
+}
+
+// asObject returns the given value as an object, if it is a map with string
+// keys, like a nested `axiom.Event`.
+func asObject(val any) (map[string]any, bool) {
+	if obj, ok := val.(map[string]any); ok {
+		return obj, true
+	}
+
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+
+	obj := make(map[string]any, rv.Len())
+	for iter := rv.MapRange(); iter.Next(); {
+		obj[iter.Key().String()] = iter.Value().Interface()
+	}
+	return obj, true
+}
+
+// uniqueName returns the given name or, if it is already taken in the given
+// object, the name suffixed with a number that makes it unique.
+func uniqueName(obj map[string]any, name string) string {
+	candidate := name
+	for i := 2; ; i++ {
+		if _, ok := obj[candidate]; !ok {
+			return candidate
+		}
+		candidate = name + "_" + strconv.Itoa(i)
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// sortedKeys returns the keys of the given object in order, which makes
+// validation errors and sanitized names deterministic.
+func sortedKeys(obj map[string]any) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
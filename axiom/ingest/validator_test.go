@@ -0,0 +1,160 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidator_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		validator Validator
+		event     map[string]any
+		expErrs   []FieldError
+	}{
+		{
+			name: "valid",
+			event: map[string]any{
+				"_time":        "2020-03-18T13:56:21Z",
+				"_sample_rate": 10,
+				"foo":          map[string]any{"bar": 1},
+			},
+		},
+		{
+			name:  "reserved field",
+			event: map[string]any{"_foo": 1},
+			expErrs: []FieldError{
+				{Field: "_foo", Reason: "field names starting with an underscore are reserved"},
+			},
+		},
+		{
+			name:      "reserved field allowed",
+			validator: Validator{AllowReservedFields: true},
+			event:     map[string]any{"_foo": 1},
+		},
+		{
+			name:  "invalid names",
+			event: map[string]any{"": 1, "a\nb": map[string]any{"\xff": 2}},
+			expErrs: []FieldError{
+				{Field: "", Reason: "field name is empty"},
+				{Field: "a\nb", Reason: "field name contains control characters"},
+				{Field: "a\nb.\xff", Reason: "field name is not valid UTF-8"},
+			},
+		},
+		{
+			name:      "name too long",
+			validator: Validator{MaxFieldNameLength: 3},
+			event:     map[string]any{"abcd": 1},
+			expErrs: []FieldError{
+				{Field: "abcd", Reason: "field name exceeds maximum length of 3 bytes"},
+			},
+		},
+		{
+			name:      "too deep",
+			validator: Validator{MaxDepth: 2},
+			event:     map[string]any{"a": map[string]any{"b": map[string]string{"c": "d"}}},
+			expErrs: []FieldError{
+				{Field: "a.b", Reason: "nesting exceeds maximum depth of 2"},
+			},
+		},
+		{
+			name:      "too many fields",
+			validator: Validator{MaxFields: 2},
+			event:     map[string]any{"a": 1, "b": map[string]any{"c": 2, "d": 3}},
+			expErrs: []FieldError{
+				{Reason: "3 fields exceed maximum of 2"},
+			},
+		},
+		{
+			name:  "invalid timestamp",
+			event: map[string]any{"_time": "yesterday"},
+			expErrs: []FieldError{
+				{Field: "_time", Reason: "invalid timestamp"},
+			},
+		},
+		{
+			name:      "custom timestamp",
+			validator: Validator{TimestampField: "ts", TimestampFormat: time.RFC1123},
+			event:     map[string]any{"ts": "Wed, 18 Mar 2020 13:56:21 UTC"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.validator.Validate(tt.event)
+			if tt.expErrs == nil {
+				require.NoError(t, err)
+				assert.Equal(t, tt.event, res)
+				return
+			}
+
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.expErrs, validationErr.Errors)
+			assert.Nil(t, res)
+		})
+	}
+}
+
+func TestValidator_Validate_Sanitize(t *testing.T) {
+	v := Validator{
+		Mode:               ValidationSanitize,
+		MaxFieldNameLength: 12,
+		MaxDepth:           2,
+	}
+
+	event := map[string]any{
+		"_time":          "yesterday",
+		"_foo":           1,
+		"foo":            2,
+		"a\tb":           3,
+		"toolong_name_x": 4,
+		"n":              map[string]any{"e": map[string]any{"s": 5}},
+	}
+
+	res, err := v.Validate(event)
+	require.Error(t, err)
+
+	assert.Equal(t, map[string]any{
+		"time_invalid": "yesterday",
+		"foo_2":        1,
+		"foo":          2,
+		"a_b":          3,
+		"toolong_name": 4,
+		"n":            map[string]any{"e.s": 5},
+	}, res)
+
+	// The event passed must not be modified.
+	assert.Contains(t, event, "_foo")
+
+	res, err = v.Validate(res)
+	require.NoError(t, err)
+	assert.NotNil(t, res)
+}
+
+func TestValidator_Validate_SanitizeReject(t *testing.T) {
+	v := Validator{Mode: ValidationSanitize, MaxFields: 1}
+
+	res, err := v.Validate(map[string]any{"a": 1, "b": 2})
+	require.Error(t, err)
+	assert.Nil(t, res)
+}
+
+func TestValidator_Validate_Report(t *testing.T) {
+	var reported *ValidationError
+	v := Validator{
+		Mode:      ValidationReport,
+		OnInvalid: func(_ map[string]any, err *ValidationError) { reported = err },
+	}
+
+	event := map[string]any{"_foo": 1}
+
+	res, err := v.Validate(event)
+	require.Error(t, err)
+	assert.Equal(t, event, res)
+	assert.Equal(t, err, reported)
+	assert.True(t, strings.HasPrefix(err.Error(), "invalid event: "))
+}
@@ -0,0 +1,477 @@
+package axiom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+)
+
+//go:generate go run golang.org/x/tools/cmd/stringer -type=Backpressure -linecomment -output=ingester_string.go
+
+// ErrIngesterClosed is returned when events are passed to an `Ingester` that
+// has been closed.
+var ErrIngesterClosed = errors.New("ingester closed")
+
+// Backpressure controls how an `Ingester` behaves when its buffer is full.
+type Backpressure uint8
+
+// All available backpressure strategies.
+const (
+	// BackpressureBlock blocks until there is space in the buffer or the
+	// context is done.
+	BackpressureBlock Backpressure = iota // block
+	// BackpressureDropOldest drops the oldest buffered event to make space for
+	// the new one.
+	BackpressureDropOldest // drop-oldest
+	// BackpressureDropNewest drops the new event.
+	BackpressureDropNewest // drop-newest
+)
+
+// EventIngester ingests events into a dataset. It is implemented by
+// `DatasetsAPI` and `*Spool`.
+type EventIngester interface {
+	IngestEvents(ctx context.Context, id string, events []Event, options ...ingest.Option) (*ingest.Status, error)
+}
+
+// IngesterConfig configures an `Ingester`. Zero values select the defaults
+// documented on each field.
+type IngesterConfig struct {
+	// BatchSize is the maximum amount of events sent in a single batch.
+	// Defaults to 1024.
+	BatchSize int
+	// BatchBytes is the maximum size of a batch in bytes, measured as the size
+	// of the JSON encoded events. Zero means the size is not limited.
+	BatchBytes int
+	// FlushInterval is the maximum time an event is buffered before it is
+	// sent, even if its batch is not full. Defaults to one second.
+	FlushInterval time.Duration
+	// Concurrency is the amount of batches sent concurrently. Defaults to one.
+	Concurrency int
+	// BufferSize is the maximum amount of events buffered, including the ones
+	// in batches waiting to be sent. Defaults to ten times the batch size.
+	BufferSize int
+	// Backpressure controls what happens when the buffer is full. Defaults to
+	// `BackpressureBlock`.
+	Backpressure Backpressure
+	// IngestOptions are passed to every call to `EventIngester.IngestEvents`.
+	IngestOptions []ingest.Option
+	// OnError, if set, is called with the error of every batch that failed to
+	// be sent, before the batch is considered done. Batches persisted by a
+	// `*Spool` are not considered failed. It must not block.
+	OnError func(err error)
+	// OnFailedEvents, if set, is called with the events of every batch that
+	// failed to be ingested, either because the server rejected some of them or
+	// because the batch failed to be sent. It can be used to route them to a
+	// dead-letter destination. It is called before the batch is considered done
+	// and must not block. See `FailedEvents` on how events are matched.
+	OnFailedEvents func(events []FailedEvent)
+}
+
+// IngesterStats are statistics about the events handled by an `Ingester`.
+type IngesterStats struct {
+	// Enqueued is the amount of events accepted by the ingester.
+	Enqueued uint64
+	// Dropped is the amount of events dropped because the buffer was full.
+	Dropped uint64
+	// Ingested is the amount of events ingested, as reported by the server.
+	Ingested uint64
+	// Failed is the amount of events that failed to be ingested, either as
+	// reported by the server or because their batch failed to be sent.
+	Failed uint64
+	// Spooled is the amount of events persisted by a `*Spool` to be replayed
+	// later on, instead of being ingested right away.
+	Spooled uint64
+	// Batches is the amount of batches sent.
+	Batches uint64
+	// Errors is the amount of batches that failed to be sent.
+	Errors uint64
+}
+
+// Ingester asynchronously ingests events into a dataset. Events are buffered
+// and sent in batches, either when a batch is full or when the flush interval
+// has passed. It is safe for concurrent use. It must be closed by calling
+// `Ingester.Close` when done, to make sure all buffered events are sent.
+//
+// When ingesting through a `*Spool`, the ingester replays the batches it
+// persisted whenever there are no events to send at the end of a flush
+// interval. Thus, they don't have to wait for the next events to be logged.
+type Ingester struct {
+	datasets EventIngester
+	id       string
+	config   IngesterConfig
+
+	// ctx is passed to the requests sent by the workers. It is canceled when
+	// closing the ingester takes too long.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// buf holds events not yet assigned to a batch, ready holds batches
+	// waiting to be picked up by a worker. Together, they make up the buffer.
+	buf        []Event
+	bufBytes   int
+	ready      []*ingesterBatch
+	buffered   int
+	inflight   map[*ingesterBatch]struct{}
+	spaceCh    chan struct{}
+	readyCond  *sync.Cond
+	stats      IngesterStats
+	closed     bool
+	stopping   bool
+	stopTicker chan struct{}
+	wg         sync.WaitGroup
+	mtx        sync.Mutex
+}
+
+type ingesterBatch struct {
+	events []Event
+	err    error
+	done   chan struct{}
+}
+
+// replayer is implemented by `*Spool`.
+type replayer interface {
+	Replay(ctx context.Context) error
+}
+
+// NewIngester returns a new `Ingester` which ingests events into the dataset
+// identified by its id, using the given datasets service, usually
+// `Client.Datasets`, or a `*Spool`.
+func NewIngester(datasets EventIngester, id string, config IngesterConfig) (*Ingester, error) {
+	if config.BatchSize < 0 || config.BatchBytes < 0 || config.FlushInterval < 0 ||
+		config.Concurrency < 0 || config.BufferSize < 0 {
+		return nil, errors.New("invalid ingester config: values must not be negative")
+	}
+
+	if config.BatchSize == 0 {
+		config.BatchSize = 1024
+	}
+	if config.FlushInterval == 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.Concurrency == 0 {
+		config.Concurrency = 1
+	}
+	if config.BufferSize == 0 {
+		config.BufferSize = 10 * config.BatchSize
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ing := &Ingester{
+		datasets: datasets,
+		id:       id,
+		config:   config,
+
+		ctx:    ctx,
+		cancel: cancel,
+
+		inflight:   make(map[*ingesterBatch]struct{}),
+		spaceCh:    make(chan struct{}),
+		stopTicker: make(chan struct{}),
+	}
+	ing.readyCond = sync.NewCond(&ing.mtx)
+
+	ing.wg.Add(config.Concurrency + 1)
+	for i := 0; i < config.Concurrency; i++ {
+		go ing.work()
+	}
+	go ing.tick()
+
+	return ing, nil
+}
+
+// Ingest passes the given events to the ingester. Depending on the configured
+// `Backpressure`, it blocks until there is space in the buffer or drops events
+// if the buffer is full. Only when blocking, the context is used to abort.
+// Errors which occur when sending the events are not returned, but passed to
+// `IngesterConfig.OnError` and recorded in the ingesters stats.
+func (i *Ingester) Ingest(ctx context.Context, events ...Event) error {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+
+	for _, event := range events {
+		if i.closed {
+			return ErrIngesterClosed
+		}
+
+		for i.buffered >= i.config.BufferSize {
+			switch i.config.Backpressure {
+			case BackpressureDropNewest:
+				i.stats.Dropped++
+				event = nil
+			case BackpressureDropOldest:
+				i.dropOldest()
+			default:
+				spaceCh := i.spaceCh
+				i.mtx.Unlock()
+				select {
+				case <-ctx.Done():
+					i.mtx.Lock()
+					return ctx.Err()
+				case <-spaceCh:
+				}
+				i.mtx.Lock()
+				if i.closed {
+					return ErrIngesterClosed
+				}
+			}
+			if event == nil {
+				break
+			}
+		}
+		if event == nil {
+			continue
+		}
+
+		size := i.eventSize(event)
+		if i.config.BatchBytes > 0 && i.bufBytes+size > i.config.BatchBytes && len(i.buf) > 0 {
+			i.cut()
+		}
+
+		i.buf = append(i.buf, event)
+		i.bufBytes += size
+		i.buffered++
+		i.stats.Enqueued++
+
+		if len(i.buf) >= i.config.BatchSize {
+			i.cut()
+		}
+	}
+
+	return nil
+}
+
+// Flush sends all buffered events and waits until they, as well as all events
+// sent before, are ingested or the context is done. It returns the first error
+// of the batches it waited for.
+func (i *Ingester) Flush(ctx context.Context) error {
+	i.mtx.Lock()
+	i.cut()
+	batches := make([]*ingesterBatch, 0, len(i.inflight))
+	for b := range i.inflight {
+		batches = append(batches, b)
+	}
+	i.mtx.Unlock()
+
+	var err error
+	for _, b := range batches {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-b.done:
+			if err == nil {
+				err = b.err
+			}
+		}
+	}
+	return err
+}
+
+// Close flushes all buffered events and stops the ingester. Events passed to
+// the ingester afterwards are rejected with `ErrIngesterClosed`. It returns
+// the first error of the batches sent while flushing. If the context is done
+// before all events are sent, the requests still in flight are canceled and
+// the events not sent, yet, fail with the error of the context.
+func (i *Ingester) Close(ctx context.Context) error {
+	i.mtx.Lock()
+	if i.closed {
+		i.mtx.Unlock()
+		return nil
+	}
+	i.closed = true
+	close(i.stopTicker)
+	i.notifySpace()
+	i.mtx.Unlock()
+
+	err := i.Flush(ctx)
+	if ctx.Err() != nil {
+		i.cancel()
+	}
+
+	i.mtx.Lock()
+	i.stopping = true
+	i.readyCond.Broadcast()
+	i.mtx.Unlock()
+
+	i.wg.Wait()
+	i.cancel()
+
+	return err
+}
+
+// Stats returns statistics about the events handled by the ingester.
+func (i *Ingester) Stats() IngesterStats {
+	i.mtx.Lock()
+	defer i.mtx.Unlock()
+
+	return i.stats
+}
+
+// cut moves the events not yet assigned to a batch into batches ready to be
+// sent. Must be called with the lock held.
+func (i *Ingester) cut() {
+	for len(i.buf) > 0 {
+		n := len(i.buf)
+		if n > i.config.BatchSize {
+			n = i.config.BatchSize
+		}
+
+		b := &ingesterBatch{
+			events: i.buf[:n:n],
+			done:   make(chan struct{}),
+		}
+		i.buf = i.buf[n:]
+		i.ready = append(i.ready, b)
+		i.inflight[b] = struct{}{}
+	}
+	i.buf, i.bufBytes = nil, 0
+	i.readyCond.Broadcast()
+}
+
+// dropOldest drops the oldest buffered event. Must be called with the lock
+// held.
+func (i *Ingester) dropOldest() {
+	if len(i.ready) > 0 {
+		b := i.ready[0]
+		if b.events = b.events[1:]; len(b.events) == 0 {
+			i.ready = i.ready[1:]
+			delete(i.inflight, b)
+			close(b.done)
+		}
+	} else if len(i.buf) > 0 {
+		i.bufBytes -= i.eventSize(i.buf[0])
+		i.buf = i.buf[1:]
+	} else {
+		return
+	}
+	i.buffered--
+	i.stats.Dropped++
+}
+
+// eventSize returns the size of the given event counted towards the maximum
+// size of a batch. It is zero, if the size of batches is not limited.
+func (i *Ingester) eventSize(event Event) int {
+	if i.config.BatchBytes == 0 {
+		return 0
+	}
+	b, err := json.Marshal(event)
+	if err != nil {
+		return 0
+	}
+	return len(b) + 1
+}
+
+// notifySpace wakes up all calls to `Ingester.Ingest` waiting for space in
+// the buffer. Must be called with the lock held.
+func (i *Ingester) notifySpace() {
+	close(i.spaceCh)
+	i.spaceCh = make(chan struct{})
+}
+
+// tick periodically moves buffered events into batches ready to be sent. If
+// there are none, it replays the batches persisted by a spool, if any.
+func (i *Ingester) tick() {
+	defer i.wg.Done()
+
+	ticker := time.NewTicker(i.config.FlushInterval)
+	defer ticker.Stop()
+
+	r, _ := i.datasets.(replayer)
+	for {
+		select {
+		case <-i.stopTicker:
+			return
+		case <-ticker.C:
+			i.mtx.Lock()
+			i.cut()
+			idle := len(i.inflight) == 0
+			i.mtx.Unlock()
+
+			// A failed replay is retried with the next tick. The batches stay
+			// persisted, so there is nothing to report.
+			if r != nil && idle {
+				_ = r.Replay(i.ctx)
+			}
+		}
+	}
+}
+
+// work sends the batches that are ready to be sent, until the ingester is
+// stopped.
+func (i *Ingester) work() {
+	defer i.wg.Done()
+
+	for {
+		i.mtx.Lock()
+		for len(i.ready) == 0 && !i.stopping {
+			i.readyCond.Wait()
+		}
+		if len(i.ready) == 0 {
+			i.mtx.Unlock()
+			return
+		}
+		b := i.ready[0]
+		i.ready = i.ready[1:]
+		i.buffered -= len(b.events)
+		i.notifySpace()
+		i.mtx.Unlock()
+
+		status, err := i.datasets.IngestEvents(i.ctx, i.id, b.events, i.config.IngestOptions...)
+
+		// A batch persisted by a spool is not lost, but replayed later on.
+		// Only the events rejected before it was persisted failed.
+		spooled := errors.Is(err, ErrSpooled)
+		if spooled {
+			err = nil
+		}
+
+		if err != nil && i.config.OnError != nil {
+			i.config.OnError(err)
+		}
+		if i.config.OnFailedEvents != nil {
+			if failed := i.failedEvents(b.events, status, err); len(failed) > 0 {
+				i.config.OnFailedEvents(failed)
+			}
+		}
+
+		i.mtx.Lock()
+		i.stats.Batches++
+		if err != nil {
+			i.stats.Errors++
+			i.stats.Failed += uint64(len(b.events))
+		} else if spooled {
+			var failed uint64
+			if status != nil {
+				failed = status.Failed
+			}
+			i.stats.Spooled += uint64(len(b.events)) - failed
+			i.stats.Failed += failed
+		} else {
+			i.stats.Ingested += status.Ingested
+			i.stats.Failed += status.Failed
+		}
+		b.err = err
+		delete(i.inflight, b)
+		close(b.done)
+		i.mtx.Unlock()
+	}
+}
+
+// failedEvents returns the events of a batch which failed to be ingested.
+func (i *Ingester) failedEvents(events []Event, status *ingest.Status, err error) []FailedEvent {
+	if err == nil {
+		return FailedEvents(events, status)
+	}
+
+	res := make([]FailedEvent, len(events))
+	for k, event := range events {
+		res[k] = FailedEvent{
+			Event: event,
+			Error: err.Error(),
+		}
+	}
+	return res
+}
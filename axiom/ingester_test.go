@@ -0,0 +1,372 @@
+package axiom
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+)
+
+// fakeDatasets implements the `IngestEvents` method of `DatasetsAPI` by
+// calling the given function and recording the batches it is called with.
+type fakeDatasets struct {
+	DatasetsAPI
+
+	ingestEvents func(ctx context.Context, events []Event) (*ingest.Status, error)
+
+	batches [][]Event
+	mtx     sync.Mutex
+}
+
+func (f *fakeDatasets) IngestEvents(ctx context.Context, _ string, events []Event, _ ...ingest.Option) (*ingest.Status, error) {
+	f.mtx.Lock()
+	f.batches = append(f.batches, events)
+	f.mtx.Unlock()
+
+	if f.ingestEvents != nil {
+		return f.ingestEvents(ctx, events)
+	}
+	return &ingest.Status{Ingested: uint64(len(events))}, nil
+}
+
+func (f *fakeDatasets) sent() [][]Event {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	return f.batches
+}
+
+func TestIngester_BatchSize(t *testing.T) {
+	datasets := new(fakeDatasets)
+
+	ing, err := NewIngester(datasets, "test", IngesterConfig{
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+	require.NoError(t, err)
+
+	err = ing.Ingest(context.Background(), Event{"n": 1}, Event{"n": 2}, Event{"n": 3}, Event{"n": 4}, Event{"n": 5})
+	require.NoError(t, err)
+
+	require.NoError(t, ing.Close(context.Background()))
+
+	assert.Equal(t, [][]Event{
+		{{"n": 1}, {"n": 2}},
+		{{"n": 3}, {"n": 4}},
+		{{"n": 5}},
+	}, datasets.sent())
+	assert.Equal(t, IngesterStats{
+		Enqueued: 5,
+		Ingested: 5,
+		Batches:  3,
+	}, ing.Stats())
+
+	assert.ErrorIs(t, ing.Ingest(context.Background(), Event{}), ErrIngesterClosed)
+}
+
+func TestIngester_BatchBytes(t *testing.T) {
+	datasets := new(fakeDatasets)
+
+	// Each event is 10 bytes when JSON encoded, including the newline.
+	ing, err := NewIngester(datasets, "test", IngesterConfig{
+		BatchBytes:    25,
+		FlushInterval: time.Hour,
+	})
+	require.NoError(t, err)
+
+	err = ing.Ingest(context.Background(), Event{"n": 100}, Event{"n": 200}, Event{"n": 300})
+	require.NoError(t, err)
+
+	require.NoError(t, ing.Close(context.Background()))
+
+	if batches := datasets.sent(); assert.Len(t, batches, 2) {
+		assert.Len(t, batches[0], 2)
+		assert.Len(t, batches[1], 1)
+	}
+}
+
+func TestIngester_BatchBytes_DropOldest(t *testing.T) {
+	datasets := new(fakeDatasets)
+
+	// Each event is 10 bytes when JSON encoded, including the newline. The
+	// dropped event must not count towards the size of the batch.
+	ing, err := NewIngester(datasets, "test", IngesterConfig{
+		BatchBytes:    25,
+		BufferSize:    2,
+		FlushInterval: time.Hour,
+		Backpressure:  BackpressureDropOldest,
+	})
+	require.NoError(t, err)
+
+	err = ing.Ingest(context.Background(), Event{"n": 100}, Event{"n": 200}, Event{"n": 300})
+	require.NoError(t, err)
+
+	require.NoError(t, ing.Close(context.Background()))
+
+	assert.Equal(t, [][]Event{{{"n": 200}, {"n": 300}}}, datasets.sent())
+	assert.EqualValues(t, 1, ing.Stats().Dropped)
+}
+
+func TestIngester_FlushInterval(t *testing.T) {
+	datasets := new(fakeDatasets)
+
+	ing, err := NewIngester(datasets, "test", IngesterConfig{
+		FlushInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer ing.Close(context.Background())
+
+	require.NoError(t, ing.Ingest(context.Background(), Event{"n": 1}))
+
+	assert.Eventually(t, func() bool {
+		return ing.Stats().Ingested == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestIngester_Flush(t *testing.T) {
+	errIngest := errors.New("ingest failed")
+
+	var onErr []error
+	datasets := &fakeDatasets{
+		ingestEvents: func(_ context.Context, events []Event) (*ingest.Status, error) {
+			if events[0]["fail"] == true {
+				return nil, errIngest
+			}
+			return &ingest.Status{Ingested: uint64(len(events)) - 1, Failed: 1}, nil
+		},
+	}
+
+	ing, err := NewIngester(datasets, "test", IngesterConfig{
+		FlushInterval: time.Hour,
+		OnError:       func(err error) { onErr = append(onErr, err) },
+	})
+	require.NoError(t, err)
+	defer ing.Close(context.Background())
+
+	require.NoError(t, ing.Ingest(context.Background(), Event{}, Event{}))
+	require.NoError(t, ing.Flush(context.Background()))
+
+	assert.Equal(t, IngesterStats{
+		Enqueued: 2,
+		Ingested: 1,
+		Failed:   1,
+		Batches:  1,
+	}, ing.Stats())
+
+	require.NoError(t, ing.Ingest(context.Background(), Event{"fail": true}))
+	require.ErrorIs(t, ing.Flush(context.Background()), errIngest)
+
+	assert.Equal(t, []error{errIngest}, onErr)
+	assert.EqualValues(t, 1, ing.Stats().Errors)
+	assert.EqualValues(t, 2, ing.Stats().Failed)
+}
+
+func TestIngester_OnFailedEvents(t *testing.T) {
+	errIngest := errors.New("ingest failed")
+
+	ts := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	datasets := &fakeDatasets{
+		ingestEvents: func(_ context.Context, events []Event) (*ingest.Status, error) {
+			if events[0]["fail"] == true {
+				return nil, errIngest
+			}
+			return &ingest.Status{
+				Ingested: 1,
+				Failed:   1,
+				Failures: []*ingest.Failure{{Timestamp: ts, Error: "invalid event", Index: 1}},
+			}, nil
+		},
+	}
+
+	var failed []FailedEvent
+	ing, err := NewIngester(datasets, "test", IngesterConfig{
+		FlushInterval:  time.Hour,
+		OnFailedEvents: func(events []FailedEvent) { failed = append(failed, events...) },
+	})
+	require.NoError(t, err)
+	defer ing.Close(context.Background())
+
+	require.NoError(t, ing.Ingest(context.Background(),
+		Event{ingest.TimestampField: ts.Add(time.Second).Format(time.RFC3339)},
+		Event{ingest.TimestampField: ts.Format(time.RFC3339)},
+	))
+	require.NoError(t, ing.Flush(context.Background()))
+
+	require.NoError(t, ing.Ingest(context.Background(), Event{"fail": true}))
+	require.ErrorIs(t, ing.Flush(context.Background()), errIngest)
+
+	assert.Equal(t, []FailedEvent{
+		{Event: Event{ingest.TimestampField: ts.Format(time.RFC3339)}, Timestamp: ts, Error: "invalid event"},
+		{Event: Event{"fail": true}, Error: errIngest.Error()},
+	}, failed)
+}
+
+// spoolingDatasets implements `EventIngester` like a `*Spool` which persists
+// every batch, and records the calls to its `Replay` method.
+type spoolingDatasets struct {
+	fakeDatasets
+
+	replays int32
+}
+
+func (s *spoolingDatasets) Replay(context.Context) error {
+	atomic.AddInt32(&s.replays, 1)
+	return nil
+}
+
+func TestIngester_Spooled(t *testing.T) {
+	errIngest := errors.New("ingest failed")
+
+	datasets := &spoolingDatasets{
+		fakeDatasets: fakeDatasets{
+			ingestEvents: func(context.Context, []Event) (*ingest.Status, error) {
+				return nil, spooledError{err: errIngest}
+			},
+		},
+	}
+
+	var (
+		errs   []error
+		failed []FailedEvent
+	)
+	ing, err := NewIngester(datasets, "test", IngesterConfig{
+		FlushInterval:  10 * time.Millisecond,
+		OnError:        func(err error) { errs = append(errs, err) },
+		OnFailedEvents: func(events []FailedEvent) { failed = append(failed, events...) },
+	})
+	require.NoError(t, err)
+	defer ing.Close(context.Background())
+
+	require.NoError(t, ing.Ingest(context.Background(), Event{"n": 1}, Event{"n": 2}))
+	require.NoError(t, ing.Flush(context.Background()))
+
+	assert.Empty(t, errs)
+	assert.Empty(t, failed)
+	assert.Equal(t, IngesterStats{
+		Enqueued: 2,
+		Spooled:  2,
+		Batches:  1,
+	}, ing.Stats())
+
+	// Persisted batches are replayed while the ingester is idle.
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&datasets.replays) > 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestIngester_Backpressure(t *testing.T) {
+	tests := []struct {
+		backpressure Backpressure
+		exp          []Event
+		expDropped   uint64
+	}{
+		{BackpressureDropNewest, []Event{{"n": 2}, {"n": 3}}, 2},
+		{BackpressureDropOldest, []Event{{"n": 4}, {"n": 5}}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.backpressure.String(), func(t *testing.T) {
+			var (
+				unblock  = make(chan struct{})
+				datasets = &fakeDatasets{
+					ingestEvents: func(_ context.Context, events []Event) (*ingest.Status, error) {
+						<-unblock
+						return &ingest.Status{Ingested: uint64(len(events))}, nil
+					},
+				}
+			)
+
+			ing, err := NewIngester(datasets, "test", IngesterConfig{
+				BatchSize:     1,
+				BufferSize:    2,
+				FlushInterval: time.Hour,
+				Backpressure:  tt.backpressure,
+			})
+			require.NoError(t, err)
+
+			// Wait for the worker to pick up the first event, so the buffer is
+			// empty before filling it up.
+			require.NoError(t, ing.Ingest(context.Background(), Event{"n": 1}))
+			require.Eventually(t, func() bool { return len(datasets.sent()) == 1 }, time.Second, time.Millisecond)
+
+			require.NoError(t, ing.Ingest(context.Background(), Event{"n": 2}, Event{"n": 3}, Event{"n": 4}, Event{"n": 5}))
+
+			close(unblock)
+			require.NoError(t, ing.Close(context.Background()))
+
+			batches := datasets.sent()
+			require.Len(t, batches, 3)
+			assert.Equal(t, tt.exp, []Event{batches[1][0], batches[2][0]})
+			assert.Equal(t, tt.expDropped, ing.Stats().Dropped)
+		})
+	}
+}
+
+func TestIngester_Backpressure_Block(t *testing.T) {
+	var (
+		unblock  = make(chan struct{})
+		datasets = &fakeDatasets{
+			ingestEvents: func(_ context.Context, events []Event) (*ingest.Status, error) {
+				<-unblock
+				return &ingest.Status{Ingested: uint64(len(events))}, nil
+			},
+		}
+	)
+
+	ing, err := NewIngester(datasets, "test", IngesterConfig{
+		BatchSize:     1,
+		BufferSize:    1,
+		FlushInterval: time.Hour,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, ing.Ingest(context.Background(), Event{"n": 1}))
+	require.Eventually(t, func() bool { return len(datasets.sent()) == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, ing.Ingest(context.Background(), Event{"n": 2}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = ing.Ingest(ctx, Event{"n": 3})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(unblock)
+	require.NoError(t, ing.Close(context.Background()))
+
+	assert.EqualValues(t, 2, ing.Stats().Ingested)
+}
+
+func TestIngester_Close_Timeout(t *testing.T) {
+	datasets := &fakeDatasets{
+		ingestEvents: func(ctx context.Context, _ []Event) (*ingest.Status, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+
+	ing, err := NewIngester(datasets, "test", IngesterConfig{
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, ing.Ingest(context.Background(), Event{"n": 1}, Event{"n": 2}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = ing.Close(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	stats := ing.Stats()
+	assert.EqualValues(t, 2, stats.Failed)
+	assert.EqualValues(t, 2, stats.Errors)
+}
@@ -0,0 +1,63 @@
+package axiom
+
+import (
+	"context"
+	"net/http"
+)
+
+// Operation describes the API operation a request is issued for.
+type Operation struct {
+	// Name of the service method that issued the request, e.g.
+	// "Datasets.Ingest". Empty, if the request was not issued by a service
+	// method but by `Client.Call` or `Client.NewRequest` directly.
+	Name string
+	// DatasetID is the ID of the dataset the operation is performed on. Empty,
+	// if the operation doesn't target a specific dataset.
+	DatasetID string
+}
+
+// A Sender sends an API request and returns the API response, just like
+// `Client.Do` does.
+type Sender func(req *http.Request) (*Response, error)
+
+// An Interceptor intercepts every API request sent by the client. It is handed
+// the request, the operation it is issued for and the next `Sender` in the
+// chain, which it must call to actually send the request. Interceptors can
+// modify the request before passing it on, inspect or modify the response and
+// the error returned by the next `Sender` or skip sending the request entirely.
+//
+// Interceptors only see the outcome of a request after it has been decoded:
+// The error returned by the next `Sender` is already decoded, e.g. into an
+// `*Error` or a `*LimitError`, and the body of the returned `*Response` is
+// already consumed and closed, with the result written to the value passed to
+// `Client.Do`. Thus, an interceptor can't read the response body and
+// modifying or replacing the response doesn't change the result decoded from
+// it. Retries happen within the next `Sender`, so an interceptor sees a
+// request only once, no matter how often it is sent.
+type Interceptor func(req *http.Request, op Operation, next Sender) (*Response, error)
+
+type operationContextKey struct{}
+
+// withOperation returns a copy of the context which carries the given
+// operation.
+func withOperation(ctx context.Context, op Operation) context.Context {
+	return context.WithValue(ctx, operationContextKey{}, op)
+}
+
+// operationFromContext returns the operation carried by the context, if any.
+func operationFromContext(ctx context.Context) Operation {
+	op, _ := ctx.Value(operationContextKey{}).(Operation)
+	return op
+}
+
+// intercept returns a `Sender` which passes requests through the given
+// interceptors, in order, before handing them to the given `Sender`.
+func intercept(send Sender, interceptors []Interceptor) Sender {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], send
+		send = func(req *http.Request) (*Response, error) {
+			return interceptor(req, operationFromContext(req.Context()), next)
+		}
+	}
+	return send
+}
@@ -0,0 +1,111 @@
+package axiom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptor(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "first,second", r.Header.Get("X-Intercepted-By"))
+
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err := fmt.Fprint(w, `{"id":"test","name":"test"}`)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test", hf)
+
+	var calls []string
+	interceptor := func(name string) Interceptor {
+		return func(req *http.Request, op Operation, next Sender) (*Response, error) {
+			assert.Equal(t, Operation{Name: "Datasets.Get", DatasetID: "test"}, op)
+
+			calls = append(calls, name)
+			if by := req.Header.Get("X-Intercepted-By"); by != "" {
+				name = by + "," + name
+			}
+			req.Header.Set("X-Intercepted-By", name)
+
+			resp, err := next(req)
+			calls = append(calls, name)
+
+			return resp, err
+		}
+	}
+
+	err := client.Options(SetInterceptors(interceptor("first"), interceptor("second")))
+	require.NoError(t, err)
+
+	_, err = client.Datasets.Get(context.Background(), "test")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"first", "second", "first,second", "first"}, calls)
+}
+
+func TestInterceptor_Operation(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err := fmt.Fprint(w, `{"id":"axiom"}`)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/orgs/axiom", hf)
+
+	var op Operation
+	err := client.Options(SetInterceptors(func(req *http.Request, o Operation, next Sender) (*Response, error) {
+		op = o
+		return next(req)
+	}))
+	require.NoError(t, err)
+
+	_, err = client.Organizations.Get(context.Background(), "axiom")
+	require.NoError(t, err)
+
+	// The organization ID is not a dataset ID.
+	assert.Equal(t, Operation{Name: "Organizations.Get"}, op)
+}
+
+func TestInterceptor_Error(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}
+
+	client := setup(t, "/api/v1/datasets/test", hf)
+
+	var interceptedErr error
+	err := client.Options(SetInterceptors(func(req *http.Request, _ Operation, next Sender) (*Response, error) {
+		resp, err := next(req)
+		interceptedErr = err
+		return resp, err
+	}))
+	require.NoError(t, err)
+
+	_, err = client.Datasets.Get(context.Background(), "test")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	assert.ErrorIs(t, interceptedErr, ErrNotFound)
+}
+
+func TestInterceptor_FaultInjection(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		assert.Fail(t, "request should not be sent")
+	}
+
+	client := setup(t, "/api/v1/datasets", hf)
+
+	errInjected := errors.New("injected fault")
+	err := client.Options(SetInterceptors(func(*http.Request, Operation, Sender) (*Response, error) {
+		return nil, errInjected
+	}))
+	require.NoError(t, err)
+
+	_, err = client.Datasets.List(context.Background())
+	require.ErrorIs(t, err, errInjected)
+}
@@ -3,7 +3,9 @@ package axiom
 import (
 	"fmt"
 	"net/http"
+	"regexp"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -28,6 +30,11 @@ const (
 	httpStatusLimitExceeded = 430
 )
 
+var (
+	ingestPath = regexp.MustCompile(`/api/v1/datasets/[^/]+/ingest$`)
+	queryPath  = regexp.MustCompile(`/api/v1/datasets/([^/]+/query|_apl)$`)
+)
+
 type limitType uint8
 
 const (
@@ -132,3 +139,111 @@ func hasHeaders(r *http.Response, headers ...string) bool {
 	}
 	return true
 }
+
+// limitKey identifies a tracked limit.
+type limitKey struct {
+	organizationID string
+	limitType      limitType
+	scope          LimitScope
+}
+
+// limitTracker keeps track of the latest limits reported by the server in
+// order to reject requests that would exceed them before they are sent. It is
+// safe for concurrent use.
+type limitTracker struct {
+	limits map[limitKey]Limit
+	mtx    sync.RWMutex
+}
+
+// newLimitTracker returns a new, empty `limitTracker`.
+func newLimitTracker() *limitTracker {
+	return &limitTracker{
+		limits: make(map[limitKey]Limit),
+	}
+}
+
+// update records the given limit as reported on a response to the given
+// request.
+func (t *limitTracker) update(req *http.Request, limit Limit) {
+	if limit.limitType == 0 {
+		return
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	t.limits[newLimitKey(req, limit.limitType, limit.Scope)] = limit
+}
+
+// check returns a `*LimitError` if the given request is known to exceed a
+// limit.
+func (t *limitTracker) check(req *http.Request) error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	now := time.Now()
+	for key, limit := range t.limits {
+		// Forget about limits which time window has passed.
+		if !now.Before(limit.Reset) {
+			delete(t.limits, key)
+			continue
+		}
+
+		if limit.Remaining == 0 && key.matches(req) {
+			return &LimitError{
+				Limit: limit,
+			}
+		}
+	}
+
+	return nil
+}
+
+// forEach calls the given function for every known limit which time window
+// hasn't passed, yet.
+func (t *limitTracker) forEach(fn func(key limitKey, limit Limit)) {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	now := time.Now()
+	for key, limit := range t.limits {
+		if now.Before(limit.Reset) {
+			fn(key, limit)
+		}
+	}
+}
+
+// newLimitKey returns the key for the given limit type and scope as reported on
+// a response to the given request.
+func newLimitKey(req *http.Request, typ limitType, scope LimitScope) limitKey {
+	key := limitKey{
+		limitType: typ,
+		scope:     scope,
+	}
+
+	// Limits are enforced per organization, unless they are scoped to a user
+	// or anonymous requests.
+	if scope != LimitScopeUser && scope != LimitScopeAnonymous {
+		key.organizationID = req.Header.Get(headerOrganizationID)
+	}
+
+	return key
+}
+
+// matches returns true if the limit identified by the key applies to the given
+// request.
+func (k limitKey) matches(req *http.Request) bool {
+	if k.organizationID != "" && k.organizationID != req.Header.Get(headerOrganizationID) {
+		return false
+	}
+
+	switch k.limitType {
+	case limitIngest:
+		return ingestPath.MatchString(req.URL.Path)
+	case limitQuery:
+		return queryPath.MatchString(req.URL.Path)
+	case limitRate:
+		return true
+	}
+	return false
+}
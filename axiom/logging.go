@@ -0,0 +1,226 @@
+package axiom
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	redacted = "REDACTED"
+
+	defaultLogMaxBodySize = 1024
+)
+
+// redactedQueryParameters are the query parameters which values are redacted
+// before a request is logged.
+var redactedQueryParameters = []string{
+	"tk", // Token of a shared access signature.
+}
+
+// A Logger logs the API requests sent by the client. See `SetLogger`.
+type Logger func(entry LogEntry)
+
+// StdLogger returns a `Logger` that prints the string representation of each
+// `LogEntry` to the given `*log.Logger`.
+func StdLogger(l *log.Logger) Logger {
+	return func(entry LogEntry) { l.Print(entry) }
+}
+
+// LogEntry describes a single API request sent by the client and its response.
+// A request that is retried results in one entry per attempt. Secrets, like the
+// access token or the token of a shared access signature, are redacted.
+type LogEntry struct {
+	// Operation the request was issued for.
+	Operation Operation
+	// Method of the request.
+	Method string
+	// URL of the request.
+	URL *url.URL
+	// Header of the request.
+	Header http.Header
+	// RequestBody is the body of the request, truncated to the configured
+	// maximum size. Encoded, e.g. compressed, bodies are omitted.
+	RequestBody string
+	// RequestBodySize is the full size of the request body.
+	RequestBodySize int64
+	// StatusCode of the response. Zero, if no response was received.
+	StatusCode int
+	// Limit as reported on the response.
+	Limit Limit
+	// ResponseBody is the body of the response, truncated to the configured
+	// maximum size.
+	ResponseBody string
+	// ResponseBodySize is the size of the response body that was read.
+	ResponseBodySize int64
+	// Duration from sending the request until the response body was closed.
+	Duration time.Duration
+	// Error that occurred while sending the request, if any.
+	Error error
+}
+
+// String returns a string representation of the log entry.
+//
+// It implements `fmt.Stringer`.
+func (e LogEntry) String() string {
+	var sb strings.Builder
+	if e.Operation.Name != "" {
+		fmt.Fprintf(&sb, "%s: ", e.Operation.Name)
+	}
+	fmt.Fprintf(&sb, "%s %s", e.Method, e.URL.RequestURI())
+	if e.Error != nil {
+		fmt.Fprintf(&sb, " failed after %s: %s", e.Duration, e.Error)
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, " %d (%s)", e.StatusCode, e.Duration)
+	if e.Limit.limitType != 0 {
+		fmt.Fprintf(&sb, " [%s]", e.Limit)
+	}
+	if e.RequestBodySize > 0 {
+		fmt.Fprintf(&sb, " request body (%d bytes): %q", e.RequestBodySize, e.RequestBody)
+	}
+	if e.ResponseBodySize > 0 {
+		fmt.Fprintf(&sb, " response body (%d bytes): %q", e.ResponseBodySize, e.ResponseBody)
+	}
+	return sb.String()
+}
+
+// shouldLog returns true if the next request should be logged.
+func (c *Client) shouldLog() bool {
+	if c.logger == nil {
+		return false
+	}
+	return c.logSampleRate >= 1 || rand.Float64() < c.logSampleRate //nolint:gosec // Sampling doesn't need to be cryptographically secure.
+}
+
+// sendAndLog sends the given request using the http client and logs it.
+func (c *Client) sendAndLog(req *http.Request) (*http.Response, error) {
+	entry := LogEntry{
+		Operation: operationFromContext(req.Context()),
+		Method:    req.Method,
+		URL:       redactURL(req.URL),
+		Header:    redactHeader(req.Header),
+	}
+
+	var reqBody *bodyRecorder
+	if req.Body != nil && req.Body != http.NoBody {
+		reqBody = newBodyRecorder(req.Body, c.logMaxBodySize)
+		reqBody.omit = req.Header.Get("Content-Encoding") != ""
+		req.Body = reqBody
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		entry.Duration = time.Since(start)
+		entry.Error = err
+		if reqBody != nil {
+			entry.RequestBody, entry.RequestBodySize = reqBody.recorded()
+		}
+		c.logger(entry)
+		return nil, err
+	}
+
+	entry.StatusCode = resp.StatusCode
+	entry.Limit = parseLimit(resp)
+
+	// The entry is logged as soon as the response body is closed, which
+	// happens for every response.
+	respBody := newBodyRecorder(resp.Body, c.logMaxBodySize)
+	respBody.onClose = func() {
+		entry.Duration = time.Since(start)
+		if reqBody != nil {
+			entry.RequestBody, entry.RequestBodySize = reqBody.recorded()
+		}
+		entry.ResponseBody, entry.ResponseBodySize = respBody.recorded()
+		c.logger(entry)
+	}
+	resp.Body = respBody
+
+	return resp, nil
+}
+
+// bodyRecorder wraps a body and records up to a maximum amount of bytes read
+// from it.
+type bodyRecorder struct {
+	io.ReadCloser
+
+	max     int
+	omit    bool
+	onClose func()
+
+	buf       []byte
+	size      int64
+	closeOnce sync.Once
+	mtx       sync.Mutex
+}
+
+func newBodyRecorder(rc io.ReadCloser, max int) *bodyRecorder {
+	return &bodyRecorder{
+		ReadCloser: rc,
+
+		max: max,
+	}
+}
+
+// Read implements `io.Reader`.
+func (r *bodyRecorder) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+
+	r.mtx.Lock()
+	r.size += int64(n)
+	if rem := r.max - len(r.buf); !r.omit && rem > 0 {
+		if rem > n {
+			rem = n
+		}
+		r.buf = append(r.buf, p[:rem]...)
+	}
+	r.mtx.Unlock()
+
+	return n, err
+}
+
+// Close implements `io.Closer`.
+func (r *bodyRecorder) Close() error {
+	err := r.ReadCloser.Close()
+	if r.onClose != nil {
+		r.closeOnce.Do(r.onClose)
+	}
+	return err
+}
+
+// recorded returns the recorded part of the body and the amount of bytes read.
+func (r *bodyRecorder) recorded() (string, int64) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return string(r.buf), r.size
+}
+
+// redactURL returns a copy of the given URL with all secrets redacted.
+func redactURL(u *url.URL) *url.URL {
+	res := *u
+	q := res.Query()
+	for _, param := range redactedQueryParameters {
+		if q.Has(param) {
+			q.Set(param, redacted)
+		}
+	}
+	res.RawQuery = q.Encode()
+	return &res
+}
+
+// redactHeader returns a copy of the given header with all secrets redacted.
+func redactHeader(h http.Header) http.Header {
+	res := h.Clone()
+	if res.Get(headerAuthorization) != "" {
+		res.Set(headerAuthorization, redacted)
+	}
+	return res
+}
@@ -0,0 +1,133 @@
+package axiom
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Logging(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.URL.Query().Get("tk"))
+
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		w.Header().Set(headerRateScope, "user")
+		w.Header().Set(headerRateLimit, "1000")
+		w.Header().Set(headerRateRemaining, "999")
+		w.Header().Set(headerRateReset, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+		_, err := fmt.Fprint(w, `{"id":"test","name":"test"}`)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test", hf)
+
+	var entries []LogEntry
+	err := client.Options(
+		SetLogger(func(entry LogEntry) { entries = append(entries, entry) }),
+		SetLogMaxBodySize(6),
+	)
+	require.NoError(t, err)
+
+	req, err := client.NewRequest(context.Background(), http.MethodPost, "/api/v1/datasets/test?tk=secret&q=1", strings.NewReader(`{"name":"test"}`))
+	require.NoError(t, err)
+
+	_, err = client.Do(req, nil)
+	require.NoError(t, err)
+
+	require.Len(t, entries, 1)
+	entry := entries[0]
+
+	assert.Equal(t, http.MethodPost, entry.Method)
+	assert.Equal(t, "/api/v1/datasets/test", entry.URL.Path)
+	assert.Equal(t, redacted, entry.URL.Query().Get("tk"))
+	assert.Equal(t, "1", entry.URL.Query().Get("q"))
+	assert.Equal(t, redacted, entry.Header.Get("Authorization"))
+	assert.Equal(t, http.StatusOK, entry.StatusCode)
+	assert.EqualValues(t, 999, entry.Limit.Remaining)
+	assert.Equal(t, `{"name`, entry.RequestBody)
+	assert.EqualValues(t, 15, entry.RequestBodySize)
+	assert.Equal(t, `{"id":`, entry.ResponseBody)
+	assert.EqualValues(t, 27, entry.ResponseBodySize)
+	assert.Positive(t, entry.Duration)
+	assert.NoError(t, entry.Error)
+
+	// The original request must not be altered by redaction.
+	assert.Equal(t, "secret", req.URL.Query().Get("tk"))
+	assert.NotEqual(t, redacted, req.Header.Get("Authorization"))
+
+	s := entry.String()
+	assert.Contains(t, s, "POST /api/v1/datasets/test?q=1&tk=REDACTED 200")
+	assert.NotContains(t, s, "secret")
+}
+
+func TestClient_Logging_Retry(t *testing.T) {
+	var attempts int
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		if attempts++; attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err := fmt.Fprint(w, `{"id":"test","name":"test"}`)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test", hf)
+
+	var buf bytes.Buffer
+	err := client.Options(SetLogger(StdLogger(log.New(&buf, "", 0))))
+	require.NoError(t, err)
+
+	_, err = client.Datasets.Get(context.Background(), "test")
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "Datasets.Get: GET /api/v1/datasets/test 500")
+	assert.Contains(t, lines[1], "Datasets.Get: GET /api/v1/datasets/test 200")
+}
+
+func TestClient_Logging_Sampling(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err := fmt.Fprint(w, `{"id":"test","name":"test"}`)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test", hf)
+
+	var logged int
+	err := client.Options(
+		SetLogger(func(LogEntry) { logged++ }),
+		SetLogSampleRate(0.5),
+	)
+	require.NoError(t, err)
+
+	const n = 200
+	for i := 0; i < n; i++ {
+		_, err = client.Datasets.Get(context.Background(), "test")
+		require.NoError(t, err)
+	}
+
+	assert.Greater(t, logged, 0)
+	assert.Less(t, logged, n)
+}
+
+func TestClient_Options_SetLogSampleRate(t *testing.T) {
+	client := newClient(t)
+
+	assert.Error(t, client.Options(SetLogSampleRate(0)))
+	assert.Error(t, client.Options(SetLogSampleRate(1.5)))
+	assert.NoError(t, client.Options(SetLogSampleRate(0.1)))
+
+	assert.Equal(t, 0.1, client.logSampleRate)
+}
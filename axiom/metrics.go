@@ -0,0 +1,194 @@
+package axiom
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/asyncint64"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+	"github.com/axiomhq/axiom-go/axiom/query"
+)
+
+// metrics holds the instruments the client records its metrics with. A nil
+// `*metrics` records nothing.
+type metrics struct {
+	requests        syncint64.Counter
+	requestDuration syncfloat64.Histogram
+	eventsIngested  syncint64.Counter
+	eventsFailed    syncint64.Counter
+	processedBytes  syncint64.Counter
+	rowsExamined    syncint64.Counter
+	limitRemaining  asyncint64.Gauge
+
+	// stopped is set once the metrics are replaced, as the callback observing
+	// the remaining limits can't be unregistered. Only accessed atomically.
+	stopped uint32
+}
+
+// newMetrics creates the instruments of the client using a meter of the given
+// meter provider. The remaining limit gauge observes the limits known to the
+// given limit tracker.
+func newMetrics(mp metric.MeterProvider, limits *limitTracker) (*metrics, error) {
+	var (
+		meter = mp.Meter(otelMeterName)
+		m     metrics
+		err   error
+	)
+
+	if m.requests, err = meter.SyncInt64().Counter("axiom.client.requests",
+		instrument.WithDescription("Number of API requests sent by the client."),
+		instrument.WithUnit(unit.Dimensionless),
+	); err != nil {
+		return nil, err
+	}
+
+	if m.requestDuration, err = meter.SyncFloat64().Histogram("axiom.client.request.duration",
+		instrument.WithDescription("Duration of API requests sent by the client, including retries."),
+		instrument.WithUnit(unit.Milliseconds),
+	); err != nil {
+		return nil, err
+	}
+
+	if m.eventsIngested, err = meter.SyncInt64().Counter("axiom.client.events.ingested",
+		instrument.WithDescription("Number of events successfully ingested."),
+		instrument.WithUnit(unit.Dimensionless),
+	); err != nil {
+		return nil, err
+	}
+
+	if m.eventsFailed, err = meter.SyncInt64().Counter("axiom.client.events.failed",
+		instrument.WithDescription("Number of events that failed to be ingested."),
+		instrument.WithUnit(unit.Dimensionless),
+	); err != nil {
+		return nil, err
+	}
+
+	if m.processedBytes, err = meter.SyncInt64().Counter("axiom.client.events.processed_bytes",
+		instrument.WithDescription("Number of bytes processed by the server while ingesting events."),
+		instrument.WithUnit(unit.Bytes),
+	); err != nil {
+		return nil, err
+	}
+
+	if m.rowsExamined, err = meter.SyncInt64().Counter("axiom.client.query.rows_examined",
+		instrument.WithDescription("Number of rows examined by queries."),
+		instrument.WithUnit(unit.Dimensionless),
+	); err != nil {
+		return nil, err
+	}
+
+	if m.limitRemaining, err = meter.AsyncInt64().Gauge("axiom.client.limit.remaining",
+		instrument.WithDescription("Remaining amount of a limit as last reported by the server."),
+		instrument.WithUnit(unit.Dimensionless),
+	); err != nil {
+		return nil, err
+	}
+
+	if err = meter.RegisterCallback([]instrument.Asynchronous{m.limitRemaining}, func(ctx context.Context) {
+		if atomic.LoadUint32(&m.stopped) == 1 {
+			return
+		}
+		limits.forEach(func(key limitKey, limit Limit) {
+			attrs := []attribute.KeyValue{
+				attribute.String("axiom.limit.type", key.limitType.String()),
+				attribute.String("axiom.limit.scope", key.scope.String()),
+			}
+			if key.organizationID != "" {
+				attrs = append(attrs, attribute.String("axiom.organization_id", key.organizationID))
+			}
+			m.limitRemaining.Observe(ctx, int64(limit.Remaining), attrs...)
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// stop stops observing the remaining limits.
+func (m *metrics) stop() {
+	if m == nil {
+		return
+	}
+	atomic.StoreUint32(&m.stopped, 1)
+}
+
+// recordRequest records an API request issued for the given operation which
+// was started at the given time and resulted in the given response and error.
+func (m *metrics) recordRequest(ctx context.Context, op Operation, start time.Time, resp *Response, err error) {
+	if m == nil {
+		return
+	}
+
+	var statusCode int
+	if resp != nil {
+		statusCode = resp.StatusCode
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("axiom.operation", op.Name),
+		attribute.Int("http.status_code", statusCode),
+		attribute.Bool("axiom.error", err != nil),
+	}
+
+	m.requests.Add(ctx, 1, attrs...)
+	m.requestDuration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), attrs...)
+}
+
+// recordIngestStatus records the status of an ingest into the dataset
+// identified by its id.
+func (m *metrics) recordIngestStatus(ctx context.Context, id string, status ingest.Status) {
+	if m == nil {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("axiom.dataset_id", id),
+	}
+
+	m.eventsIngested.Add(ctx, int64(status.Ingested), attrs...)
+	m.eventsFailed.Add(ctx, int64(status.Failed), attrs...)
+	m.processedBytes.Add(ctx, int64(status.ProcessedBytes), attrs...)
+}
+
+// recordFailedEvents records the given amount of events which failed to be
+// ingested because the request they were sent with failed. They are recorded
+// for the dataset of the operation carried by the context.
+func (m *metrics) recordFailedEvents(ctx context.Context, n int) {
+	if m == nil || n == 0 {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("axiom.dataset_id", operationFromContext(ctx).DatasetID),
+	}
+
+	m.eventsFailed.Add(ctx, int64(n), attrs...)
+}
+
+// recordQueryResult records the result of a query issued for the operation
+// carried by the context.
+func (m *metrics) recordQueryResult(ctx context.Context, res query.Result) {
+	if m == nil {
+		return
+	}
+
+	op := operationFromContext(ctx)
+
+	attrs := []attribute.KeyValue{
+		attribute.String("axiom.operation", op.Name),
+	}
+	if op.DatasetID != "" {
+		attrs = append(attrs, attribute.String("axiom.dataset_id", op.DatasetID))
+	}
+
+	m.rowsExamined.Add(ctx, int64(res.Status.RowsExamined), attrs...)
+}
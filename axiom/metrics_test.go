@@ -0,0 +1,357 @@
+package axiom
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/asyncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/asyncint64"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+)
+
+func TestClient_Metrics_Ingest(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		w.Header().Set(headerIngestLimit, "1000")
+		w.Header().Set(headerIngestRemaining, "900")
+		w.Header().Set(headerIngestReset, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+		_, err := fmt.Fprint(w, `{
+			"ingested": 2,
+			"failed": 1,
+			"failures": [],
+			"processedBytes": 630,
+			"blocksCreated": 0,
+			"walLength": 2
+		}`)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	mp := newTestMeterProvider()
+	err := client.Options(SetMeterProvider(mp))
+	require.NoError(t, err)
+
+	_, err = client.Datasets.Ingest(context.Background(), "test", strings.NewReader(`[{},{},{}]`), JSON, Identity)
+	require.NoError(t, err)
+
+	opAttrs := []attribute.KeyValue{
+		attribute.String("axiom.operation", "Datasets.Ingest"),
+		attribute.Int("http.status_code", http.StatusOK),
+		attribute.Bool("axiom.error", false),
+	}
+	assert.EqualValues(t, 1, mp.value("axiom.client.requests", opAttrs...))
+	assert.Positive(t, mp.value("axiom.client.request.duration", opAttrs...))
+
+	datasetAttrs := []attribute.KeyValue{attribute.String("axiom.dataset_id", "test")}
+	assert.EqualValues(t, 2, mp.value("axiom.client.events.ingested", datasetAttrs...))
+	assert.EqualValues(t, 1, mp.value("axiom.client.events.failed", datasetAttrs...))
+	assert.EqualValues(t, 630, mp.value("axiom.client.events.processed_bytes", datasetAttrs...))
+
+	mp.collect(context.Background())
+	limitAttrs := []attribute.KeyValue{
+		attribute.String("axiom.limit.type", "ingest"),
+		attribute.String("axiom.limit.scope", "unknown"),
+		attribute.String("axiom.organization_id", organizationID),
+	}
+	assert.EqualValues(t, 900, mp.value("axiom.client.limit.remaining", limitAttrs...))
+}
+
+func TestClient_Metrics_Query(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err := fmt.Fprint(w, `{
+			"status": {
+				"rowsExamined": 142655,
+				"rowsMatched": 142655
+			}
+		}`)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/_apl", hf)
+
+	mp := newTestMeterProvider()
+	err := client.Options(SetMeterProvider(mp))
+	require.NoError(t, err)
+
+	_, err = client.Datasets.Query(context.Background(), "['test']")
+	require.NoError(t, err)
+
+	attrs := []attribute.KeyValue{attribute.String("axiom.operation", "Datasets.Query")}
+	assert.EqualValues(t, 142655, mp.value("axiom.client.query.rows_examined", attrs...))
+}
+
+func TestClient_Metrics_Error(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}
+
+	client := setup(t, "/api/v1/datasets/test", hf)
+
+	mp := newTestMeterProvider()
+	err := client.Options(SetMeterProvider(mp))
+	require.NoError(t, err)
+
+	_, err = client.Datasets.Get(context.Background(), "test")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	attrs := []attribute.KeyValue{
+		attribute.String("axiom.operation", "Datasets.Get"),
+		attribute.Int("http.status_code", http.StatusNotFound),
+		attribute.Bool("axiom.error", true),
+	}
+	assert.EqualValues(t, 1, mp.value("axiom.client.requests", attrs...))
+}
+
+func TestClient_Metrics_IngestError(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	events := []Event{{"n": 1}, {"_n": 2}, {"n": 3}, {"n": 4}}
+	options := []ingest.Option{
+		ingest.SetMaxEvents(2),
+		ingest.SetValidator(&ingest.Validator{}),
+	}
+
+	tests := []struct {
+		name      string
+		ingest    func() error
+		expFailed float64
+	}{
+		{
+			name: "IngestEvents",
+			ingest: func() error {
+				_, err := client.Datasets.IngestEvents(context.Background(), "test", events, options...)
+				return err
+			},
+			expFailed: 4,
+		},
+		{
+			name: "IngestEvents/single request",
+			ingest: func() error {
+				_, err := client.Datasets.IngestEvents(context.Background(), "test", events, ingest.SetValidator(&ingest.Validator{}))
+				return err
+			},
+			expFailed: 4,
+		},
+		{
+			// Events are only consumed until the first request fails.
+			name: "IngestChannel",
+			ingest: func() error {
+				eventCh := make(chan Event, len(events))
+				for _, event := range events {
+					eventCh <- event
+				}
+				close(eventCh)
+				_, err := client.Datasets.IngestChannel(context.Background(), "test", eventCh, options...)
+				return err
+			},
+			expFailed: 3,
+		},
+		{
+			name: "IngestFile",
+			ingest: func() error {
+				r := strings.NewReader("{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n")
+				_, err := client.Datasets.IngestFile(context.Background(), "test", r, NDJSON, ingest.SetMaxEvents(2))
+				return err
+			},
+			expFailed: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mp := newTestMeterProvider()
+			err := client.Options(SetMeterProvider(mp))
+			require.NoError(t, err)
+
+			require.Error(t, tt.ingest())
+
+			datasetAttrs := []attribute.KeyValue{attribute.String("axiom.dataset_id", "test")}
+			assert.EqualValues(t, tt.expFailed, mp.value("axiom.client.events.failed", datasetAttrs...))
+			assert.Zero(t, mp.value("axiom.client.events.ingested", datasetAttrs...))
+		})
+	}
+}
+
+func TestClient_Metrics_NilMeterProvider(t *testing.T) {
+	client := newClient(t)
+
+	mp := newTestMeterProvider()
+	err := client.Options(SetMeterProvider(mp), SetMeterProvider(nil))
+	require.NoError(t, err)
+
+	assert.NotNil(t, client.metrics)
+}
+
+func TestClient_Metrics_LimitRemaining(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		w.Header().Set(headerIngestLimit, "1000")
+		w.Header().Set(headerIngestRemaining, "900")
+		w.Header().Set(headerIngestReset, strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+		_, err := fmt.Fprint(w, `{"ingested":1}`)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	// The remaining limits are reported without limiting, too, and only once
+	// after setting a meter provider again.
+	mp := newTestMeterProvider()
+	err := client.Options(SetNoLimiting(), SetMeterProvider(mp), SetMeterProvider(mp))
+	require.NoError(t, err)
+
+	_, err = client.Datasets.Ingest(context.Background(), "test", strings.NewReader(`[{}]`), JSON, Identity)
+	require.NoError(t, err)
+
+	mp.collect(context.Background())
+	limitAttrs := []attribute.KeyValue{
+		attribute.String("axiom.limit.type", "ingest"),
+		attribute.String("axiom.limit.scope", "unknown"),
+		attribute.String("axiom.organization_id", organizationID),
+	}
+	assert.EqualValues(t, 900, mp.value("axiom.client.limit.remaining", limitAttrs...))
+	assert.EqualValues(t, 1, mp.value("axiom.client.requests",
+		attribute.String("axiom.operation", "Datasets.Ingest"),
+		attribute.Int("http.status_code", http.StatusOK),
+		attribute.Bool("axiom.error", false),
+	))
+}
+
+type measurementKey struct {
+	name  string
+	attrs attribute.Distinct
+}
+
+// testMeterProvider is a `metric.MeterProvider` which sums up all values
+// recorded by the instruments the client uses.
+type testMeterProvider struct {
+	noop metric.Meter
+
+	values    map[measurementKey]float64
+	callbacks []func(context.Context)
+	mtx       sync.Mutex
+}
+
+func newTestMeterProvider() *testMeterProvider {
+	return &testMeterProvider{
+		noop: metric.NewNoopMeter(),
+
+		values: make(map[measurementKey]float64),
+	}
+}
+
+func (mp *testMeterProvider) Meter(string, ...metric.MeterOption) metric.Meter {
+	return mp
+}
+
+func (mp *testMeterProvider) SyncInt64() syncint64.InstrumentProvider {
+	return testSyncInt64{mp.noop.SyncInt64(), mp}
+}
+
+func (mp *testMeterProvider) SyncFloat64() syncfloat64.InstrumentProvider {
+	return testSyncFloat64{mp.noop.SyncFloat64(), mp}
+}
+
+func (mp *testMeterProvider) AsyncFloat64() asyncfloat64.InstrumentProvider {
+	return mp.noop.AsyncFloat64()
+}
+
+func (mp *testMeterProvider) AsyncInt64() asyncint64.InstrumentProvider {
+	return testAsyncInt64{mp.noop.AsyncInt64(), mp}
+}
+
+func (mp *testMeterProvider) RegisterCallback(_ []instrument.Asynchronous, fn func(context.Context)) error {
+	mp.callbacks = append(mp.callbacks, fn)
+	return nil
+}
+
+func (mp *testMeterProvider) record(name string, v float64, attrs []attribute.KeyValue) {
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	set := attribute.NewSet(attrs...)
+	mp.values[measurementKey{name, set.Equivalent()}] += v
+}
+
+func (mp *testMeterProvider) collect(ctx context.Context) {
+	for _, fn := range mp.callbacks {
+		fn(ctx)
+	}
+}
+
+func (mp *testMeterProvider) value(name string, attrs ...attribute.KeyValue) float64 {
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	set := attribute.NewSet(attrs...)
+	return mp.values[measurementKey{name, set.Equivalent()}]
+}
+
+type testInstrument struct {
+	instrument.Synchronous
+	instrument.Asynchronous
+
+	mp   *testMeterProvider
+	name string
+}
+
+func (i *testInstrument) Add(_ context.Context, v int64, attrs ...attribute.KeyValue) {
+	i.mp.record(i.name, float64(v), attrs)
+}
+
+func (i *testInstrument) Record(_ context.Context, v float64, attrs ...attribute.KeyValue) {
+	i.mp.record(i.name, v, attrs)
+}
+
+func (i *testInstrument) Observe(_ context.Context, v int64, attrs ...attribute.KeyValue) {
+	i.mp.record(i.name, float64(v), attrs)
+}
+
+type testSyncInt64 struct {
+	syncint64.InstrumentProvider
+
+	mp *testMeterProvider
+}
+
+func (p testSyncInt64) Counter(name string, _ ...instrument.Option) (syncint64.Counter, error) {
+	return &testInstrument{mp: p.mp, name: name}, nil
+}
+
+type testSyncFloat64 struct {
+	syncfloat64.InstrumentProvider
+
+	mp *testMeterProvider
+}
+
+func (p testSyncFloat64) Histogram(name string, _ ...instrument.Option) (syncfloat64.Histogram, error) {
+	return &testInstrument{mp: p.mp, name: name}, nil
+}
+
+type testAsyncInt64 struct {
+	asyncint64.InstrumentProvider
+
+	mp *testMeterProvider
+}
+
+func (p testAsyncInt64) Gauge(name string, _ ...instrument.Option) (asyncint64.Gauge, error) {
+	return &testInstrument{mp: p.mp, name: name}, nil
+}
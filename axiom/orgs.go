@@ -171,6 +171,16 @@ type wrappedOrganization struct {
 	ExternalPlan any `json:"externalPlan,omitempty"`
 }
 
+// OrganizationsAPI describes the organization related operations of the Axiom
+// API, as implemented by `OrganizationsService` and faked by
+// `axiomtest.Organizations`.
+type OrganizationsAPI interface {
+	List(ctx context.Context) ([]*Organization, error)
+	Get(ctx context.Context, id string) (*Organization, error)
+}
+
+var _ OrganizationsAPI = (*OrganizationsService)(nil)
+
 // OrganizationsService handles communication with the organization related
 // operations of the Axiom API.
 //
@@ -179,7 +189,7 @@ type OrganizationsService service
 
 // List all available organizations.
 func (s *OrganizationsService) List(ctx context.Context) ([]*Organization, error) {
-	ctx, span := s.client.trace(ctx, "Organizations.List")
+	ctx, span := s.client.trace(ctx, Operation{Name: "Organizations.List"})
 	defer span.End()
 
 	var res []*wrappedOrganization
@@ -197,7 +207,7 @@ func (s *OrganizationsService) List(ctx context.Context) ([]*Organization, error
 
 // Get an organization by id.
 func (s *OrganizationsService) Get(ctx context.Context, id string) (*Organization, error) {
-	ctx, span := s.client.trace(ctx, "Organizations.Get", trace.WithAttributes(
+	ctx, span := s.client.trace(ctx, Operation{Name: "Organizations.Get"}, trace.WithAttributes(
 		attribute.String("axiom.dataset_id", id),
 	))
 	defer span.End()
@@ -0,0 +1,154 @@
+package axiom
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+)
+
+// progressInterval is the minimum interval between two progress reports.
+const progressInterval = 100 * time.Millisecond
+
+// progressTracker counts the progress of an ingestion and reports it to an
+// `ingest.ProgressFunc`. A nil tracker discards all counts, so callers don't
+// need to check if progress is to be reported at all.
+type progressTracker struct {
+	f     ingest.ProgressFunc
+	start time.Time
+
+	// Only accessed atomically.
+	bytesRead, bytesSent, events, requests uint64
+	lastReport                             int64
+
+	mtx sync.Mutex
+}
+
+// newProgressTracker returns a tracker reporting to the given function. It
+// returns nil if the function is nil.
+func newProgressTracker(f ingest.ProgressFunc) *progressTracker {
+	if f == nil {
+		return nil
+	}
+	now := time.Now()
+	return &progressTracker{
+		f:          f,
+		start:      now,
+		lastReport: now.UnixNano(),
+	}
+}
+
+// encoded counts an event of the given encoded size.
+func (t *progressTracker) encoded(n int) {
+	if t == nil {
+		return
+	}
+	atomic.AddUint64(&t.bytesRead, uint64(n))
+	atomic.AddUint64(&t.events, 1)
+	t.report(false)
+}
+
+// read counts the given amount of records read, which make up the given
+// amount of bytes.
+func (t *progressTracker) read(n, records int) {
+	if t == nil {
+		return
+	}
+	atomic.AddUint64(&t.bytesRead, uint64(n))
+	atomic.AddUint64(&t.events, uint64(records))
+	t.report(false)
+}
+
+// sent counts the given amount of bytes sent.
+func (t *progressTracker) sent(n int) {
+	if t == nil {
+		return
+	}
+	atomic.AddUint64(&t.bytesSent, uint64(n))
+	t.report(false)
+}
+
+// completed counts a completed request.
+func (t *progressTracker) completed() {
+	if t == nil {
+		return
+	}
+	atomic.AddUint64(&t.requests, 1)
+	t.report(false)
+}
+
+// done reports the final progress.
+func (t *progressTracker) done() {
+	if t == nil {
+		return
+	}
+	t.report(true)
+}
+
+// report calls the progress function, unless the last report is too recent
+// and the report is not forced.
+func (t *progressTracker) report(force bool) {
+	now := time.Now()
+	if !force && now.UnixNano()-atomic.LoadInt64(&t.lastReport) < int64(progressInterval) {
+		return
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	// Another goroutine might have reported in the meantime.
+	if !force && now.UnixNano()-atomic.LoadInt64(&t.lastReport) < int64(progressInterval) {
+		return
+	}
+	atomic.StoreInt64(&t.lastReport, now.UnixNano())
+
+	t.f(ingest.Progress{
+		BytesRead:         atomic.LoadUint64(&t.bytesRead),
+		BytesSent:         atomic.LoadUint64(&t.bytesSent),
+		EventsEncoded:     atomic.LoadUint64(&t.events),
+		RequestsCompleted: atomic.LoadUint64(&t.requests),
+		Elapsed:           now.Sub(t.start),
+	})
+}
+
+// trackBody counts the bytes of the body of the given request as sent, also
+// when the request is retried. If read is true, the body is the content to
+// ingest as is and the bytes of the first attempt are counted as read, too.
+func (t *progressTracker) trackBody(req *http.Request, read bool) {
+	if t == nil || req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	req.Body = &progressBody{ReadCloser: req.Body, t: t, read: read}
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return &progressBody{ReadCloser: body, t: t}, nil
+		}
+	}
+}
+
+// progressBody is a request body which counts the bytes read from it.
+type progressBody struct {
+	io.ReadCloser
+
+	t    *progressTracker
+	read bool
+}
+
+func (b *progressBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if b.read {
+			atomic.AddUint64(&b.t.bytesRead, uint64(n))
+		}
+		b.t.sent(n)
+	}
+	return n, err
+}
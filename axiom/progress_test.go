@@ -0,0 +1,198 @@
+package axiom
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+)
+
+func TestProgress(t *testing.T) {
+	events := make([]Event, 10)
+	var size uint64
+	for i := range events {
+		events[i] = Event{"n": i}
+		b, err := json.Marshal(events[i])
+		require.NoError(t, err)
+		size += uint64(len(b)) + 1
+	}
+
+	ndjson := `{"n":1}` + "\n" + `{"n":2}` + "\n" + `{"n":3}` + "\n"
+
+	tests := []struct {
+		name   string
+		ingest func(context.Context, *Client, ...ingest.Option) error
+		exp    ingest.Progress
+		sent   bool // BytesRead equals BytesSent.
+	}{
+		{
+			name: "Ingest",
+			ingest: func(ctx context.Context, client *Client, options ...ingest.Option) error {
+				_, err := client.Datasets.Ingest(ctx, "test", strings.NewReader(ndjson), NDJSON, Identity, options...)
+				return err
+			},
+			exp: ingest.Progress{
+				BytesRead:         uint64(len(ndjson)),
+				RequestsCompleted: 1,
+			},
+			sent: true,
+		},
+		{
+			name: "IngestEvents",
+			ingest: func(ctx context.Context, client *Client, options ...ingest.Option) error {
+				_, err := client.Datasets.IngestEvents(ctx, "test", events, append(options, ingest.SetMaxEvents(4))...)
+				return err
+			},
+			exp: ingest.Progress{
+				BytesRead:         size,
+				EventsEncoded:     10,
+				RequestsCompleted: 3,
+			},
+		},
+		{
+			name: "IngestEvents compressed",
+			ingest: func(ctx context.Context, client *Client, options ...ingest.Option) error {
+				_, err := client.Datasets.IngestEvents(ctx, "test", events, append(options, ingest.SetMaxCompressedBytes(1))...)
+				return err
+			},
+			exp: ingest.Progress{
+				BytesRead:         size,
+				EventsEncoded:     10,
+				RequestsCompleted: 10,
+			},
+		},
+		{
+			name: "IngestChannel",
+			ingest: func(ctx context.Context, client *Client, options ...ingest.Option) error {
+				ch := make(chan Event, len(events))
+				for _, event := range events {
+					ch <- event
+				}
+				close(ch)
+				_, err := client.Datasets.IngestChannel(ctx, "test", ch, append(options, ingest.SetMaxEvents(5))...)
+				return err
+			},
+			exp: ingest.Progress{
+				BytesRead:         size,
+				EventsEncoded:     10,
+				RequestsCompleted: 2,
+			},
+		},
+		{
+			name: "IngestFile",
+			ingest: func(ctx context.Context, client *Client, options ...ingest.Option) error {
+				_, err := client.Datasets.IngestFile(ctx, "test", strings.NewReader(ndjson), NDJSON, append(options, ingest.SetMaxEvents(2))...)
+				return err
+			},
+			exp: ingest.Progress{
+				BytesRead:         uint64(len(ndjson)),
+				EventsEncoded:     3,
+				RequestsCompleted: 2,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				sent int
+				mtx  sync.Mutex
+			)
+			hf := func(w http.ResponseWriter, r *http.Request) {
+				b, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+
+				mtx.Lock()
+				sent += len(b)
+				mtx.Unlock()
+
+				w.Header().Set("Content-Type", mediaTypeJSON)
+				_, err = fmt.Fprint(w, `{"ingested":1}`)
+				assert.NoError(t, err)
+			}
+
+			client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+			var reports []ingest.Progress
+			err := tt.ingest(context.Background(), client, ingest.SetProgress(func(p ingest.Progress) {
+				reports = append(reports, p)
+			}))
+			require.NoError(t, err)
+
+			require.NotEmpty(t, reports)
+			act := reports[len(reports)-1]
+			assert.Positive(t, act.Elapsed)
+			act.Elapsed = 0
+
+			exp := tt.exp
+			exp.BytesSent = uint64(sent)
+			if tt.sent {
+				assert.Equal(t, exp.BytesRead, exp.BytesSent)
+			}
+			assert.Equal(t, exp, act)
+		})
+	}
+}
+
+func TestProgress_Retry(t *testing.T) {
+	var (
+		calls int
+		sent  int
+	)
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		sent += len(b)
+
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err = fmt.Fprint(w, `{"ingested":1}`)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	policy := DefaultRetryPolicy()
+	policy.InitialInterval = time.Millisecond
+	require.NoError(t, client.Options(SetRetryPolicy(policy)))
+
+	var last ingest.Progress
+	_, err := client.Datasets.IngestEvents(context.Background(), "test", []Event{{"n": 1}},
+		ingest.SetProgress(func(p ingest.Progress) { last = p }),
+	)
+	require.NoError(t, err)
+
+	// The retried request is sent twice but its events are only encoded once.
+	assert.Equal(t, 2, calls)
+	assert.EqualValues(t, sent, last.BytesSent)
+	assert.EqualValues(t, len(`{"n":1}`)+1, last.BytesRead)
+	assert.EqualValues(t, 1, last.EventsEncoded)
+	assert.EqualValues(t, 1, last.RequestsCompleted)
+}
+
+func TestProgressTracker_Throttle(t *testing.T) {
+	var calls int
+	tracker := newProgressTracker(func(ingest.Progress) { calls++ })
+
+	for i := 0; i < 1000; i++ {
+		tracker.encoded(10)
+	}
+	tracker.done()
+
+	// Only the final report is guaranteed, unless the loop took long.
+	assert.GreaterOrEqual(t, calls, 1)
+	assert.Less(t, calls, 10)
+}
@@ -0,0 +1,145 @@
+package axiom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+// RetryPolicy controls if and how the client retries failed requests. Retries
+// are done with an exponential backoff. Requests which body can't be replayed
+// are never retried. Refer to `Client.Do` for details. Zero values select the
+// defaults documented on each field.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum amount of attempts made for a request,
+	// including the initial one. A value of one disables retries. Zero means
+	// the amount of attempts is only bounded by MaxElapsedTime.
+	MaxAttempts uint
+	// MaxElapsedTime is the maximum time spent on retrying a request. Zero
+	// means the time is only bounded by MaxAttempts and the requests context.
+	// If MaxAttempts is zero, too, it defaults to ten seconds.
+	MaxElapsedTime time.Duration
+	// InitialInterval is the time to wait before the first retry. Defaults to
+	// 200 milliseconds.
+	InitialInterval time.Duration
+	// MaxInterval caps the time to wait between two retries. Defaults to one
+	// minute.
+	MaxInterval time.Duration
+	// Multiplier is the factor the interval is multiplied with after each
+	// retry. Must be at least one. Defaults to two.
+	Multiplier float64
+	// Jitter randomizes each interval by the given factor to avoid retrying
+	// requests in lockstep. Must be in the range of [0, 1]. Zero disables
+	// jitter.
+	Jitter float64
+	// StatusCodes are the HTTP status codes of responses that are retried. If
+	// empty, responses with a status code >= 500 are retried.
+	StatusCodes []int
+	// Methods are the HTTP methods of requests that are retried. If empty,
+	// requests of all methods are retried. Restrict this to idempotent methods
+	// if a request must never be applied twice.
+	Methods []string
+	// RetryError reports if a request which failed with the given error, e.g.
+	// because of a network issue, is retried. If nil, all errors but the ones
+	// caused by a canceled context are retried.
+	RetryError func(err error) bool
+}
+
+// DefaultRetryPolicy returns the retry policy used by a client if not
+// configured otherwise.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxElapsedTime:  10 * time.Second,
+		InitialInterval: 200 * time.Millisecond,
+		MaxInterval:     backoff.DefaultMaxInterval,
+		Multiplier:      2.0,
+		Jitter:          backoff.DefaultRandomizationFactor,
+	}
+}
+
+// withDefaults returns the retry policy with its zero values replaced by the
+// defaults documented on each field.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	def := DefaultRetryPolicy()
+	if p.MaxAttempts == 0 && p.MaxElapsedTime == 0 {
+		p.MaxElapsedTime = def.MaxElapsedTime
+	}
+	if p.InitialInterval == 0 {
+		p.InitialInterval = def.InitialInterval
+	}
+	if p.MaxInterval == 0 {
+		p.MaxInterval = def.MaxInterval
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = def.Multiplier
+	}
+	return p
+}
+
+// validate makes sure the retry policy is valid. It must be called on a policy
+// returned by `RetryPolicy.withDefaults`.
+func (p RetryPolicy) validate() error {
+	if p.Multiplier < 1 {
+		return fmt.Errorf("invalid retry multiplier %g: must be at least 1", p.Multiplier)
+	} else if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("invalid retry jitter %g: must be in range [0, 1]", p.Jitter)
+	} else if p.InitialInterval < 0 || p.MaxInterval < 0 || p.MaxElapsedTime < 0 {
+		return errors.New("invalid retry intervals: must not be negative")
+	}
+	return nil
+}
+
+// backOff returns the `backoff.BackOff` implementing the retry policy for a
+// request executed with the given context.
+func (p RetryPolicy) backOff(ctx context.Context) backoff.BackOff {
+	bck := backoff.NewExponentialBackOff()
+	bck.InitialInterval = p.InitialInterval
+	bck.MaxInterval = p.MaxInterval
+	bck.Multiplier = p.Multiplier
+	bck.RandomizationFactor = p.Jitter
+	bck.MaxElapsedTime = p.MaxElapsedTime
+	bck.Reset()
+
+	var b backoff.BackOff = bck
+	if p.MaxAttempts > 0 {
+		b = backoff.WithMaxRetries(b, uint64(p.MaxAttempts-1))
+	}
+	return backoff.WithContext(b, ctx)
+}
+
+// retryMethod reports if requests using the given method are retried.
+func (p RetryPolicy) retryMethod(method string) bool {
+	if len(p.Methods) == 0 {
+		return true
+	}
+	for _, m := range p.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// retryStatusCode reports if responses with the given status code are retried.
+func (p RetryPolicy) retryStatusCode(code int) bool {
+	if len(p.StatusCodes) == 0 {
+		return code >= 500
+	}
+	for _, c := range p.StatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retryError reports if requests that failed with the given error are retried.
+func (p RetryPolicy) retryError(err error) bool {
+	if p.RetryError != nil {
+		return p.RetryError(err)
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
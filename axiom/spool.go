@@ -0,0 +1,447 @@
+package axiom
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+)
+
+const (
+	defaultSpoolMaxBytes = 256 << 20 // 256 MiB
+
+	spoolFileExt = ".batch"
+	spoolTmpExt  = ".tmp"
+)
+
+// ErrSpooled is wrapped by the errors returned from a `Spool` when a batch
+// couldn't be ingested but was persisted to be replayed later on. The original
+// error is still accessible using `errors.Is` and `errors.As`.
+var ErrSpooled = errors.New("batch spooled for replay")
+
+// SpoolConfig configures a `Spool`.
+type SpoolConfig struct {
+	// Dir is the directory the batches are persisted to. It is created if it
+	// doesn't exist. It must not be shared by multiple spools.
+	Dir string
+	// MaxBytes is the maximum size of all batches persisted to the directory.
+	// When exceeded, the oldest batches are dropped to make space for new
+	// ones. Defaults to 256 MiB.
+	MaxBytes int64
+}
+
+// SpoolStats are statistics about the batches handled by a `Spool`.
+type SpoolStats struct {
+	// Pending is the amount of batches waiting to be replayed.
+	Pending int
+	// PendingBytes is the size of the batches waiting to be replayed.
+	PendingBytes int64
+	// Spooled is the amount of batches persisted.
+	Spooled uint64
+	// Replayed is the amount of persisted batches that have been ingested.
+	Replayed uint64
+	// Dropped is the amount of batches dropped, either to stay within the
+	// configured maximum size or because the server rejected them for good.
+	Dropped uint64
+}
+
+// Spool is a disk-backed write-ahead spool for ingestion. Batches that fail to
+// be ingested because the server is unreachable, unavailable or a limit is
+// exceeded are persisted and replayed in order as soon as ingestion succeeds
+// again. As batches are kept on disk, the replay survives restarts of the
+// process. Batches rejected for reasons that won't go away, like invalid
+// credentials, are not persisted.
+//
+// Ingestion through a spool is serialized to retain the order of the batches.
+// It is safe for concurrent use.
+type Spool struct {
+	datasets DatasetsAPI
+	config   SpoolConfig
+
+	files []spoolFile
+	size  int64
+	seq   uint64
+	stats SpoolStats
+	mtx   sync.Mutex
+}
+
+type spoolFile struct {
+	name string
+	size int64
+}
+
+// spoolHeader is persisted in front of every batch and holds everything
+// needed to replay it.
+type spoolHeader struct {
+	Dataset         string         `json:"dataset"`
+	ContentType     string         `json:"contentType"`
+	ContentEncoding string         `json:"contentEncoding"`
+	Options         ingest.Options `json:"options"`
+}
+
+// spooledError wraps an ingestion error and marks its batch as spooled.
+type spooledError struct {
+	err error
+}
+
+func (e spooledError) Error() string        { return fmt.Sprintf("%s: %s", ErrSpooled, e.err) }
+func (e spooledError) Is(target error) bool { return target == ErrSpooled }
+func (e spooledError) Unwrap() error        { return e.err }
+
+// NewSpool returns a new `Spool` which ingests using the given datasets
+// service, usually `Client.Datasets`. Batches persisted to the configured
+// directory by a previous spool are picked up and replayed with the next
+// ingestion or by calling `Spool.Replay`. A spool doesn't replay batches on its
+// own, but an `Ingester` ingesting through it does, while it is idle.
+func NewSpool(datasets DatasetsAPI, config SpoolConfig) (*Spool, error) {
+	if config.Dir == "" {
+		return nil, errors.New("invalid spool config: missing directory")
+	} else if config.MaxBytes < 0 {
+		return nil, errors.New("invalid spool config: max bytes must not be negative")
+	}
+
+	if config.MaxBytes == 0 {
+		config.MaxBytes = defaultSpoolMaxBytes
+	}
+
+	if err := os.MkdirAll(config.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create spool directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("read spool directory: %w", err)
+	}
+
+	s := &Spool{
+		datasets: datasets,
+		config:   config,
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+
+		// Leftovers of a batch that was never completely written.
+		if strings.HasSuffix(name, spoolTmpExt) {
+			_ = os.Remove(filepath.Join(config.Dir, name))
+			continue
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolFileExt), 10, 64)
+		if err != nil || !strings.HasSuffix(name, spoolFileExt) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("read spooled batch: %w", err)
+		}
+
+		s.files = append(s.files, spoolFile{name: name, size: info.Size()})
+		s.size += info.Size()
+		if seq > s.seq {
+			s.seq = seq
+		}
+	}
+
+	// File names are zero padded, so sorting them by name sorts them by
+	// sequence number.
+	sort.Slice(s.files, func(i, j int) bool { return s.files[i].name < s.files[j].name })
+
+	return s, nil
+}
+
+// Ingest ingests the data read from the given reader into the dataset
+// identified by its id, like `DatasetsService.Ingest`. Batches persisted
+// before are replayed first. If that fails or the data itself fails to be
+// ingested for a transient reason, the data is persisted and the returned
+// error wraps `ErrSpooled`.
+func (s *Spool) Ingest(ctx context.Context, id string, r io.Reader, typ ContentType, enc ContentEncoding, options ...ingest.Option) (*ingest.Status, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	h := spoolHeader{
+		Dataset:         id,
+		ContentType:     typ.String(),
+		ContentEncoding: enc.String(),
+	}
+	for _, option := range options {
+		option(&h.Options)
+	}
+
+	return s.ingest(ctx, h, data)
+}
+
+// IngestEvents ingests the given events into the dataset identified by its id,
+// like `DatasetsService.IngestEvents`, but sends them as a zstd compressed
+// NDJSON batch which is persisted and replayed just like the batches passed to
+// `Spool.Ingest`. Configured processors and validators are applied before the
+// batch is persisted. If it is persisted, the returned status, if any, only
+// holds the events rejected by a validator.
+func (s *Spool) IngestEvents(ctx context.Context, id string, events []Event, options ...ingest.Option) (*ingest.Status, error) {
+	events, indices, rejected := prepareValues(events, eventPreparer(options))
+	if len(events) == 0 {
+		return &rejected, nil
+	}
+
+	data, err := io.ReadAll(encodeZstdNDJSON(valuesEncoder(events), nil))
+	if err != nil {
+		return nil, err
+	}
+
+	h := spoolHeader{
+		Dataset:         id,
+		ContentType:     NDJSON.String(),
+		ContentEncoding: Zstd.String(),
+	}
+	for _, option := range options {
+		option(&h.Options)
+	}
+
+	res, err := s.ingest(ctx, h, data)
+	if res != nil {
+		indexFailures(res.Failures, events, valueIndex(indices, 0), h.Options)
+	}
+	if rejected.Failed > 0 {
+		if res != nil {
+			rejected.Add(res)
+			res = &rejected
+		} else if errors.Is(err, ErrSpooled) {
+			// The events rejected are not part of the persisted batch.
+			res = &rejected
+		}
+	}
+	return res, err
+}
+
+// Replay ingests all persisted batches in the order they were persisted. It
+// stops at the first batch that fails for a transient reason and returns its
+// error. Batches the server rejects for good are dropped.
+func (s *Spool) Replay(ctx context.Context) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.replay(ctx)
+}
+
+// Stats returns statistics about the batches handled by the spool.
+func (s *Spool) Stats() SpoolStats {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	stats := s.stats
+	stats.Pending = len(s.files)
+	stats.PendingBytes = s.size
+	return stats
+}
+
+func (s *Spool) ingest(ctx context.Context, h spoolHeader, data []byte) (*ingest.Status, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// Only send the batch if all batches persisted before have been replayed.
+	// Otherwise, it is queued up behind them.
+	err := s.replay(ctx)
+	if err == nil {
+		var res *ingest.Status
+		if res, err = s.send(ctx, h, data); err == nil || !isTransientIngestError(err) {
+			return res, err
+		}
+	}
+
+	if storeErr := s.store(h, data); storeErr != nil {
+		return nil, fmt.Errorf("spool batch after ingest error %q: %w", err, storeErr)
+	}
+	return nil, spooledError{err: err}
+}
+
+// replay ingests all persisted batches in order. Must be called with the lock
+// held.
+func (s *Spool) replay(ctx context.Context) error {
+	for len(s.files) > 0 {
+		f := s.files[0]
+
+		h, data, err := s.load(f)
+		if err == nil {
+			if _, err = s.send(ctx, h, data); err != nil && isTransientIngestError(err) {
+				return err
+			}
+		}
+
+		if err != nil {
+			s.stats.Dropped++
+		} else {
+			s.stats.Replayed++
+		}
+		if err = s.removeOldest(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Spool) send(ctx context.Context, h spoolHeader, data []byte) (*ingest.Status, error) {
+	typ, err := parseContentType(h.ContentType)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := parseContentEncoding(h.ContentEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := h.Options
+	return s.datasets.Ingest(ctx, h.Dataset, bytes.NewReader(data), typ, enc, func(o *ingest.Options) { *o = opts })
+}
+
+// store persists a batch, dropping the oldest batches if needed to stay within
+// the configured maximum size. Must be called with the lock held.
+func (s *Spool) store(h spoolHeader, data []byte) error {
+	header, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	size := int64(len(header) + 1 + len(data))
+
+	if size > s.config.MaxBytes {
+		s.stats.Dropped++
+		return fmt.Errorf("batch of %d bytes exceeds maximum spool size of %d bytes", size, s.config.MaxBytes)
+	}
+	for s.size+size > s.config.MaxBytes {
+		if err = s.removeOldest(); err != nil {
+			return err
+		}
+		s.stats.Dropped++
+	}
+
+	s.seq++
+	name := fmt.Sprintf("%020d%s", s.seq, spoolFileExt)
+	path := filepath.Join(s.config.Dir, name)
+
+	// The batch is written to a temporary file first and renamed once
+	// complete, so a crash never leaves a partially written batch behind.
+	tmp, err := os.OpenFile(path+spoolTmpExt, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if err = writeSpoolFile(tmp, header, data); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	s.files = append(s.files, spoolFile{name: name, size: size})
+	s.size += size
+	s.stats.Spooled++
+
+	return nil
+}
+
+// load reads a persisted batch.
+func (s *Spool) load(f spoolFile) (spoolHeader, []byte, error) {
+	var h spoolHeader
+
+	b, err := os.ReadFile(filepath.Join(s.config.Dir, f.name))
+	if err != nil {
+		return h, nil, err
+	}
+
+	i := bytes.IndexByte(b, '\n')
+	if i < 0 {
+		return h, nil, fmt.Errorf("corrupt spooled batch %s: missing header", f.name)
+	}
+	if err = json.Unmarshal(b[:i], &h); err != nil {
+		return h, nil, fmt.Errorf("corrupt spooled batch %s: %w", f.name, err)
+	}
+
+	return h, b[i+1:], nil
+}
+
+// removeOldest deletes the oldest persisted batch. Must be called with the
+// lock held.
+func (s *Spool) removeOldest() error {
+	f := s.files[0]
+	if err := os.Remove(filepath.Join(s.config.Dir, f.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	s.files = s.files[1:]
+	s.size -= f.size
+	return nil
+}
+
+func writeSpoolFile(f *os.File, header, data []byte) error {
+	w := bufio.NewWriter(f)
+	_, _ = w.Write(header)
+	_ = w.WriteByte('\n')
+	_, _ = w.Write(data)
+	err := w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// isTransientIngestError reports if an ingestion that failed with the given
+// error might succeed when tried again later on.
+func isTransientIngestError(err error) bool {
+	var (
+		limitErr *LimitError
+		apiErr   *Error
+	)
+	switch {
+	case errors.As(err, &limitErr):
+		return true
+	case errors.As(err, &apiErr):
+		return apiErr.Status >= 500
+	case errors.Is(err, ErrUnauthenticated),
+		errors.Is(err, ErrUnauthorized),
+		errors.Is(err, ErrNotFound),
+		errors.Is(err, ErrExists),
+		errors.Is(err, ErrUnknownContentType),
+		errors.Is(err, ErrUnknownContentEncoding):
+		return false
+	}
+	// Anything else, like a network error or a canceled context, means the
+	// server never got to process the batch.
+	return true
+}
+
+func parseContentType(s string) (ContentType, error) {
+	for _, typ := range []ContentType{JSON, NDJSON, CSV} {
+		if typ.String() == s {
+			return typ, nil
+		}
+	}
+	return 0, ErrUnknownContentType
+}
+
+func parseContentEncoding(s string) (ContentEncoding, error) {
+	for _, enc := range []ContentEncoding{Identity, Gzip, Zstd} {
+		if enc.String() == s {
+			return enc, nil
+		}
+	}
+	return 0, ErrUnknownContentEncoding
+}
@@ -0,0 +1,194 @@
+package axiom
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+)
+
+// spoolDatasets implements the `Ingest` method of `DatasetsAPI` by recording
+// the batches it is called with as long as err is nil.
+type spoolDatasets struct {
+	DatasetsAPI
+
+	err error
+
+	batches []string
+	options []ingest.Options
+	types   []ContentType
+	encs    []ContentEncoding
+}
+
+func (f *spoolDatasets) Ingest(_ context.Context, _ string, r io.Reader, typ ContentType, enc ContentEncoding, options ...ingest.Option) (*ingest.Status, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts ingest.Options
+	for _, option := range options {
+		option(&opts)
+	}
+
+	f.batches = append(f.batches, string(b))
+	f.options = append(f.options, opts)
+	f.types = append(f.types, typ)
+	f.encs = append(f.encs, enc)
+
+	return &ingest.Status{Ingested: 1}, nil
+}
+
+func TestSpool(t *testing.T) {
+	datasets := &spoolDatasets{
+		err: &LimitError{Limit: Limit{limitType: limitIngest}},
+	}
+
+	spool, err := NewSpool(datasets, SpoolConfig{Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = spool.Ingest(ctx, "test", strings.NewReader("a"), NDJSON, Identity, ingest.SetTimestampField("ts"))
+	require.ErrorIs(t, err, ErrSpooled)
+	var limitErr *LimitError
+	assert.ErrorAs(t, err, &limitErr)
+
+	_, err = spool.Ingest(ctx, "test", strings.NewReader("b"), JSON, Identity)
+	require.ErrorIs(t, err, ErrSpooled)
+
+	stats := spool.Stats()
+	assert.Equal(t, 2, stats.Pending)
+	assert.EqualValues(t, 2, stats.Spooled)
+	assert.Empty(t, datasets.batches)
+
+	datasets.err = nil
+
+	res, err := spool.Ingest(ctx, "test", strings.NewReader("c"), CSV, Identity)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, res.Ingested)
+
+	assert.Equal(t, []string{"a", "b", "c"}, datasets.batches)
+	assert.Equal(t, []ContentType{NDJSON, JSON, CSV}, datasets.types)
+	assert.Equal(t, ingest.Options{TimestampField: "ts"}, datasets.options[0])
+
+	stats = spool.Stats()
+	assert.Zero(t, stats.Pending)
+	assert.Zero(t, stats.PendingBytes)
+	assert.EqualValues(t, 2, stats.Replayed)
+}
+
+func TestSpool_Restart(t *testing.T) {
+	dir := t.TempDir()
+
+	datasets := &spoolDatasets{
+		err: &Error{Status: http.StatusServiceUnavailable},
+	}
+
+	spool, err := NewSpool(datasets, SpoolConfig{Dir: dir})
+	require.NoError(t, err)
+
+	for _, batch := range []string{"a", "b", "c"} {
+		_, err = spool.Ingest(context.Background(), "test", strings.NewReader(batch), NDJSON, Identity)
+		require.ErrorIs(t, err, ErrSpooled)
+	}
+
+	// A batch that was never completely written must be ignored.
+	require.NoError(t, os.WriteFile(dir+"/00000000000000000004.batch.tmp", []byte("d"), 0o600))
+
+	datasets.err = nil
+
+	spool, err = NewSpool(datasets, SpoolConfig{Dir: dir})
+	require.NoError(t, err)
+	assert.Equal(t, 3, spool.Stats().Pending)
+
+	require.NoError(t, spool.Replay(context.Background()))
+
+	assert.Equal(t, []string{"a", "b", "c"}, datasets.batches)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSpool_MaxBytes(t *testing.T) {
+	datasets := &spoolDatasets{
+		err: errors.New("connection refused"),
+	}
+
+	spool, err := NewSpool(datasets, SpoolConfig{Dir: t.TempDir(), MaxBytes: 300})
+	require.NoError(t, err)
+
+	for _, batch := range []string{"a", "b", "c", "d"} {
+		_, err = spool.Ingest(context.Background(), "test", strings.NewReader(strings.Repeat(batch, 100)), NDJSON, Identity)
+		require.ErrorIs(t, err, ErrSpooled)
+	}
+
+	_, err = spool.Ingest(context.Background(), "test", strings.NewReader(strings.Repeat("e", 500)), NDJSON, Identity)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrSpooled)
+
+	stats := spool.Stats()
+	assert.Equal(t, 1, stats.Pending)
+	assert.LessOrEqual(t, stats.PendingBytes, int64(300))
+	assert.EqualValues(t, 4, stats.Dropped)
+
+	datasets.err = nil
+	require.NoError(t, spool.Replay(context.Background()))
+
+	assert.Equal(t, []string{strings.Repeat("d", 100)}, datasets.batches)
+}
+
+func TestSpool_PermanentError(t *testing.T) {
+	datasets := &spoolDatasets{
+		err: ErrUnauthenticated,
+	}
+
+	spool, err := NewSpool(datasets, SpoolConfig{Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	_, err = spool.Ingest(context.Background(), "test", strings.NewReader("a"), NDJSON, Identity)
+	require.ErrorIs(t, err, ErrUnauthenticated)
+	assert.NotErrorIs(t, err, ErrSpooled)
+
+	assert.Zero(t, spool.Stats().Pending)
+}
+
+func TestSpool_IngestEvents(t *testing.T) {
+	datasets := &spoolDatasets{
+		err: errors.New("connection refused"),
+	}
+
+	spool, err := NewSpool(datasets, SpoolConfig{Dir: t.TempDir()})
+	require.NoError(t, err)
+
+	res, err := spool.IngestEvents(context.Background(), "test",
+		[]Event{{"foo": "bar"}, {"_reserved": "baz"}},
+		ingest.SetValidator(&ingest.Validator{}),
+	)
+	require.ErrorIs(t, err, ErrSpooled)
+
+	// The rejected event is reported, even though the batch was persisted.
+	require.NotNil(t, res)
+	assert.EqualValues(t, 1, res.Failed)
+	assert.Len(t, res.Rejected, 1)
+
+	datasets.err = nil
+	require.NoError(t, spool.Replay(context.Background()))
+
+	require.Len(t, datasets.batches, 1)
+	assert.Equal(t, NDJSON, datasets.types[0])
+	assert.Equal(t, Zstd, datasets.encs[0])
+}
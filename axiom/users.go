@@ -70,6 +70,14 @@ type User struct {
 	Emails []string `json:"emails"`
 }
 
+// UsersAPI describes the user related operations of the Axiom API, as
+// implemented by `UsersService` and faked by `axiomtest.Users`.
+type UsersAPI interface {
+	Current(ctx context.Context) (*User, error)
+}
+
+var _ UsersAPI = (*UsersService)(nil)
+
 // UsersService handles communication with the user related operations of the
 // Axiom API.
 //
@@ -78,7 +86,7 @@ type UsersService service
 
 // Current retrieves the authenticated user.
 func (s *UsersService) Current(ctx context.Context) (*User, error) {
-	ctx, span := s.client.trace(ctx, "Users.Current")
+	ctx, span := s.client.trace(ctx, Operation{Name: "Users.Current"})
 	defer span.End()
 
 	path := "/api/v1/user"
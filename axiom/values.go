@@ -0,0 +1,175 @@
+package axiom
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+)
+
+// tagTimestamp is the value of the `axiom` struct tag that marks a field as the
+// timestamp field.
+const tagTimestamp = ingest.TimestampField
+
+// timestampFields caches the timestamp field of the types passed to
+// `IngestValues` and `IngestValuesChannel`.
+var timestampFields sync.Map // map[reflect.Type]string
+
+// IngestValues ingests the given values into the dataset identified by its id.
+// In contrast to `DatasetsService.IngestEvents`, the values don't need to be
+// converted to `Event`s first but are encoded to JSON directly, honoring their
+// `json` struct tags.
+//
+// A struct field tagged with `axiom:"_time"` marks the field that holds the
+// time of the value. Its JSON name is set as timestamp field, unless one is
+// explicitly specified using `ingest.SetTimestampField`:
+//
+//	type Request struct {
+//		Time   time.Time `json:"ts" axiom:"_time"`
+//		Method string    `json:"method"`
+//	}
+//
+// Splitting the values into multiple requests works just like it does for
+// `DatasetsService.IngestEvents`.
+func IngestValues[T any](ctx context.Context, client *Client, id string, values []T, options ...ingest.Option) (*ingest.Status, error) {
+	ctx, span := client.trace(ctx, Operation{Name: "Datasets.IngestValues", DatasetID: id}, trace.WithAttributes(
+		attribute.String("axiom.dataset_id", id),
+		attribute.Int("axiom.events_to_ingest", len(values)),
+	))
+	defer span.End()
+
+	options, err := withTimestampField[T](options)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+
+	return ingestValues[T](ctx, client.Datasets, span, id, values, options, nil)
+}
+
+// IngestValuesChannel ingests the values consumed from the given channel into
+// the dataset identified by its id. It is the equivalent of
+// `DatasetsService.IngestChannel` for values which are encoded to JSON
+// directly. Refer to `IngestValues` for details.
+func IngestValuesChannel[T any](ctx context.Context, client *Client, id string, values <-chan T, options ...ingest.Option) (*ingest.Status, error) {
+	ctx, span := client.trace(ctx, Operation{Name: "Datasets.IngestValuesChannel", DatasetID: id}, trace.WithAttributes(
+		attribute.String("axiom.dataset_id", id),
+		attribute.Int("axiom.channel.capacity", cap(values)),
+	))
+	defer span.End()
+
+	options, err := withTimestampField[T](options)
+	if err != nil {
+		return nil, spanError(span, err)
+	}
+
+	return ingestChannel[T](ctx, client.Datasets, span, id, values, options, nil)
+}
+
+// withTimestampField prepends an option setting the timestamp field marked on
+// the given type to the given options, so it can still be overridden.
+func withTimestampField[T any](options []ingest.Option) ([]ingest.Option, error) {
+	field, err := timestampFieldOf(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	} else if field == "" || field == ingest.TimestampField {
+		return options, nil
+	}
+
+	return append([]ingest.Option{ingest.SetTimestampField(field)}, options...), nil
+}
+
+// timestampFieldOf returns the JSON name of the field tagged as timestamp
+// field on the given type, if any.
+func timestampFieldOf(typ reflect.Type) (string, error) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return "", nil
+	}
+
+	if field, ok := timestampFields.Load(typ); ok {
+		return field.(string), nil
+	}
+
+	fields, err := timestampFieldsOf(typ, make(map[reflect.Type]bool))
+	if err != nil {
+		return "", err
+	} else if len(fields) > 1 {
+		return "", fmt.Errorf("type %s has multiple timestamp fields: %s", typ, strings.Join(fields, ", "))
+	}
+
+	var field string
+	if len(fields) == 1 {
+		field = fields[0]
+	}
+	timestampFields.Store(typ, field)
+
+	return field, nil
+}
+
+// timestampFieldsOf returns the JSON names of all fields tagged as timestamp
+// field on the given struct type, including the ones of embedded structs.
+// Types already visited are skipped, as a struct can embed a pointer to
+// itself.
+func timestampFieldsOf(typ reflect.Type, visited map[reflect.Type]bool) ([]string, error) {
+	if visited[typ] {
+		return nil, nil
+	}
+	visited[typ] = true
+
+	var fields []string
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+
+		name, hasName := jsonFieldName(f)
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			if f.Tag.Get("axiom") == tagTimestamp {
+				return nil, fmt.Errorf("timestamp field %s of type %s is omitted from JSON", f.Name, typ)
+			}
+			continue
+		}
+
+		switch tag := f.Tag.Get("axiom"); tag {
+		case "":
+		case tagTimestamp:
+			fields = append(fields, name)
+			continue
+		default:
+			return nil, fmt.Errorf("invalid axiom tag %q on field %s of type %s", tag, f.Name, typ)
+		}
+
+		// Fields of embedded structs are promoted, unless given a name.
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && !hasName && ft.Kind() == reflect.Struct {
+			embedded, err := timestampFieldsOf(ft, visited)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, embedded...)
+		}
+	}
+	return fields, nil
+}
+
+// jsonFieldName returns the name of the given field when encoded as JSON and
+// if it was explicitly specified using the `json` struct tag.
+func jsonFieldName(f reflect.StructField) (string, bool) {
+	tag, ok := f.Tag.Lookup("json")
+	if !ok {
+		return f.Name, false
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name, true
+	}
+	return f.Name, false
+}
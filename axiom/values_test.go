@@ -0,0 +1,234 @@
+package axiom
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/axiomhq/axiom-go/axiom/ingest"
+)
+
+type testValue struct {
+	Time    time.Time `json:"ts" axiom:"_time"`
+	Message string    `json:"msg"`
+	Ignored string    `json:"-"`
+}
+
+type testValueEmbedded struct {
+	testValue
+
+	Level string `json:"level"`
+}
+
+type testValueRecursive struct {
+	*testValueRecursive
+
+	Time time.Time `json:"ts" axiom:"_time"`
+}
+
+func TestIngestValues(t *testing.T) {
+	now := time.Now().UTC()
+
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, mediaTypeNDJSON, r.Header.Get("Content-Type"))
+		assert.Equal(t, "zstd", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "ts", r.URL.Query().Get("timestamp-field"))
+
+		zsr, err := zstd.NewReader(r.Body)
+		require.NoError(t, err)
+		defer zsr.Close()
+
+		var (
+			dec    = json.NewDecoder(zsr)
+			events []Event
+		)
+		for dec.More() {
+			var event Event
+			require.NoError(t, dec.Decode(&event))
+			events = append(events, event)
+		}
+
+		assert.Equal(t, []Event{
+			{"ts": now.Format(time.RFC3339Nano), "msg": "hello", "level": "info"},
+			{"ts": now.Format(time.RFC3339Nano), "msg": "world", "level": "warn"},
+		}, events)
+
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err = fmt.Fprintf(w, `{"ingested":%d}`, len(events))
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	values := []*testValueEmbedded{
+		{testValue{now, "hello", "ignored"}, "info"},
+		{testValue{now, "world", "ignored"}, "warn"},
+	}
+
+	res, err := IngestValues(context.Background(), client, "test", values)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, res.Ingested)
+
+	valueCh := make(chan *testValueEmbedded, len(values))
+	for _, v := range values {
+		valueCh <- v
+	}
+	close(valueCh)
+
+	res, err = IngestValuesChannel(context.Background(), client, "test", valueCh)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, res.Ingested)
+}
+
+// countingValue counts how often it is encoded.
+type countingValue struct {
+	n     int
+	calls *int32
+}
+
+func (v countingValue) MarshalJSON() ([]byte, error) {
+	atomic.AddInt32(v.calls, 1)
+	return []byte(fmt.Sprintf(`{"n":%d}`, v.n)), nil
+}
+
+func TestIngestValues_EncodeOnce(t *testing.T) {
+	var lines int32
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		zsr, err := zstd.NewReader(r.Body)
+		require.NoError(t, err)
+		defer zsr.Close()
+
+		var (
+			dec = json.NewDecoder(zsr)
+			n   int
+		)
+		for ; dec.More(); n++ {
+			var event Event
+			require.NoError(t, dec.Decode(&event))
+		}
+		atomic.AddInt32(&lines, int32(n))
+
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err = fmt.Fprintf(w, `{"ingested":%d}`, n)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	var calls int32
+	values := make([]countingValue, 10)
+	for i := range values {
+		values[i] = countingValue{n: i, calls: &calls}
+	}
+
+	// Each value is 8 bytes when JSON encoded, including the newline.
+	res, err := IngestValues(context.Background(), client, "test", values,
+		ingest.SetMaxBytes(20),
+		ingest.SetMaxCompressedBytes(1),
+	)
+	require.NoError(t, err)
+
+	assert.EqualValues(t, 10, res.Ingested)
+	assert.EqualValues(t, 10, atomic.LoadInt32(&lines))
+	assert.EqualValues(t, 10, atomic.LoadInt32(&calls))
+}
+
+func TestIngestValues_TimestampFieldOverride(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "other", r.URL.Query().Get("timestamp-field"))
+
+		w.Header().Set("Content-Type", mediaTypeJSON)
+		_, err := fmt.Fprint(w, `{"ingested":1}`)
+		assert.NoError(t, err)
+	}
+
+	client := setup(t, "/api/v1/datasets/test/ingest", hf)
+
+	_, err := IngestValues(context.Background(), client, "test", []testValue{{}}, ingest.SetTimestampField("other"))
+	require.NoError(t, err)
+}
+
+func TestTimestampFieldOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  any
+		exp    string
+		expErr string
+	}{
+		{
+			name:  "no struct",
+			value: Event{},
+		},
+		{
+			name: "no tag",
+			value: struct {
+				Time time.Time `json:"time"`
+			}{},
+		},
+		{
+			name:  "tagged",
+			value: testValue{},
+			exp:   "ts",
+		},
+		{
+			name:  "embedded pointer",
+			value: &struct{ *testValue }{},
+			exp:   "ts",
+		},
+		{
+			name:  "recursive",
+			value: testValueRecursive{},
+			exp:   "ts",
+		},
+		{
+			name: "field name",
+			value: struct {
+				Time time.Time `axiom:"_time"`
+			}{},
+			exp: "Time",
+		},
+		{
+			name: "omitted",
+			value: struct {
+				Time time.Time `json:"-" axiom:"_time"`
+			}{},
+			expErr: "is omitted from JSON",
+		},
+		{
+			name: "multiple",
+			value: struct {
+				testValue
+				Other time.Time `json:"other" axiom:"_time"`
+			}{},
+			expErr: "multiple timestamp fields: ts, other",
+		},
+		{
+			name: "invalid tag",
+			value: struct {
+				Time time.Time `axiom:"time"`
+			}{},
+			expErr: `invalid axiom tag "time"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field, err := timestampFieldOf(reflect.TypeOf(tt.value))
+			if tt.expErr != "" {
+				assert.ErrorContains(t, err, tt.expErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.exp, field)
+		})
+	}
+}
@@ -1,5 +1,6 @@
-// The purpose of this example is to show how to stream the contents of a JSON
-// logfile and gzip them on the fly.
+// The purpose of this example is to show how to stream the contents of a
+// logfile, detect its format and gzip it on the fly, if it isn't compressed
+// already.
 package main
 
 import (
@@ -25,27 +26,35 @@ func main() {
 	}
 	defer f.Close()
 
-	// 2. Wrap it in a gzip enabled reader.
-	r, err := axiom.GzipEncoder(f)
+	// 2. Detect the content type and encoding of the file.
+	r, typ, enc, err := axiom.DetectContent(f)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// 3. Initialize the Axiom API client.
+	// 3. Wrap it in a gzip enabled reader, if it isn't compressed.
+	if enc == axiom.Identity {
+		if r, err = axiom.GzipEncoder(r); err != nil {
+			log.Fatal(err)
+		}
+		enc = axiom.Gzip
+	}
+
+	// 4. Initialize the Axiom API client.
 	client, err := axiom.NewClient()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// 4. Ingest ⚡
-	// Note the JSON content type and Gzip content encoding being set because
-	// the client does not auto sense them.
-	res, err := client.Datasets.Ingest(context.Background(), dataset, r, axiom.JSON, axiom.Gzip)
+	// 5. Ingest ⚡
+	// Note the detected content type and encoding being set because the client
+	// does not auto sense them.
+	res, err := client.Datasets.Ingest(context.Background(), dataset, r, typ, enc)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// 5. Make sure everything went smoothly.
+	// 6. Make sure everything went smoothly.
 	for _, fail := range res.Failures {
 		log.Print(fail.Error)
 	}
@@ -52,8 +52,7 @@ func main() {
 	// ingestion.
 	eventCh := fetchEvents(idCh)
 
-	// 4. Wrap the channel that streams up to maxItemID events to present a
-	// progress bar.
+	// 4. Create a progress bar for the up to maxItemID events to ingest.
 	bar := progressbar.NewOptions64(int64(maxItemID),
 		progressbar.OptionOnCompletion(func() {
 			fmt.Fprint(os.Stderr, "\n")
@@ -68,17 +67,6 @@ func main() {
 		progressbar.OptionSpinnerType(14),
 		progressbar.OptionThrottle(65*time.Millisecond),
 	)
-	progressEventCh := make(chan axiom.Event)
-	go func() {
-		for event := range eventCh {
-			progressEventCh <- event
-			_ = bar.Add(1)
-		}
-		close(progressEventCh)
-		if finishErr := bar.Finish(); finishErr != nil {
-			log.Fatal(finishErr)
-		}
-	}()
 
 	// 5. Initialize the Axiom API client.
 	client, err := axiom.NewClient()
@@ -87,9 +75,16 @@ func main() {
 	}
 
 	// 6. Ingest ⚡
-	res, err := client.Datasets.IngestChannel(ctx, dataset, progressEventCh,
+	// The progress of the ingestion is reported to the progress bar.
+	res, err := client.Datasets.IngestChannel(ctx, dataset, eventCh,
 		ingest.SetTimestampField("time"),
+		ingest.SetProgress(func(p ingest.Progress) {
+			_ = bar.Set64(int64(p.EventsEncoded))
+		}),
 	)
+	if finishErr := bar.Finish(); finishErr != nil {
+		log.Fatal(finishErr)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}